@@ -4,23 +4,9 @@ const (
 	ScreenWidth  = 704
 	ScreenHeight = 704
 	BlockSize    = 32 // 各テトリミノブロックのサイズ
-	BoardHeight  = 22
+	BoardHeight  = 22 // ボードの大きさの初期値（settings で変更できる）
 	BoardWidth   = 10
 
-	// カメラ機能を有効にするかどうか
-	// ゲーム上でカメラのデータを使用している場合、エラーが発生する可能性があります
-	IS_CAMERA = true
-
-	// カメラのプレビューを表示するかどうか
-	// ゲームプレイに影響はありません
-	IS_CAMERA_PREVIEW = true
-
-	// カメラプレビューのFPS
-	CAMERA_PREVIEW_FPS = 5
-
-	// 表情分析を行う頻度
-	EMOTION_ANALYSIS_FPS = 20
-
 	// landmark の各点（0-14）
 	// landmark は15つの座標から構成される配列
 	R_EYEBROW_OUTER = 0
@@ -38,11 +24,25 @@ const (
 	B_MOUTH         = 12
 	T_MOUTH         = 13
 	L_MOUTH         = 14
+	LANDMARK_COUNT  = 15
 
 	// 各表情に対応するインデックス
-	// face.Update() で choices に格納される
+	// face.Update() で EmoteFlags に格納される
 	SMILE     = 0
 	ANGRY     = 1
 	SURPRISED = 2
 	SUS       = 3
+
+	// 瞳とランドマークから判定するジェスチャー
+	// 表情と同じく EmoteFlags に格納され、判定され始めたときに FaceEvent が発生する
+	// 頬を膨らませる表情は、15 点のランドマークに頬の輪郭の点がなく判定できないため扱わない
+	WINK_LEFT    = 4 // 左目だけを閉じる
+	WINK_RIGHT   = 5 // 右目だけを閉じる
+	DOUBLE_BLINK = 6 // 素早く 2 回まばたきする
+	MOUTH_OPEN   = 7 // 口を開ける
+	TILT_LEFT    = 8 // 頭を左肩側に傾ける
+	TILT_RIGHT   = 9 // 頭を右肩側に傾ける
+
+	// 表情・ジェスチャーの総数
+	EMOTION_COUNT = 10
 )
@@ -5,12 +5,34 @@ import (
 )
 
 // ボードの定義
-type Board [][]int // 20行10列のボード
+type Board [][]int // 行ごとのセル（0: 空, 1: ブロック）
+
+// Init は width 列 height 行の空のボードを作る
+// 0 を渡した場合は constants.BoardWidth / constants.BoardHeight を使う
+func (b *Board) Init(width, height int) {
+	if width <= 0 {
+		width = constants.BoardWidth
+	}
+	if height <= 0 {
+		height = constants.BoardHeight
+	}
 
-func (b *Board) Init() {
 	// ボードを定義
-	*b = make([][]int, constants.BoardHeight)
+	*b = make([][]int, height)
 	for i := range *b {
-		(*b)[i] = make([]int, constants.BoardWidth)
+		(*b)[i] = make([]int, width)
 	}
-}
\ No newline at end of file
+}
+
+// Width はボードの列数を返す
+func (b Board) Width() int {
+	if len(b) == 0 {
+		return 0
+	}
+	return len(b[0])
+}
+
+// Height はボードの行数を返す
+func (b Board) Height() int {
+	return len(b)
+}
@@ -0,0 +1,354 @@
+// Package bot はテトリミノの置き場所を探して自動で遊ぶ CPU プレイヤー
+//
+// 現在のテトリミノ（UseHold ならホールドと入れ替えたものも）を全ての向き・列に落とした盤面を
+// Weights による評価値で比べ、最も良い置き場所まで input.Action で操作する。
+// 対戦の CPU、プレイ中のヒント、ヘッドレスでの成績の確認に使う
+package bot
+
+import (
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/input"
+)
+
+// Weights は盤面の評価に使う各特徴量の重み
+type Weights struct {
+	Height    float64 `json:"height"`    // 各列の高さの合計
+	Lines     float64 `json:"lines"`     // 消した行数
+	Holes     float64 `json:"holes"`     // 上をブロックで塞がれた空きマスの数
+	Bumpiness float64 `json:"bumpiness"` // 隣り合う列の高さの差の合計
+}
+
+// 重みの初期値
+// 高さ・穴・でこぼこを減らし、行を消すほど良い評価になる
+var DefaultWeights = Weights{
+	Height:    -0.510066,
+	Lines:     0.760666,
+	Holes:     -0.35663,
+	Bumpiness: -0.184483,
+}
+
+// CPU の強さの範囲
+// 強さは操作の間隔に対応し、最も強い場合は毎フレーム操作する
+const (
+	MinLevel = 1
+	MaxLevel = 10
+)
+
+// 置き場所に着くまでに操作する回数の上限（超えた場合はその場でハードドロップする）
+const maxActions = 20
+
+// Placement はテトリミノの置き場所
+type Placement struct {
+	Hold      bool    // ホールドと入れ替えてから置く
+	Rotations int     // 時計回りに回転する回数
+	X, Y      int     // 落とした後の位置
+	Shape     [][]int // 回転した後の形
+	Score     float64 // 置いた後の盤面の評価値
+}
+
+// Bot は CPU プレイヤー
+type Bot struct {
+	Weights  Weights
+	UseHold  bool // ホールドと入れ替えた置き場所も探す
+	Interval int  // 操作の間隔（フレーム数、1 なら毎フレーム）
+
+	piece   *engine.Tetromino // 置き場所を決めたテトリミノ
+	target  Placement
+	wait    int // 次の操作までのフレーム数
+	actions int // 現在のテトリミノに対して操作した回数
+}
+
+// New は重みの初期値を使い、ホールドも使う CPU を作る
+func New() *Bot {
+	return &Bot{Weights: DefaultWeights, UseHold: true, Interval: 1}
+}
+
+// NewLevel は強さ（MinLevel から MaxLevel）に応じた操作の間隔の CPU を作る
+func NewLevel(level int) *Bot {
+	if level < MinLevel {
+		level = MinLevel
+	}
+	if level > MaxLevel {
+		level = MaxLevel
+	}
+	b := New()
+	b.Interval = 2*(MaxLevel-level) + 1
+	return b
+}
+
+// Best は現在のテトリミノの最も評価値の高い置き場所を返す
+// 置ける場所がない場合は false を返す
+func (b *Bot) Best(e *engine.Engine) (Placement, bool) {
+	return best(b.placements(e))
+}
+
+// BestFor は board に t を置く場合の最も評価値の高い置き場所を返す（ホールドは考えない）
+// 次のテトリミノの候補を比べるときに使う
+func (b *Bot) BestFor(board domain.Board, t *engine.Tetromino) (Placement, bool) {
+	return best(piecePlacements(board, baseShape(t), b.Weights))
+}
+
+func best(placements []Placement) (Placement, bool) {
+	var best Placement
+	found := false
+	for _, p := range placements {
+		if !found || p.Score > best.Score {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// Actions はこのフレームの操作を返す
+// 毎フレーム呼ぶと、Interval フレームごとに 1 つずつ、置き場所に向けて回転・移動してハードドロップする
+func (b *Bot) Actions(e *engine.Engine) []input.Action {
+	if e.Over || e.Current == nil {
+		return nil
+	}
+
+	// 新しいテトリミノになったら（ホールドで入れ替わった場合も）置き場所を決め直す
+	if e.Current != b.piece {
+		b.piece = e.Current
+		b.actions = 0
+		target, ok := b.Best(e)
+		if !ok {
+			return []input.Action{input.HardDrop}
+		}
+		b.target = target
+	}
+
+	if b.wait > 0 {
+		b.wait--
+		return nil
+	}
+	b.wait = b.Interval - 1
+	b.actions++
+
+	t := e.Current
+	switch {
+	case b.actions > maxActions:
+		return []input.Action{input.HardDrop}
+	case b.target.Hold && !e.HoldUsed:
+		return []input.Action{input.Hold}
+	case !sameShape(t.Shape, b.target.Shape):
+		return []input.Action{input.RotateCW}
+	case t.X < b.target.X:
+		return []input.Action{input.MoveRight}
+	case t.X > b.target.X:
+		return []input.Action{input.MoveLeft}
+	}
+	return []input.Action{input.HardDrop}
+}
+
+// placements は全ての置き場所と、その評価値を返す
+func (b *Bot) placements(e *engine.Engine) []Placement {
+	placements := piecePlacements(e.Board, e.Current.Shape, b.Weights)
+
+	if b.UseHold && !e.HoldUsed {
+		// ホールドが空の場合は、次のテトリミノが出てくる
+		held := e.Hold
+		if held == nil && len(e.Next) > 0 {
+			held = e.Next[0]
+		}
+		if held != nil {
+			for _, p := range piecePlacements(e.Board, baseShape(held), b.Weights) {
+				p.Hold = true
+				placements = append(placements, p)
+			}
+		}
+	}
+	return placements
+}
+
+// piecePlacements は shape を全ての向き・列で一番下まで落とした置き場所を返す
+func piecePlacements(board domain.Board, shape [][]int, w Weights) []Placement {
+	var placements []Placement
+	var shapes [][][]int
+	for rotations := 0; rotations < 4; rotations++ {
+		// 同じ形になる向き（O の全て、I・S・Z の 180 度）は 1 回だけ調べる
+		duplicate := false
+		for _, s := range shapes {
+			if sameShape(s, shape) {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			shapes = append(shapes, shape)
+			for x := 0; x+len(shape[0]) <= board.Width(); x++ {
+				y, ok := dropY(board, shape, x)
+				if !ok {
+					continue
+				}
+				placements = append(placements, Placement{
+					Rotations: rotations,
+					X:         x,
+					Y:         y,
+					Shape:     shape,
+					Score:     Evaluate(place(board, shape, x, y), w),
+				})
+			}
+		}
+		shape = rotateCW(shape)
+	}
+	return placements
+}
+
+// Evaluate は盤面の評価値を返す（揃った行は消してから数える）
+func Evaluate(board domain.Board, w Weights) float64 {
+	board, lines := clearLines(board)
+
+	heights := make([]int, board.Width())
+	holes := 0
+	for x := range heights {
+		for y := 0; y < board.Height(); y++ {
+			if board[y][x] != 0 {
+				if heights[x] == 0 {
+					heights[x] = board.Height() - y
+				}
+			} else if heights[x] > 0 {
+				holes++
+			}
+		}
+	}
+
+	height, bumpiness := 0, 0
+	for x, h := range heights {
+		height += h
+		if x > 0 {
+			bumpiness += abs(h - heights[x-1])
+		}
+	}
+
+	return w.Height*float64(height) + w.Lines*float64(lines) + w.Holes*float64(holes) + w.Bumpiness*float64(bumpiness)
+}
+
+// dropY は shape を x 列の一番上から落としたときの y を返す（一番上にも置けなければ false）
+func dropY(board domain.Board, shape [][]int, x int) (int, bool) {
+	if !fits(board, shape, x, 0) {
+		return 0, false
+	}
+	y := 0
+	for fits(board, shape, x, y+1) {
+		y++
+	}
+	return y, true
+}
+
+func fits(board domain.Board, shape [][]int, x, y int) bool {
+	for dy, row := range shape {
+		for dx, cell := range row {
+			if cell == 0 {
+				continue
+			}
+			bx, by := x+dx, y+dy
+			if bx < 0 || bx >= board.Width() || by >= board.Height() {
+				return false
+			}
+			if by >= 0 && board[by][bx] != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// place は shape を (x, y) に置いた盤面のコピーを返す
+func place(board domain.Board, shape [][]int, x, y int) domain.Board {
+	placed := make(domain.Board, len(board))
+	for i, row := range board {
+		placed[i] = append([]int(nil), row...)
+	}
+	for dy, row := range shape {
+		for dx, cell := range row {
+			if cell != 0 && y+dy >= 0 {
+				placed[y+dy][x+dx] = 1
+			}
+		}
+	}
+	return placed
+}
+
+// clearLines は揃った行を取り除いて上に空の行を足した盤面と、消した行数を返す
+func clearLines(board domain.Board) (domain.Board, int) {
+	cleared := make(domain.Board, 0, len(board))
+	for _, row := range board {
+		full := true
+		for _, cell := range row {
+			if cell == 0 {
+				full = false
+				break
+			}
+		}
+		if !full {
+			cleared = append(cleared, row)
+		}
+	}
+	lines := len(board) - len(cleared)
+	for i := 0; i < lines; i++ {
+		cleared = append(domain.Board{make([]int, board.Width())}, cleared...)
+	}
+	return cleared, lines
+}
+
+// baseShape は回転していない向きの形を返す（ホールドしたテトリミノはこの向きで出てくる）
+func baseShape(t *engine.Tetromino) [][]int {
+	shape := t.Shape
+	for r := t.Rotation; r != 0; r = (r + 270) % 360 {
+		shape = rotateCCW(shape)
+	}
+	return shape
+}
+
+// rotateCW と rotateCCW は engine の回転と同じ向きに形を回転する
+func rotateCW(shape [][]int) [][]int {
+	rows, cols := len(shape), len(shape[0])
+	rotated := make([][]int, cols)
+	for i := range rotated {
+		rotated[i] = make([]int, rows)
+	}
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			rotated[x][rows-1-y] = shape[y][x]
+		}
+	}
+	return rotated
+}
+
+func rotateCCW(shape [][]int) [][]int {
+	rows, cols := len(shape), len(shape[0])
+	rotated := make([][]int, cols)
+	for i := range rotated {
+		rotated[i] = make([]int, rows)
+	}
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			rotated[cols-1-x][y] = shape[y][x]
+		}
+	}
+	return rotated
+}
+
+func sameShape(a, b [][]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for y := range a {
+		if len(a[y]) != len(b[y]) {
+			return false
+		}
+		for x := range a[y] {
+			if a[y][x] != b[y][x] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
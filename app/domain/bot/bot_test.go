@@ -0,0 +1,300 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/engine"
+)
+
+// board は "#" をブロック、"." を空きマスとした行から盤面を作る
+func board(rows ...string) domain.Board {
+	b := make(domain.Board, len(rows))
+	for y, row := range rows {
+		b[y] = make([]int, len(row))
+		for x, c := range row {
+			if c == '#' {
+				b[y][x] = 1
+			}
+		}
+	}
+	return b
+}
+
+// emptyBoard は width x height の空の盤面
+func emptyBoard(width, height int) domain.Board {
+	rows := make([]string, height)
+	for i := range rows {
+		rows[i] = strings.Repeat(".", width)
+	}
+	return board(rows...)
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name                            string
+		board                           domain.Board
+		height, lines, holes, bumpiness int
+	}{
+		{"empty", board(
+			"....",
+			"....",
+			"....",
+		), 0, 0, 0, 0},
+		{"flat", board(
+			"....",
+			"....",
+			"###.",
+		), 3, 0, 0, 1},
+		{"hole", board(
+			"....",
+			".#..",
+			"....",
+		), 2, 0, 1, 4},
+		{"hole under a column", board(
+			"#...",
+			"....",
+			"#...",
+		), 3, 0, 1, 3},
+		{"line", board(
+			"....",
+			"#...",
+			"####",
+		), 1, 1, 0, 1},
+		{"two lines with a hole above", board(
+			"..#.",
+			"....",
+			"####",
+			"####",
+		), 2, 2, 1, 4},
+		{"bumpy", board(
+			"#...",
+			"#.#.",
+			"#.##",
+		), 6, 0, 0, 3 + 2 + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features := []struct {
+				name string
+				w    Weights
+				want int
+			}{
+				{"height", Weights{Height: 1}, tt.height},
+				{"lines", Weights{Lines: 1}, tt.lines},
+				{"holes", Weights{Holes: 1}, tt.holes},
+				{"bumpiness", Weights{Bumpiness: 1}, tt.bumpiness},
+			}
+			for _, f := range features {
+				if got := Evaluate(tt.board, f.w); got != float64(f.want) {
+					t.Errorf("%s = %v, want %d", f.name, got, f.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPiecePlacements(t *testing.T) {
+	shape := func(name string) [][]int {
+		return engine.TetrominoByName(name).Shape
+	}
+	// 左端の列が一番上まで埋まった盤面
+	walled := emptyBoard(10, 20)
+	for y := range walled {
+		walled[y][0] = 1
+	}
+
+	tests := []struct {
+		name      string
+		board     domain.Board
+		shape     [][]int
+		rotations []int // 置き場所ごとの回転数（重複した向きは含まれない）
+		columns   [][]int
+	}{
+		{"O", emptyBoard(10, 20), shape("O"), []int{0}, [][]int{{0, 1, 2, 3, 4, 5, 6, 7, 8}}},
+		{"I", emptyBoard(10, 20), shape("I"), []int{0, 1}, [][]int{
+			{0, 1, 2, 3, 4, 5, 6},
+			{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		}},
+		{"S", emptyBoard(6, 20), shape("S"), []int{0, 1}, [][]int{{0, 1, 2, 3}, {0, 1, 2, 3, 4}}},
+		{"T", emptyBoard(5, 20), shape("T"), []int{0, 1, 2, 3}, [][]int{{0, 1, 2}, {0, 1, 2, 3}, {0, 1, 2}, {0, 1, 2, 3}}},
+		{"O next to a wall", walled, shape("O"), []int{0}, [][]int{{1, 2, 3, 4, 5, 6, 7, 8}}},
+		{"I next to a wall", walled, shape("I"), []int{0, 1}, [][]int{
+			{1, 2, 3, 4, 5, 6},
+			{1, 2, 3, 4, 5, 6, 7, 8, 9},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns := map[int][]int{}
+			for _, p := range piecePlacements(tt.board, tt.shape, DefaultWeights) {
+				columns[p.Rotations] = append(columns[p.Rotations], p.X)
+				if want, _ := dropY(tt.board, p.Shape, p.X); p.Y != want || !fits(tt.board, p.Shape, p.X, p.Y) {
+					t.Errorf("rotation %d, x %d: y = %d, want %d", p.Rotations, p.X, p.Y, want)
+				}
+			}
+			if len(columns) != len(tt.rotations) {
+				t.Fatalf("rotations = %v, want %v", columns, tt.rotations)
+			}
+			for i, r := range tt.rotations {
+				if !equalInts(columns[r], tt.columns[i]) {
+					t.Errorf("rotation %d: columns = %v, want %v", r, columns[r], tt.columns[i])
+				}
+			}
+		})
+	}
+}
+
+// 埋まっている盤面には置けない
+func TestPiecePlacementsOnFullBoard(t *testing.T) {
+	full := board("####", "####")
+	if placements := piecePlacements(full, engine.TetrominoByName("O").Shape, DefaultWeights); len(placements) != 0 {
+		t.Errorf("placements = %+v, want none", placements)
+	}
+}
+
+// engine で回転したテトリミノから、回転していない向きの形に戻せる
+func TestBaseShape(t *testing.T) {
+	e := engine.New(1, engine.DefaultOptions)
+	for _, tetromino := range engine.Tetrominos {
+		for rotations := 0; rotations < 8; rotations++ {
+			for _, clockwise := range []bool{true, false} {
+				piece := tetromino
+				e.Current = &piece
+				for i := 0; i < rotations; i++ {
+					if clockwise {
+						e.RotateTetromino()
+					} else {
+						e.RotateTetrominoCCW()
+					}
+				}
+				if got := baseShape(&piece); !sameShape(got, tetromino.Shape) {
+					t.Errorf("%s rotated %d times (clockwise %v): base shape = %v, want %v",
+						tetromino.Name, rotations, clockwise, got, tetromino.Shape)
+				}
+			}
+		}
+	}
+}
+
+// newEngine は重力で落ちないゲームを始め、最初のテトリミノを出す
+func newEngine(seed int64) *engine.Engine {
+	opts := engine.DefaultOptions
+	opts.DropInterval = time.Hour
+	opts.TimeLimit = time.Hour
+	e := engine.New(seed, opts)
+	e.Step(nil, nil)
+	return e
+}
+
+// playPiece は現在のテトリミノを置き終わるまで CPU に操作させる
+func playPiece(t *testing.T, e *engine.Engine, b *Bot) {
+	t.Helper()
+	piece := e.Current
+	for i := 0; e.Current == piece || e.Current == nil; i++ {
+		if i > 10*maxActions*b.Interval {
+			t.Fatalf("the piece %s was not placed", piece.Name)
+		}
+		e.Step(b.Actions(e), nil)
+		if e.Over {
+			t.Fatal("game over")
+		}
+	}
+}
+
+// Actions で操作すると、Best で選んだ置き場所に置ける
+func TestActionsReachTarget(t *testing.T) {
+	for _, interval := range []int{1, 3} {
+		for seed := int64(1); seed <= 5; seed++ {
+			e := newEngine(seed)
+			b := New()
+			b.UseHold = false
+			b.Interval = interval
+			for piece := 0; piece < 15; piece++ {
+				target, ok := b.Best(e)
+				if !ok {
+					t.Fatalf("seed %d: no placement", seed)
+				}
+				want, _ := clearLines(place(e.Board, target.Shape, target.X, target.Y))
+
+				playPiece(t, e, b)
+				if !sameShape(e.Board, want) {
+					t.Fatalf("seed %d, interval %d, piece %d: board = %v, want %v", seed, interval, piece, e.Board, want)
+				}
+			}
+		}
+	}
+}
+
+// ホールドしたテトリミノのほうが良い場合は、ホールドしてから置く
+func TestActionsHold(t *testing.T) {
+	tests := []struct {
+		name string
+		hold *engine.Tetromino // ホールド中のテトリミノ（nil なら次のテトリミノと入れ替わる）
+	}{
+		{"empty hold", nil},
+		{"held piece", engine.TetrominoByName("I")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEngine(1)
+			// 右端の列だけが空いた 4 行の上に O が出ている。I を縦に置けば 4 行消せる
+			for y := e.Board.Height() - 4; y < e.Board.Height(); y++ {
+				for x := 0; x < e.Board.Width()-1; x++ {
+					e.Board[y][x] = 1
+				}
+			}
+			o, i, next := *engine.TetrominoByName("O"), *engine.TetrominoByName("I"), *engine.TetrominoByName("T")
+			o.X = e.Current.X
+			e.Current = &o
+			if tt.hold != nil {
+				held := *tt.hold
+				e.Hold = &held
+				e.Next[0] = &next
+			} else {
+				e.Next[0] = &i
+			}
+
+			b := New()
+			if target, _ := b.Best(e); !target.Hold {
+				t.Fatalf("target = %+v, want to hold", target)
+			}
+			playPiece(t, e, b)
+			playPiece(t, e, b)
+
+			if e.Lines != 4 {
+				t.Errorf("Lines = %d, want 4", e.Lines)
+			}
+			if e.Hold == nil || e.Hold.Name != "O" {
+				t.Errorf("Hold = %v, want O", e.Hold)
+			}
+		})
+	}
+}
+
+// ホールドを使わない CPU はホールドしない
+func TestActionsWithoutHold(t *testing.T) {
+	e := newEngine(1)
+	b := New()
+	b.UseHold = false
+	for piece := 0; piece < 20; piece++ {
+		playPiece(t, e, b)
+		if e.Hold != nil {
+			t.Fatalf("Hold = %v after %d pieces", e.Hold, piece+1)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,16 @@
+package bot
+
+import "square-face-tetris/app/domain/engine"
+
+// Play はゲームが終わるまで CPU に遊ばせる（画面を使わない成績の確認に使う）
+// emotions は各フレームで判定されたことにする表情を返す（nil なら表情なし）
+func Play(e *engine.Engine, b *Bot, emotions func(e *engine.Engine) []int) {
+	for !e.Over {
+		var emotionIndexes []int
+		if emotions != nil {
+			emotionIndexes = emotions(e)
+		}
+		e.Step(b.Actions(e), emotionIndexes)
+		e.PopEvents()
+	}
+}
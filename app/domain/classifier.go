@@ -0,0 +1,19 @@
+package domain
+
+// Classifier は顔のランドマークから表情を判定する
+// Face（ルールベース）のほか、学習済みモデルなどを差し替えられるようにする
+type Classifier interface {
+	// Calibrate は基準となる（無表情の）ランドマークを設定する
+	Calibrate(baseline Landmarks)
+	// Classify は constants.SMILE などのインデックスに対応するフラグを返す
+	// 判定に必要な点が欠けている表情は false にする
+	Classify(landmarks Landmarks) []bool
+}
+
+// ConfidenceClassifier は判定の確からしさも返せる分類器
+// 実装していない分類器の確からしさは、判定した表情を 1、それ以外を 0 とする
+type ConfidenceClassifier interface {
+	Classifier
+	// Confidences は constants.SMILE などのインデックスに対応する 0 から 1 の確からしさを返す
+	Confidences(landmarks Landmarks) []float64
+}
@@ -0,0 +1,91 @@
+package dataset
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"square-face-tetris/app/domain"
+)
+
+// 表情が出ていないフレームのラベル
+const Neutral = "NEUTRAL"
+
+// Sample はラベル付きの 1 フレーム分のランドマーク
+// JSONL ファイルの 1 行が 1 つの Sample に対応する
+type Sample struct {
+	Time      int64   `json:"time,omitempty"`   // 記録時刻（UNIX ミリ秒）
+	Landmarks [][]int `json:"landmarks"`        // 15 点のランドマーク [x, y, scale]
+	Pupils    [][]int `json:"pupils,omitempty"` // 左右の瞳 [x, y, scale]（未検出は null）
+	Baseline  [][]int `json:"baseline"`         // 基準（無表情）のランドマーク
+	Label     string  `json:"label"`            // SMILE, ANGRY, ..., NEUTRAL
+}
+
+// Current は記録したフレームのランドマークと瞳を返す
+func (s Sample) Current() domain.Landmarks {
+	return domain.NewLandmarks(s.Landmarks, s.Pupils)
+}
+
+// BaselineLandmarks は基準のランドマークを返す
+func (s Sample) BaselineLandmarks() domain.Landmarks {
+	return domain.NewLandmarks(s.Baseline, nil)
+}
+
+// Writer は Sample を JSONL 形式で書き出す
+type Writer struct {
+	enc *json.Encoder
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{enc: json.NewEncoder(w)}
+}
+
+// Write は 1 行分の Sample を書き出す
+func (w *Writer) Write(s Sample) error {
+	return w.enc.Encode(s)
+}
+
+// Read は JSONL 形式の Sample を全て読み込む
+// 空行は読み飛ばす
+func Read(r io.Reader) ([]Sample, error) {
+	var samples []Sample
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var s Sample
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("%d 行目を読み込めません: %w", line, err)
+		}
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// ReadFiles は複数の JSONL ファイルを読み込んで連結する
+func ReadFiles(paths ...string) ([]Sample, error) {
+	var samples []Sample
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		s, err := Read(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		samples = append(samples, s...)
+	}
+	return samples, nil
+}
@@ -0,0 +1,120 @@
+package dataset
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+)
+
+// fakeClassifier は 0 番目のランドマークの x 座標を表情のインデックスとして返す（範囲外なら NEUTRAL）
+type fakeClassifier struct {
+	calibrations int
+}
+
+func (c *fakeClassifier) Calibrate(baseline domain.Landmarks) {
+	c.calibrations++
+}
+
+func (c *fakeClassifier) Classify(landmarks domain.Landmarks) []bool {
+	flags := make([]bool, constants.EMOTION_COUNT)
+	if i := landmarks.Points[0].X; i >= 0 && i < len(flags) {
+		flags[i] = true
+	}
+	return flags
+}
+
+// points は 0 番目の x 座標が x の、全ての点が揃ったランドマークを返す
+func points(x int) [][]int {
+	p := make([][]int, constants.LANDMARK_COUNT)
+	for i := range p {
+		p[i] = []int{x, i, 1}
+	}
+	return p
+}
+
+func sample(label string, predicted int, baseline int) Sample {
+	return Sample{Landmarks: points(predicted), Baseline: points(baseline), Label: label}
+}
+
+func TestRoundTripAndEvaluate(t *testing.T) {
+	smile := domain.EmotionName(constants.SMILE)
+	angry := domain.EmotionName(constants.ANGRY)
+	incomplete := sample(smile, constants.SMILE, 100)
+	incomplete.Landmarks[3] = nil
+
+	samples := []Sample{
+		sample(smile, constants.SMILE, 100),
+		sample(smile, constants.SMILE, 100),
+		sample(smile, constants.ANGRY, 100),
+		sample(angry, constants.ANGRY, 200),
+		sample(Neutral, 100, 200),
+		sample(Neutral, constants.SMILE, 200),
+		sample("UNKNOWN_LABEL", constants.SMILE, 200),
+		incomplete,
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i, s := range samples {
+		if err := w.Write(s); err != nil {
+			t.Fatal(err)
+		}
+		if i == 3 {
+			buf.WriteString("\n") // 空行は読み飛ばす
+		}
+	}
+
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, samples) {
+		t.Fatalf("Read = %v, want %v", read, samples)
+	}
+
+	c := &fakeClassifier{}
+	r := Evaluate(c, read)
+	if r.Total != 6 || r.Skipped != 2 {
+		t.Errorf("Total, Skipped = %d, %d, want 6, 2", r.Total, r.Skipped)
+	}
+	if c.calibrations != 2 {
+		t.Errorf("calibrations = %d, want 2", c.calibrations)
+	}
+
+	neutral := len(r.Labels) - 1
+	want := map[[2]int]int{
+		{constants.SMILE, constants.SMILE}: 2,
+		{constants.SMILE, constants.ANGRY}: 1,
+		{constants.ANGRY, constants.ANGRY}: 1,
+		{neutral, neutral}:                 1,
+		{neutral, constants.SMILE}:         1,
+	}
+	for actual, row := range r.Confusion {
+		for predicted, n := range row {
+			if n != want[[2]int{actual, predicted}] {
+				t.Errorf("Confusion[%s][%s] = %d, want %d", r.Labels[actual], r.Labels[predicted], n, want[[2]int{actual, predicted}])
+			}
+		}
+	}
+
+	if got := r.Accuracy(); got != 4.0/6 {
+		t.Errorf("Accuracy = %v, want %v", got, 4.0/6)
+	}
+	if got := r.Precision(constants.SMILE); got != 2.0/3 {
+		t.Errorf("Precision(SMILE) = %v, want %v", got, 2.0/3)
+	}
+	if got := r.Recall(constants.SMILE); got != 2.0/3 {
+		t.Errorf("Recall(SMILE) = %v, want %v", got, 2.0/3)
+	}
+}
+
+func TestReadReportsLine(t *testing.T) {
+	_, err := Read(strings.NewReader("{\"label\":\"SMILE\"}\n{broken\n"))
+	if err == nil || !strings.Contains(err.Error(), "2 行目") {
+		t.Errorf("Read error = %v, want an error for line 2", err)
+	}
+}
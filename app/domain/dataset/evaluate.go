@@ -0,0 +1,157 @@
+package dataset
+
+import (
+	"fmt"
+	"io"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+)
+
+// Labels は評価に使うラベルの一覧（表情のインデックス順 + NEUTRAL）
+func Labels() []string {
+	labels := make([]string, 0, constants.EMOTION_COUNT+1)
+	for i := 0; i < constants.EMOTION_COUNT; i++ {
+		labels = append(labels, domain.EmotionName(i))
+	}
+	return append(labels, Neutral)
+}
+
+// Predict は分類器のフラグから 1 つのラベルを決める
+// 最初に立っているフラグの表情を採用し、どれも立っていなければ NEUTRAL とする
+func Predict(flags []bool) string {
+	for i, flag := range flags {
+		if flag {
+			return domain.EmotionName(i)
+		}
+	}
+	return Neutral
+}
+
+// Report は分類器の評価結果
+type Report struct {
+	Labels    []string
+	Confusion [][]int // Confusion[正解][予測]
+	Total     int     // 評価したフレーム数
+	Skipped   int     // ランドマークが欠けていて評価できなかったフレーム数
+}
+
+// Evaluate はデータセットの全フレームに分類器をかけて結果を集計する
+// 基準ランドマークが変わるたびに分類器を較正し直す
+func Evaluate(c domain.Classifier, samples []Sample) Report {
+	labels := Labels()
+	index := make(map[string]int, len(labels))
+	for i, label := range labels {
+		index[label] = i
+	}
+
+	r := Report{Labels: labels, Confusion: make([][]int, len(labels))}
+	for i := range r.Confusion {
+		r.Confusion[i] = make([]int, len(labels))
+	}
+
+	var baseline [][]int
+	for _, s := range samples {
+		actual, ok := index[s.Label]
+		if !ok || !s.Current().Complete() || !s.BaselineLandmarks().Complete() {
+			r.Skipped++
+			continue
+		}
+		if !sameLandmarks(baseline, s.Baseline) {
+			c.Calibrate(s.BaselineLandmarks())
+			baseline = s.Baseline
+		}
+
+		predicted := index[Predict(c.Classify(s.Current()))]
+		r.Confusion[actual][predicted]++
+		r.Total++
+	}
+	return r
+}
+
+// Precision は i 番目のラベルと予測したうち正解だった割合
+func (r Report) Precision(i int) float64 {
+	var sum int
+	for actual := range r.Labels {
+		sum += r.Confusion[actual][i]
+	}
+	if sum == 0 {
+		return 0
+	}
+	return float64(r.Confusion[i][i]) / float64(sum)
+}
+
+// Recall は正解が i 番目のラベルのうち正しく予測できた割合
+func (r Report) Recall(i int) float64 {
+	var sum int
+	for _, n := range r.Confusion[i] {
+		sum += n
+	}
+	if sum == 0 {
+		return 0
+	}
+	return float64(r.Confusion[i][i]) / float64(sum)
+}
+
+// Accuracy は全体の正解率
+func (r Report) Accuracy() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	var correct int
+	for i := range r.Labels {
+		correct += r.Confusion[i][i]
+	}
+	return float64(correct) / float64(r.Total)
+}
+
+// Fprint は表情ごとの適合率・再現率と混同行列を表形式で書き出す
+func (r Report) Fprint(w io.Writer) {
+	fmt.Fprintf(w, "frames: %d (skipped: %d)  accuracy: %.3f\n\n", r.Total, r.Skipped, r.Accuracy())
+
+	fmt.Fprintf(w, "%-10s %9s %9s %7s\n", "label", "precision", "recall", "support")
+	for i, label := range r.Labels {
+		var support int
+		for _, n := range r.Confusion[i] {
+			support += n
+		}
+		fmt.Fprintf(w, "%-10s %9.3f %9.3f %7d\n", label, r.Precision(i), r.Recall(i), support)
+	}
+
+	fmt.Fprintf(w, "\nconfusion (row: actual, column: predicted)\n%-10s", "")
+	for _, label := range r.Labels {
+		fmt.Fprintf(w, " %9s", abbrev(label))
+	}
+	fmt.Fprintln(w)
+	for i, label := range r.Labels {
+		fmt.Fprintf(w, "%-10s", label)
+		for _, n := range r.Confusion[i] {
+			fmt.Fprintf(w, " %9d", n)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func abbrev(label string) string {
+	if len(label) > 9 {
+		return label[:9]
+	}
+	return label
+}
+
+func sameLandmarks(a, b [][]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+// Package detection はカメラの映像から顔を検出する処理を、ゲームのループとは別に動かす
+//
+// ゲームは取得したフレームを Submit し、検出の結果を Results から受け取る。
+// 検出が追いつかない場合は古いフレームを捨て、常に最新のフレームだけを検出する
+package detection
+
+import "time"
+
+// Frame は検出にかけるグレースケールの 1 フレーム
+type Frame struct {
+	Time   time.Time // フレームを取得した時刻
+	Pixels []byte    // Width x Height のグレースケールの画素
+	Width  int
+	Height int
+}
+
+// Result は 1 フレームの検出の結果
+type Result struct {
+	Time     time.Time     `json:"time"`     // 検出したフレームを取得した時刻
+	Duration time.Duration `json:"duration"` // 検出にかかった時間
+	Faces    [][]int       `json:"faces"`    // 検出した全ての顔 [row, col, scale, q]（追跡している顔が先頭）
+	// 瞳とランドマークを検出した顔（Faces の先頭から Options.MaxFaces 個まで）
+	Tracks []Track `json:"tracks"`
+}
+
+// Track は瞳とランドマークを検出した 1 つの顔
+type Track struct {
+	ID        int     `json:"id"`        // フレームをまたいで変わらない顔の番号
+	Face      []int   `json:"face"`      // [row, col, scale, q]
+	Landmarks [][]int `json:"landmarks"` // ランドマーク [x, y, scale]（15 点）
+	Pupils    [][]int `json:"pupils"`    // 左右の瞳 [x, y, scale]
+	// 顔は見つかったが、瞳やランドマークが足りずに使わなかった理由（使える場合は空）
+	Rejected string `json:"rejected,omitempty"`
+}
+
+// Primary は追跡している顔（Faces の先頭）を返す。顔が見つからなかった場合は false を返す
+func (r Result) Primary() (Track, bool) {
+	if len(r.Tracks) == 0 {
+		return Track{}, false
+	}
+	return r.Tracks[0], true
+}
+
+// Detector は 1 フレームから顔を検出する
+type Detector interface {
+	Configure(o Options)
+	Detect(f Frame) Result
+}
+
+// Runner はフレームを非同期に検出する
+type Runner interface {
+	// Buffer は Submit に渡す n バイトの画素のバッファを返す
+	Buffer(n int) []byte
+	// Configure は検出のパラメータを変える（次に検出するフレームから反映する）
+	Configure(o Options)
+	// Submit はフレームの検出を依頼する。検出中のフレームがあれば、待っている古いフレームは捨てる
+	Submit(f Frame)
+	// Results は検出の結果を返すチャネル（Close の後に閉じる）
+	Results() <-chan Result
+	// Close は検出を止める
+	Close()
+}
+
+// 受け取られていない結果を溜めておく数（溢れた場合は古い結果を捨てる）
+const ResultBuffer = 4
+
+// Send は結果を results に送る。受け取られていない結果が溜まっている場合は古いものを捨てる
+func Send(results chan Result, r Result) {
+	for {
+		select {
+		case results <- r:
+			return
+		default:
+		}
+		select {
+		case <-results:
+		default:
+		}
+	}
+}
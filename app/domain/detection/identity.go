@@ -0,0 +1,78 @@
+package detection
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Identities は見つけた顔に、フレームをまたいで変わらない番号（1 から）を振る
+// 前のフレームの顔と位置が近い顔には同じ番号を、近い顔がなければ新しい番号を振る
+type Identities struct {
+	MaxAge      time.Duration // この時間見つからなかった顔の番号は使わない
+	MaxDistance float64       // 同じ顔とみなす中心の距離（顔の大きさに対する割合）
+
+	next  int
+	known []identity
+}
+
+type identity struct {
+	id       int
+	row, col float64
+	scale    float64
+	seen     time.Time
+}
+
+func NewIdentities() *Identities {
+	return &Identities{MaxAge: time.Second, MaxDistance: 0.5}
+}
+
+// Assign は faces（[row, col, scale, q]）のそれぞれの番号を返す
+func (ids *Identities) Assign(faces [][]int, now time.Time) []int {
+	// 古くなった顔を忘れる
+	known := ids.known[:0]
+	for _, k := range ids.known {
+		if now.Sub(k.seen) <= ids.MaxAge {
+			known = append(known, k)
+		}
+	}
+	ids.known = known
+
+	// 距離の近い組から順に同じ顔とみなす
+	type pair struct {
+		face, known int
+		distance    float64
+	}
+	var pairs []pair
+	for i, face := range faces {
+		for j, k := range ids.known {
+			d := math.Hypot(float64(face[0])-k.row, float64(face[1])-k.col)
+			if d <= ids.MaxDistance*math.Max(float64(face[2]), k.scale) {
+				pairs = append(pairs, pair{i, j, d})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool { return pairs[a].distance < pairs[b].distance })
+
+	result := make([]int, len(faces))
+	used := make([]bool, len(ids.known))
+	for _, p := range pairs {
+		if result[p.face] != 0 || used[p.known] {
+			continue
+		}
+		result[p.face] = ids.known[p.known].id
+		used[p.known] = true
+		ids.known[p.known] = identity{result[p.face], float64(faces[p.face][0]), float64(faces[p.face][1]), float64(faces[p.face][2]), now}
+	}
+
+	// 対応する顔がなければ新しい番号を振る
+	for i, face := range faces {
+		if result[i] != 0 {
+			continue
+		}
+		ids.next++
+		result[i] = ids.next
+		ids.known = append(ids.known, identity{ids.next, float64(face[0]), float64(face[1]), float64(face[2]), now})
+	}
+	return result
+}
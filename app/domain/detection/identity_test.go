@@ -0,0 +1,64 @@
+package detection
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestIdentitiesKeepIDsWhenFacesCross(t *testing.T) {
+	ids := NewIdentities()
+	start := time.Unix(0, 0)
+
+	var left, right int
+	for i := 0; i <= 20; i++ {
+		// 左の顔は右へ、右の顔は左へ動いて途中ですれ違う（高さは違う）
+		a := []int{100, 60 + 15*i, 80, 500}
+		b := []int{180, 360 - 15*i, 70, 500}
+		// 検出器は顔を左から順に返すので、すれ違った後は並びが入れ替わる
+		faces := [][]int{a, b}
+		sort.Slice(faces, func(i, j int) bool { return faces[i][1] < faces[j][1] })
+
+		got := ids.Assign(faces, start.Add(time.Duration(i)*33*time.Millisecond))
+		idA, idB := got[0], got[1]
+		if faces[0][0] != a[0] {
+			idA, idB = idB, idA
+		}
+		if i == 0 {
+			left, right = idA, idB
+			if left == right {
+				t.Fatalf("both faces got ID %d", left)
+			}
+			continue
+		}
+		if idA != left || idB != right {
+			t.Fatalf("frame %d: IDs = %d, %d, want %d, %d", i, idA, idB, left, right)
+		}
+	}
+}
+
+func TestIdentitiesExpire(t *testing.T) {
+	ids := NewIdentities()
+	start := time.Unix(0, 0)
+	face := [][]int{{100, 100, 80, 500}}
+
+	first := ids.Assign(face, start)[0]
+	ids.Assign(nil, start.Add(ids.MaxAge/2))
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		same  bool
+	}{
+		{"within MaxAge", ids.MaxAge, true},
+		{"after MaxAge", 2*ids.MaxAge + time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids.Assign(face, start.Add(tt.after))[0]
+			if (got == first) != tt.same {
+				t.Errorf("ID after %v = %d, first ID %d; same = %v, want %v", tt.after, got, first, got == first, tt.same)
+			}
+		})
+	}
+}
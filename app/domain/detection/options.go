@@ -0,0 +1,156 @@
+package detection
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"square-face-tetris/app/constants"
+)
+
+// Options は顔検出のパラメータ
+type Options struct {
+	MinSize     int     `json:"minSize"`     // フレーム全体を探すときの顔の大きさの最小（ピクセル）
+	MaxSize     int     `json:"maxSize"`     // フレーム全体を探すときの顔の大きさの最大（ピクセル）
+	ShiftFactor float64 `json:"shiftFactor"` // 探す窓をずらす幅（顔の大きさに対する割合）
+	ScaleFactor float64 `json:"scaleFactor"` // 探す顔の大きさを大きくしていく倍率
+	IoU         float64 `json:"iou"`         // 重なった検出を 1 つにまとめる IoU のしきい値
+	Perturb     int     `json:"perturb"`     // 瞳とランドマークの検出でずらして試す回数
+
+	MinQuality float64 `json:"minQuality"` // 顔として扱う信頼度（q）の最小
+	// 複数の顔があるときに追跡を始める顔（"largest": 最も大きい, "central": 最も中央に近い, "quality": 最も信頼度が高い）
+	Selection string `json:"selection"`
+	// 見つかったランドマークがこれより少ないフレームは使わない（0 から LandmarkCount）
+	MinLandmarks int `json:"minLandmarks"`
+	// 瞳とランドマークを検出する顔の数（2 以上ではフレーム全体を毎回探す）
+	MaxFaces int `json:"maxFaces"`
+}
+
+// 選択できる顔の選び方
+var Selections = []string{"largest", "central", "quality"}
+
+// ランドマークの点の数
+const LandmarkCount = constants.LANDMARK_COUNT
+
+// 設定できる値の範囲
+const (
+	MinFaceSize = 20
+	MaxFaceSize = 2000
+	MaxPerturb  = 127
+	MaxFaces    = 4
+)
+
+// DefaultOptions は顔検出のパラメータの初期値を返す
+func DefaultOptions() Options {
+	return Options{
+		MinSize:      200,
+		MaxSize:      480,
+		ShiftFactor:  0.1,
+		ScaleFactor:  1.1,
+		IoU:          0.1,
+		Perturb:      63,
+		MinQuality:   5,
+		Selection:    "largest",
+		MinLandmarks: LandmarkCount,
+		MaxFaces:     1,
+	}
+}
+
+// Validate は範囲外の値を初期値に戻す
+// 戻した値があればその内容をエラーとして返す
+func (o *Options) Validate() error {
+	d := DefaultOptions()
+	var errs []error
+	invalid := func(name string, value interface{}) {
+		errs = append(errs, fmt.Errorf("detector.%s の値が不正なため初期値に戻しました: %v", name, value))
+	}
+
+	if o.MinSize < MinFaceSize || o.MinSize > MaxFaceSize {
+		invalid("minSize", o.MinSize)
+		o.MinSize = d.MinSize
+	}
+	if o.MaxSize < o.MinSize || o.MaxSize > MaxFaceSize {
+		invalid("maxSize", o.MaxSize)
+		o.MaxSize = int(math.Max(float64(d.MaxSize), float64(o.MinSize)))
+	}
+	if o.ShiftFactor <= 0 || o.ShiftFactor > 1 {
+		invalid("shiftFactor", o.ShiftFactor)
+		o.ShiftFactor = d.ShiftFactor
+	}
+	if o.ScaleFactor <= 1 || o.ScaleFactor > 2 {
+		invalid("scaleFactor", o.ScaleFactor)
+		o.ScaleFactor = d.ScaleFactor
+	}
+	if o.IoU < 0 || o.IoU > 1 {
+		invalid("iou", o.IoU)
+		o.IoU = d.IoU
+	}
+	if o.Perturb < 1 || o.Perturb > MaxPerturb {
+		invalid("perturb", o.Perturb)
+		o.Perturb = d.Perturb
+	}
+	if o.MinQuality < 0 {
+		invalid("minQuality", o.MinQuality)
+		o.MinQuality = d.MinQuality
+	}
+	if !contains(Selections, o.Selection) {
+		invalid("selection", o.Selection)
+		o.Selection = d.Selection
+	}
+	if o.MinLandmarks < 0 || o.MinLandmarks > LandmarkCount {
+		invalid("minLandmarks", o.MinLandmarks)
+		o.MinLandmarks = d.MinLandmarks
+	}
+	if o.MaxFaces < 1 || o.MaxFaces > MaxFaces {
+		invalid("maxFaces", o.MaxFaces)
+		o.MaxFaces = d.MaxFaces
+	}
+	return errors.Join(errs...)
+}
+
+// Select は信頼度が MinQuality に満たない顔を除き、Selection の順に並べた顔を返す
+// faces は [row, col, scale, q]、width と height はフレームの大きさ
+func (o Options) Select(faces [][]int, width, height int) [][]int {
+	selected := make([][]int, 0, len(faces))
+	for _, face := range faces {
+		if float64(face[3]) >= o.MinQuality {
+			selected = append(selected, face)
+		}
+	}
+
+	var less func(a, b []int) bool
+	switch o.Selection {
+	case "central":
+		distance := func(face []int) float64 {
+			return math.Hypot(float64(face[0]-height/2), float64(face[1]-width/2))
+		}
+		less = func(a, b []int) bool { return distance(a) < distance(b) }
+	case "quality":
+		less = func(a, b []int) bool { return a[3] > b[3] }
+	default:
+		less = func(a, b []int) bool { return a[2] > b[2] }
+	}
+	sort.SliceStable(selected, func(i, j int) bool { return less(selected[i], selected[j]) })
+	return selected
+}
+
+// CountLandmarks は見つかったランドマークの点の数を返す
+func CountLandmarks(landmarks [][]int) int {
+	n := 0
+	for _, p := range landmarks {
+		if len(p) >= 2 {
+			n++
+		}
+	}
+	return n
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
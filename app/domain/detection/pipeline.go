@@ -0,0 +1,114 @@
+package detection
+
+import "time"
+
+// Pipeline は goroutine で顔を検出する Runner
+type Pipeline struct {
+	frames  chan Frame    // 検出を待っているフレーム（最新の 1 つだけ）
+	options chan Options  // 次のフレームから使うパラメータ（最新の 1 つだけ）
+	results chan Result   // 検出の結果
+	buffers chan []byte   // 使い終わった画素のバッファ
+	done    chan struct{} // Close で閉じる
+}
+
+// NewPipeline は検出する goroutine を起動する
+// init は goroutine の中で検出器を作る（カスケードの読み込みなど、時間のかかる準備をここで行う）
+// 準備に失敗した場合は onError を呼び、検出しない
+func NewPipeline(init func() (Detector, error), onError func(error)) *Pipeline {
+	p := &Pipeline{
+		frames:  make(chan Frame, 1),
+		options: make(chan Options, 1),
+		results: make(chan Result, ResultBuffer),
+		buffers: make(chan []byte, 2),
+		done:    make(chan struct{}),
+	}
+	go p.run(init, onError)
+	return p
+}
+
+func (p *Pipeline) run(init func() (Detector, error), onError func(error)) {
+	defer close(p.results)
+
+	d, err := init()
+	if err != nil {
+		if onError != nil {
+			onError(err)
+		}
+		return
+	}
+
+	for {
+		select {
+		case o := <-p.options:
+			d.Configure(o)
+		case f := <-p.frames:
+			start := time.Now()
+			r := d.Detect(f)
+			r.Time = f.Time
+			r.Duration = time.Since(start)
+			p.recycle(f.Pixels)
+			Send(p.results, r)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Buffer は使い終わったバッファがあれば再利用し、なければ新しく作る
+func (p *Pipeline) Buffer(n int) []byte {
+	select {
+	case b := <-p.buffers:
+		if cap(b) >= n {
+			return b[:n]
+		}
+	default:
+	}
+	return make([]byte, n)
+}
+
+// Submit はフレームを検出の待ちに入れる。既に待っているフレームは捨てる
+func (p *Pipeline) Submit(f Frame) {
+	for {
+		select {
+		case p.frames <- f:
+			return
+		default:
+		}
+		// 待っている古いフレームを取り除いてから入れ直す
+		select {
+		case stale := <-p.frames:
+			p.recycle(stale.Pixels)
+		default:
+		}
+	}
+}
+
+// Configure はパラメータを検出の goroutine に渡す。まだ渡していないパラメータは捨てる
+func (p *Pipeline) Configure(o Options) {
+	for {
+		select {
+		case p.options <- o:
+			return
+		default:
+		}
+		select {
+		case <-p.options:
+		default:
+		}
+	}
+}
+
+func (p *Pipeline) Results() <-chan Result {
+	return p.results
+}
+
+func (p *Pipeline) Close() {
+	close(p.done)
+}
+
+func (p *Pipeline) recycle(b []byte) {
+	select {
+	case p.buffers <- b:
+	default:
+	}
+}
@@ -0,0 +1,94 @@
+package detection
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// gatedDetector は release に値が届くまで Detect から戻らない検出器
+type gatedDetector struct {
+	started chan time.Time // 検出を始めたフレームの時刻
+	release chan struct{}
+}
+
+func newGatedDetector() *gatedDetector {
+	return &gatedDetector{started: make(chan time.Time, 8), release: make(chan struct{})}
+}
+
+func (d *gatedDetector) Configure(o Options) {}
+
+func (d *gatedDetector) Detect(f Frame) Result {
+	d.started <- f.Time
+	<-d.release
+	return Result{}
+}
+
+func frameAt(p *Pipeline, sec int) Frame {
+	return Frame{Time: time.Unix(int64(sec), 0), Pixels: p.Buffer(16), Width: 4, Height: 4}
+}
+
+func receiveResult(t *testing.T, p *Pipeline) (Result, bool) {
+	t.Helper()
+	select {
+	case r, ok := <-p.Results():
+		return r, ok
+	case <-time.After(5 * time.Second):
+		t.Fatal("no result within 5s")
+		return Result{}, false
+	}
+}
+
+func TestPipelineDropsStaleFrames(t *testing.T) {
+	d := newGatedDetector()
+	p := NewPipeline(func() (Detector, error) { return d, nil }, nil)
+
+	// 1 つ目を検出している間に 2 つ目と 3 つ目を入れると、待っていた 2 つ目は捨てられる
+	p.Submit(frameAt(p, 1))
+	<-d.started
+	stale := frameAt(p, 2)
+	p.Submit(stale)
+	p.Submit(frameAt(p, 3))
+
+	// 捨てたフレームのバッファは再利用される
+	if b := p.Buffer(16); &b[0] != &stale.Pixels[0] {
+		t.Error("Buffer did not reuse the dropped frame's pixels")
+	}
+
+	for _, want := range []int64{1, 3} {
+		if want != 1 {
+			if sec := <-d.started; sec.Unix() != want {
+				t.Fatalf("detecting frame %d, want frame %d", sec.Unix(), want)
+			}
+		}
+		d.release <- struct{}{}
+		r, ok := receiveResult(t, p)
+		if !ok || r.Time.Unix() != want {
+			t.Fatalf("result for frame %d, %v; want frame %d", r.Time.Unix(), ok, want)
+		}
+	}
+	select {
+	case sec := <-d.started:
+		t.Errorf("detected frame %d after the latest one", sec.Unix())
+	default:
+	}
+
+	p.Close()
+	if r, ok := receiveResult(t, p); ok {
+		t.Errorf("received %+v after Close, want Results to be closed", r)
+	}
+}
+
+func TestPipelineInitError(t *testing.T) {
+	errInit := errors.New("cascade not found")
+	reported := make(chan error, 1)
+	p := NewPipeline(func() (Detector, error) { return nil, errInit }, func(err error) { reported <- err })
+	defer p.Close()
+
+	if _, ok := receiveResult(t, p); ok {
+		t.Error("received a result from a pipeline without a detector")
+	}
+	if err := <-reported; err != errInit {
+		t.Errorf("onError got %v, want %v", err, errInit)
+	}
+}
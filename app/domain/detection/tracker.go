@@ -0,0 +1,182 @@
+package detection
+
+import (
+	"math"
+	"time"
+)
+
+// Region は顔を探す範囲と大きさ
+type Region struct {
+	Top, Left     int
+	Height, Width int
+	MinSize       int  // 探す顔の大きさの最小
+	MaxSize       int  // 探す顔の大きさの最大
+	Full          bool // フレーム全体を探すか
+}
+
+// FullRegion はフレーム全体を minSize から maxSize の大きさで探す範囲を返す
+func FullRegion(width, height, minSize, maxSize int) Region {
+	return Region{Height: height, Width: width, MinSize: minSize, MaxSize: maxSize, Full: true}
+}
+
+// Tracker は前のフレームで見つけた顔の周り（ROI）だけを近い大きさで探し、見失ったらフレーム全体を探し直す
+// 追跡している顔の位置と大きさは alpha-beta フィルタで平滑化する
+type Tracker struct {
+	Padding        float64 // ROI を顔の大きさの何倍ずつ上下左右に広げるか
+	ScaleRange     float64 // 前の顔の大きさから探す大きさの幅（0.25 なら ±25%）
+	RescanInterval int     // ROI で見つけ続けていても、この回数ごとにフレーム全体を探す（0 なら探さない）
+
+	row, col, scale AlphaBeta
+	tracking        bool
+	last            time.Time // 最後に顔を見つけたフレームの時刻
+	scans           int       // 前にフレーム全体を探してからの回数
+}
+
+// NewTracker は初期値の Tracker を作る
+func NewTracker() *Tracker {
+	return &Tracker{
+		Padding:        0.5,
+		ScaleRange:     0.25,
+		RescanInterval: 30,
+		row:            AlphaBeta{Alpha: 0.6, Beta: 0.1},
+		col:            AlphaBeta{Alpha: 0.6, Beta: 0.1},
+		scale:          AlphaBeta{Alpha: 0.4, Beta: 0.05},
+	}
+}
+
+// Tracking は顔を追跡しているか
+func (t *Tracker) Tracking() bool {
+	return t.tracking
+}
+
+// Region は now のフレームで顔を探す範囲を返す
+// 追跡していないときや定期的に探し直すときは、フレーム全体を minSize から maxSize の大きさで探す
+func (t *Tracker) Region(now time.Time, width, height, minSize, maxSize int) Region {
+	full := FullRegion(width, height, minSize, maxSize)
+	if !t.tracking || (t.RescanInterval > 0 && t.scans >= t.RescanInterval) {
+		return full
+	}
+
+	// 顔が動いている向きに合わせて、今の位置を予測する
+	dt := now.Sub(t.last).Seconds()
+	row, col, size := t.row.Predict(dt), t.col.Predict(dt), t.scale.Predict(dt)
+
+	r := Region{
+		MinSize: clamp(int(size*(1-t.ScaleRange)), minSize, maxSize),
+		MaxSize: clamp(int(math.Ceil(size*(1+t.ScaleRange))), minSize, maxSize),
+	}
+	// 探す大きさの最大の顔が収まるよう、少なくとも MaxSize の半分は広げる
+	half := math.Max(size/2+size*t.Padding, float64(r.MaxSize)/2+1)
+	top, left := clamp(int(row-half), 0, height), clamp(int(col-half), 0, width)
+	bottom, right := clamp(int(row+half), 0, height), clamp(int(col+half), 0, width)
+	r.Top, r.Left, r.Height, r.Width = top, left, bottom-top, right-left
+	if r.Height <= r.MinSize || r.Width <= r.MinSize {
+		return full
+	}
+	return r
+}
+
+// Update は region で見つけた顔（[row, col, scale, q]）を受け取り、追跡している顔を平滑化して先頭にした結果を返す
+// 顔が見つからなければ追跡をやめ、次はフレーム全体を探す
+func (t *Tracker) Update(now time.Time, region Region, faces [][]int) [][]int {
+	if region.Full {
+		t.scans = 0
+	} else {
+		t.scans++
+	}
+	if len(faces) == 0 {
+		t.Reset()
+		return faces
+	}
+
+	dt := now.Sub(t.last).Seconds()
+	i := 0
+	if t.tracking {
+		i = t.nearest(faces, dt)
+	} else {
+		t.row.Reset()
+		t.col.Reset()
+		t.scale.Reset()
+	}
+	face := faces[i]
+
+	smoothed := []int{
+		int(math.Round(t.row.Update(float64(face[0]), dt))),
+		int(math.Round(t.col.Update(float64(face[1]), dt))),
+		int(math.Round(t.scale.Update(float64(face[2]), dt))),
+		face[3],
+	}
+	t.tracking = true
+	t.last = now
+
+	result := make([][]int, 0, len(faces))
+	result = append(result, smoothed)
+	result = append(result, faces[:i]...)
+	return append(result, faces[i+1:]...)
+}
+
+// Reset は追跡をやめる
+func (t *Tracker) Reset() {
+	t.tracking = false
+	t.scans = 0
+}
+
+// nearest は予測した位置に最も近い顔の添字を返す
+func (t *Tracker) nearest(faces [][]int, dt float64) int {
+	row, col := t.row.Predict(dt), t.col.Predict(dt)
+	nearest, min := 0, math.Inf(1)
+	for i, face := range faces {
+		d := math.Hypot(float64(face[0])-row, float64(face[1])-col)
+		if d < min {
+			nearest, min = i, d
+		}
+	}
+	return nearest
+}
+
+// AlphaBeta は 1 つの値を平滑化する alpha-beta フィルタ
+// Alpha は位置、Beta は速度をどれだけ観測値に合わせるか（0 から 1、大きいほど反応が速くブレやすい）
+type AlphaBeta struct {
+	Alpha, Beta float64
+
+	x, v   float64 // 推定した値と、1 秒あたりの変化
+	inited bool
+}
+
+// Update は観測値 z で推定を更新する。dt は前の観測からの秒数
+func (f *AlphaBeta) Update(z, dt float64) float64 {
+	if !f.inited {
+		f.x, f.v, f.inited = z, 0, true
+		return f.x
+	}
+	predicted := f.Predict(dt)
+	residual := z - predicted
+	f.x = predicted + f.Alpha*residual
+	if dt > 0 {
+		f.v += f.Beta * residual / dt
+	}
+	return f.x
+}
+
+// Predict は dt 秒後の値を予測する
+func (f *AlphaBeta) Predict(dt float64) float64 {
+	if dt <= 0 {
+		return f.x
+	}
+	return f.x + f.v*dt
+}
+
+// Reset は推定を捨て、次の観測値から始め直す
+func (f *AlphaBeta) Reset() {
+	f.inited = false
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
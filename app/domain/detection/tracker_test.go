@@ -0,0 +1,83 @@
+package detection
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTrackerRegion(t *testing.T) {
+	const width, height, minSize, maxSize = 640, 480, 40, 400
+	face := []int{200, 300, 80, 900}
+
+	tr := NewTracker()
+	tr.RescanInterval = 3
+	start := time.Unix(0, 0)
+
+	// 見つけ続けている間は ROI を探し、RescanInterval 回ごとにフレーム全体を探す
+	want := []bool{true, false, false, false, true, false, false, false, true}
+	for i, full := range want {
+		now := start.Add(time.Duration(i) * 33 * time.Millisecond)
+		r := tr.Region(now, width, height, minSize, maxSize)
+		if r.Full != full {
+			t.Fatalf("frame %d: Full = %v, want %v (%+v)", i, r.Full, full, r)
+		}
+		if !r.Full {
+			if r.Top > face[0]-face[2]/2 || r.Left > face[1]-face[2]/2 ||
+				r.Top+r.Height < face[0]+face[2]/2 || r.Left+r.Width < face[1]+face[2]/2 {
+				t.Errorf("frame %d: region %+v does not contain the face %v", i, r, face)
+			}
+			if r.MinSize > face[2] || r.MaxSize < face[2] || r.MinSize <= minSize || r.MaxSize >= maxSize {
+				t.Errorf("frame %d: sizes %d-%d, want a range around %d", i, r.MinSize, r.MaxSize, face[2])
+			}
+		}
+		tr.Update(now, r, [][]int{face})
+	}
+
+	// 見失ったら、次はフレーム全体を探す
+	now := start.Add(time.Second)
+	r := tr.Region(now, width, height, minSize, maxSize)
+	if r.Full {
+		t.Fatal("expected an ROI while tracking")
+	}
+	tr.Update(now, r, nil)
+	if tr.Tracking() {
+		t.Error("still tracking after losing the face")
+	}
+	if r := tr.Region(now.Add(33*time.Millisecond), width, height, minSize, maxSize); !r.Full {
+		t.Errorf("Region after losing the face = %+v, want a full scan", r)
+	}
+}
+
+func TestAlphaBetaConverges(t *testing.T) {
+	const dt = 0.05
+	tests := []struct {
+		name   string
+		signal func(t float64) float64
+	}{
+		{"constant", func(float64) float64 { return 120 }},
+		{"step", func(t float64) float64 {
+			if t < 1 {
+				return 0
+			}
+			return 50
+		}},
+		{"ramp", func(t float64) float64 { return 30 + 80*t }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := AlphaBeta{Alpha: 0.6, Beta: 0.1}
+			var x float64
+			for i := 0; i < 200; i++ {
+				x = f.Update(tt.signal(float64(i)*dt), dt)
+			}
+			end := float64(199) * dt
+			if got, want := x, tt.signal(end); math.Abs(got-want) > 0.5 {
+				t.Errorf("estimate = %v, want %v", got, want)
+			}
+			if got, want := f.Predict(dt), tt.signal(end+dt); math.Abs(got-want) > 0.5 {
+				t.Errorf("prediction = %v, want %v", got, want)
+			}
+		})
+	}
+}
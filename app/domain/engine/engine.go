@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"math/rand"
+	"time"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/input"
+)
+
+// 1 秒あたりのフレーム数（ebiten の既定の TPS と同じ）
+const TPS = 60
+
+// Options はゲームのルールの設定
+type Options struct {
+	DropInterval time.Duration `json:"dropInterval"` // 落下間隔
+	TimeLimit    time.Duration `json:"timeLimit"`    // タイムリミット
+
+	// ボードの大きさ（0 の場合は constants.BoardWidth / constants.BoardHeight）
+	BoardWidth  int `json:"boardWidth,omitempty"`
+	BoardHeight int `json:"boardHeight,omitempty"`
+
+	// 次のテトリミノを選ぶ表情（constants.SMILE など）
+	// Next[i+1] が ChoiceEmotions[i] に対応する候補になる
+	ChoiceEmotions []int `json:"choiceEmotions"`
+
+	// 次のテトリミノの選び方（RandomizerEmotion など）
+	// 表情を使わない選び方では、Next は先に出る順に並んだテトリミノになる
+	Randomizer string `json:"randomizer,omitempty"`
+}
+
+// 次のテトリミノを選ぶ表情の初期値
+var DefaultChoiceEmotions = []int{
+	constants.SMILE,
+	constants.ANGRY,
+	constants.SURPRISED,
+	constants.SUS,
+}
+
+var DefaultOptions = Options{
+	DropInterval:   2 * time.Second,
+	TimeLimit:      3 * time.Minute,
+	ChoiceEmotions: DefaultChoiceEmotions,
+}
+
+// Engine はテトリスのルールを進める
+//
+// 時間はフレーム数で数え、乱数はシードから作るため、
+// 同じシード・同じ入力を与えれば必ず同じ結果になる
+type Engine struct {
+	Options
+	Seed int64
+
+	Board       domain.Board // 10x20 のボード
+	Current     *Tetromino   // 現在のテトリミノ
+	Next        []*Tetromino // 次のテトリミノ
+	Hold        *Tetromino   // ホールド中のテトリミノ
+	HoldUsed    bool         // 現在のテトリミノでホールドを使ったか
+	Score       int          // スコア
+	Lines       int          // 消した行数
+	Frame       int          // 開始からのフレーム数
+	Over        bool         // ゲームが終了したか
+	DrawedEmote string       // 次のテトリミノを選んだ表情
+	Stats       Stats        // プレイの統計
+	Events      []GameEvent  // 行を消したなどの出来事（PopEvents で取り出す）
+
+	// 相手から送られてきて、まだせり上がっていないおじゃま行の数（対戦のとき）
+	IncomingGarbage int
+
+	rand       *rand.Rand
+	dropFrames int   // 最後に落下してからのフレーム数
+	lockNow    bool  // このフレームで固定する（ハードドロップ）
+	bag        []int // RandomizerBag で、今の組の残りのテトリミノ（Tetrominos の番号）
+	piece      pieceRecord
+	nearDeath  bool // 危険な高さまで積み上がっている
+
+	outgoingGarbage int // 相手へ送るおじゃま行の数（PopAttack で取り出す）
+}
+
+// New はシードと設定から新しいゲームを始める
+func New(seed int64, opts Options) *Engine {
+	if len(opts.ChoiceEmotions) == 0 {
+		opts.ChoiceEmotions = DefaultChoiceEmotions
+	}
+	e := &Engine{
+		Options: opts,
+		Seed:    seed,
+		Stats:   newStats(),
+		rand:    rand.New(rand.NewSource(seed)),
+	}
+
+	e.Board.Init(e.BoardWidth, e.BoardHeight)
+	e.Next = make([]*Tetromino, 1+len(e.ChoiceEmotions))
+	if !e.usesEmotions() {
+		for i := range e.Next {
+			e.Next[i] = e.randomTetromino()
+		}
+		return e
+	}
+	// Next[0] と、表情ごとの候補 Next[1:] を生成
+	e.Next[0] = e.GenerateRandomTetromino()
+	copy(e.Next[1:], e.GenerateUniqueTetrominos(len(e.ChoiceEmotions)))
+	return e
+}
+
+// Step はゲームを 1 フレーム進める
+// emotionIndexes は現在判定されている表情で、次のテトリミノを選ぶときに使う
+func (e *Engine) Step(actions []input.Action, emotionIndexes []int) {
+	if e.Over {
+		return
+	}
+
+	// タイムリミットを超えている場合は終了
+	e.Frame++
+	if e.Frame >= Frames(e.TimeLimit) {
+		e.Over = true
+		return
+	}
+
+	if e.Current == nil {
+		e.ShiftTetrominoQueue(emotionIndexes)
+	}
+	e.Stats.recordEmotions(emotionIndexes)
+
+	// 同じ入力から必ず同じ結果になるよう、操作は input.Actions の順に 1 回ずつ適用する
+	for _, action := range input.Actions {
+		if hasAction(actions, action) {
+			e.ApplyAction(action)
+		}
+	}
+
+	// ホールドで現在のテトリミノが空になった場合は次を取り出す
+	if e.Current == nil {
+		e.ShiftTetrominoQueue(emotionIndexes)
+	}
+
+	// 一定間隔で落下
+	e.dropFrames++
+	if e.dropFrames > Frames(e.DropInterval) || e.lockNow {
+		if !e.lockNow && e.IsValidPosition(e.Current, 0, 1) {
+			e.Current.Y += 1
+		} else {
+			// テトリミノが固定されるべき条件を満たす
+			e.LockTetromino()
+
+			// 最上段にブロックがあるか確認（ゲームオーバーの判定）
+			if e.isTopRowFilled() {
+				e.Over = true
+			}
+		}
+		e.dropFrames = 0
+		e.lockNow = false
+	}
+}
+
+// RemainingTime はタイムリミットまでの残り時間を返す
+func (e *Engine) RemainingTime() time.Duration {
+	remaining := e.TimeLimit - Duration(e.Frame)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// 何行消すごとにレベルが上がるか
+const linesPerLevel = 10
+
+// Level は消した行数から求めたレベルを返す（1 から始まる）
+func (e *Engine) Level() int {
+	return e.Lines/linesPerLevel + 1
+}
+
+// Elapsed は開始からの経過時間を返す
+func (e *Engine) Elapsed() time.Duration {
+	return Duration(e.Frame)
+}
+
+// 最上段が埋まっているか確認
+func (e *Engine) isTopRowFilled() bool {
+	topRow := e.Board[0] // 最上段の行を取得
+	for _, cell := range topRow {
+		if cell != 0 { // 0 以外ならブロックがある
+			return true
+		}
+	}
+	return false
+}
+
+func hasAction(actions []input.Action, action input.Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Frames は時間をフレーム数に変換する
+func Frames(d time.Duration) int {
+	return int(d * TPS / time.Second)
+}
+
+// Duration はフレーム数を時間に変換する
+func Duration(frames int) time.Duration {
+	return time.Duration(frames) * time.Second / TPS
+}
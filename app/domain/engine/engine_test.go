@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"testing"
+
+	"square-face-tetris/app/domain/input"
+)
+
+func topRowEmpty(e *Engine) bool {
+	for _, cell := range e.Board[0] {
+		if cell != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ハードドロップとホールドを同じフレームに押しても、次のテトリミノが出現した位置で固定されない
+func TestHardDropAndHoldInSameFrame(t *testing.T) {
+	both := []input.Action{input.HardDrop, input.Hold}
+
+	// ホールドが空のとき（次のテトリミノを取り出す）
+	e := New(1, DefaultOptions)
+	e.Step(both, nil)
+	if e.Over || !topRowEmpty(e) {
+		t.Fatalf("Over = %v, top row = %v after the first hold", e.Over, e.Board[0])
+	}
+	if e.Hold == nil || e.Current == nil {
+		t.Fatalf("Hold = %v, Current = %v, want both set", e.Hold, e.Current)
+	}
+
+	// ホールドと入れ替えるとき
+	e.Step([]input.Action{input.HardDrop}, nil)
+	e.Step(nil, nil)
+	if e.HoldUsed {
+		t.Fatal("HoldUsed is still set after locking a piece")
+	}
+	held := e.Hold
+	e.Step(both, nil)
+	if e.Over || !topRowEmpty(e) {
+		t.Fatalf("Over = %v, top row = %v after swapping with the hold", e.Over, e.Board[0])
+	}
+	if e.Current != held || e.Current.Y != 0 {
+		t.Errorf("Current = %v (Y = %d), want the held piece at the top", e.Current, e.Current.Y)
+	}
+}
+
+// 同じシード・同じ入力なら同じ状態になる
+func TestStepIsDeterministic(t *testing.T) {
+	run := func() *Engine {
+		e := New(42, DefaultOptions)
+		for i := 0; i < 2000 && !e.Over; i++ {
+			var actions []input.Action
+			switch i % 7 {
+			case 0:
+				actions = []input.Action{input.MoveLeft}
+			case 2:
+				actions = []input.Action{input.RotateCW, input.MoveRight}
+			case 5:
+				actions = []input.Action{input.HardDrop}
+			}
+			e.Step(actions, []int{i / 50 % 4})
+		}
+		return e
+	}
+	a, b := run(), run()
+	if a.Hash() != b.Hash() || a.Score != b.Score || a.Frame != b.Frame {
+		t.Errorf("runs differ: hash %x / %x, score %d / %d, frame %d / %d", a.Hash(), b.Hash(), a.Score, b.Score, a.Frame, b.Frame)
+	}
+}
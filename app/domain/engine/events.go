@@ -0,0 +1,50 @@
+package engine
+
+// ゲーム中の出来事の種類
+const (
+	EventLineClear = "lineClear" // 行を消した
+	EventNearDeath = "nearDeath" // ブロックが上端の近くまで積み上がった
+)
+
+// 上から何行以内にブロックがあれば危険とみなすか
+const nearDeathRows = 4
+
+// GameEvent はゲーム中の出来事
+type GameEvent struct {
+	Frame int    `json:"frame"`
+	Type  string `json:"type"`
+	Lines int    `json:"lines,omitempty"` // 消した行数（EventLineClear のとき）
+}
+
+// PopEvents は溜まっている出来事を取り出す
+func (e *Engine) PopEvents() []GameEvent {
+	events := e.Events
+	e.Events = nil
+	return events
+}
+
+// recordEvents はテトリミノを固定したときの出来事を記録する
+func (e *Engine) recordEvents(cleared int) {
+	if cleared > 0 {
+		e.Events = append(e.Events, GameEvent{Frame: e.Frame, Type: EventLineClear, Lines: cleared})
+	}
+
+	// 危険な高さになったときに 1 回だけ記録し、下がったら再び記録できるようにする
+	danger := e.stackHeight() > e.Board.Height()-nearDeathRows
+	if danger && !e.nearDeath {
+		e.Events = append(e.Events, GameEvent{Frame: e.Frame, Type: EventNearDeath})
+	}
+	e.nearDeath = danger
+}
+
+// stackHeight は積み上がったブロックの高さ（行数）を返す
+func (e *Engine) stackHeight() int {
+	for y, row := range e.Board {
+		for _, cell := range row {
+			if cell != 0 {
+				return e.Board.Height() - y
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package engine
+
+// 1 回の固定でせり上がるおじゃま行の最大数（残りは次の固定に回す）
+const maxGarbagePerLock = 8
+
+// 同時に消した行数ごとに相手へ送る行数（添字 1: シングル ... 4: テトリス）
+var attackTable = [5]int{0, 0, 1, 2, 4}
+
+// AddGarbage は相手から送られてきたおじゃま行を受け取る
+// 受け取った行はテトリミノを固定したときに、行を消していなければせり上がる
+func (e *Engine) AddGarbage(lines int) {
+	e.IncomingGarbage += lines
+}
+
+// PopAttack は相手へ送る行数を取り出す
+func (e *Engine) PopAttack() int {
+	attack := e.outgoingGarbage
+	e.outgoingGarbage = 0
+	return attack
+}
+
+// ExchangeGarbage は 2 人のエンジンの間で、相手へ送る行数をやり取りする
+// 対戦では両方のエンジンを Step した後に毎フレーム呼ぶ
+func ExchangeGarbage(a, b *Engine) {
+	attackA, attackB := a.PopAttack(), b.PopAttack()
+	b.AddGarbage(attackA)
+	a.AddGarbage(attackB)
+}
+
+// exchangeGarbage はテトリミノを固定したときに、消した行数から攻撃を計算する
+// 攻撃は受け取っているおじゃま行の相殺に先に使い、残りを相手へ送る
+func (e *Engine) exchangeGarbage(cleared int, tSpin bool) {
+	if cleared == 0 {
+		e.raiseGarbage()
+		return
+	}
+
+	attack := attackTable[cleared]
+	if tSpin {
+		attack = cleared * 2
+	}
+	// 3 回目以降の連続した消去には 2 回ごとに 1 行を加える
+	if bonus := (e.Stats.combo - 1) / 2; bonus > 0 {
+		attack += bonus
+	}
+
+	cancel := attack
+	if cancel > e.IncomingGarbage {
+		cancel = e.IncomingGarbage
+	}
+	e.IncomingGarbage -= cancel
+	e.outgoingGarbage += attack - cancel
+}
+
+// raiseGarbage は受け取っているおじゃま行をボードの下からせり上げる
+// おじゃま行は 1 回にせり上がる分ごとに同じ列が 1 マス空いている
+func (e *Engine) raiseGarbage() {
+	lines := e.IncomingGarbage
+	if lines > maxGarbagePerLock {
+		lines = maxGarbagePerLock
+	}
+	if lines == 0 {
+		return
+	}
+	e.IncomingGarbage -= lines
+
+	width := e.Board.Width()
+	hole := e.rand.Intn(width)
+	for i := 0; i < lines; i++ {
+		// 上端からはみ出すブロックがあればゲームオーバー
+		for _, cell := range e.Board[0] {
+			if cell != 0 {
+				e.Over = true
+			}
+		}
+
+		row := make([]int, width)
+		for x := range row {
+			if x != hole {
+				row[x] = 1
+			}
+		}
+		copy(e.Board, e.Board[1:])
+		e.Board[len(e.Board)-1] = row
+	}
+}
@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// Hash はゲームの状態のハッシュ値を返す
+// 同じシード・同じ入力で進めたエンジンは同じ値になるため、通信対戦の同期ずれの検出に使う
+func (e *Engine) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	write := func(v int) {
+		binary.LittleEndian.PutUint64(buf[:], uint64(v))
+		h.Write(buf[:])
+	}
+
+	write(e.Frame)
+	write(e.Score)
+	write(e.Lines)
+	write(e.IncomingGarbage)
+	for _, row := range e.Board {
+		for _, cell := range row {
+			write(cell)
+		}
+	}
+	for _, t := range append([]*Tetromino{e.Current, e.Hold}, e.Next...) {
+		if t == nil {
+			write(-1)
+			continue
+		}
+		h.Write([]byte(t.Name))
+		write(t.X)
+		write(t.Y)
+		write(t.Rotation)
+	}
+	for _, i := range e.bag {
+		write(i)
+	}
+	return h.Sum64()
+}
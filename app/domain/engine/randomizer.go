@@ -0,0 +1,40 @@
+package engine
+
+// 次のテトリミノの選び方（Options.Randomizer）
+const (
+	RandomizerEmotion = ""        // 表情で候補から選ぶ（初期値。不明な値もこれとして扱う）
+	RandomizerBag     = "bag"     // 7 種類を 1 組にして、組ごとにランダムな順番で出す（表情は使わない）
+	RandomizerUniform = "uniform" // 毎回 7 種類から等確率で選ぶ（表情は使わない）
+)
+
+// usesEmotions は表情で次のテトリミノを選ぶかを返す
+func (e *Engine) usesEmotions() bool {
+	return e.Randomizer != RandomizerBag && e.Randomizer != RandomizerUniform
+}
+
+// randomTetromino は表情を使わない選び方で、次に出すテトリミノを 1 つ作る
+func (e *Engine) randomTetromino() *Tetromino {
+	if e.Randomizer != RandomizerBag {
+		return e.GenerateRandomTetromino()
+	}
+	if len(e.bag) == 0 {
+		e.bag = e.rand.Perm(len(Tetrominos))
+	}
+	index := e.bag[0]
+	e.bag = e.bag[1:]
+	return &Tetromino{
+		Name:  Tetrominos[index].Name,
+		Color: Tetrominos[index].Color,
+		Shape: append([][]int{}, Tetrominos[index].Shape...),
+	}
+}
+
+// shiftRandomQueue は表情を使わない選び方で、Next[0] を現在のテトリミノにし、
+// 残りを 1 つずつ前に詰めて最後に新しいテトリミノを加える
+func (e *Engine) shiftRandomQueue() {
+	e.Current = e.Next[0]
+	copy(e.Next, e.Next[1:])
+	e.Next[len(e.Next)-1] = e.randomTetromino()
+	e.DrawedEmote = ""
+	e.spawn()
+}
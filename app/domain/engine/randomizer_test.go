@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"square-face-tetris/app/constants"
+)
+
+// sequence は表情 emotions を与えながら、出てくるテトリミノを n 個並べる
+func sequence(randomizer string, seed int64, n int, emotions []int) []string {
+	opts := DefaultOptions
+	opts.Randomizer = randomizer
+	e := New(seed, opts)
+	names := make([]string, n)
+	for i := range names {
+		e.ShiftTetrominoQueue(emotions)
+		names[i] = e.Current.Name
+	}
+	return names
+}
+
+func TestRandomizers(t *testing.T) {
+	const n = 7 * 100
+	tests := []struct {
+		randomizer string
+		check      func(names []string) error
+	}{
+		{
+			randomizer: RandomizerBag,
+			// 7 個ごとに全ての種類が 1 回ずつ出る
+			check: func(names []string) error {
+				for i := 0; i < len(names); i += len(Tetrominos) {
+					seen := map[string]bool{}
+					for _, name := range names[i : i+len(Tetrominos)] {
+						seen[name] = true
+					}
+					if len(seen) != len(Tetrominos) {
+						return fmt.Errorf("bag %d = %v, want every piece once", i/len(Tetrominos), names[i:i+len(Tetrominos)])
+					}
+				}
+				return nil
+			},
+		},
+		{
+			randomizer: RandomizerUniform,
+			// 全ての種類が出る（1 種類あたり平均 100 回）
+			check: func(names []string) error {
+				counts := map[string]int{}
+				for _, name := range names {
+					counts[name]++
+				}
+				for _, tetromino := range Tetrominos {
+					if counts[tetromino.Name] < 50 {
+						return fmt.Errorf("%s appeared %d times in %d pieces", tetromino.Name, counts[tetromino.Name], len(names))
+					}
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.randomizer, func(t *testing.T) {
+			names := sequence(tt.randomizer, 7, n, nil)
+			if err := tt.check(names); err != nil {
+				t.Error(err)
+			}
+
+			// 表情を使わないので、表情を変えても同じ順番になる
+			smiling := sequence(tt.randomizer, 7, n, []int{constants.SMILE})
+			for i := range names {
+				if names[i] != smiling[i] {
+					t.Fatalf("piece %d = %s with a smile, %s without; want the emotions to be ignored", i, smiling[i], names[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain/input"
+)
+
+// Stats はプレイの統計
+type Stats struct {
+	Pieces        int    `json:"pieces"`        // 固定したテトリミノの数
+	LineClears    [5]int `json:"lineClears"`    // 同時に消した行数ごとの回数（添字 1: シングル ... 4: テトリス）
+	TSpins        int    `json:"tSpins"`        // T スピンで固定した回数
+	MaxCombo      int    `json:"maxCombo"`      // 連続して行を消した最大の回数
+	FinesseFaults int    `json:"finesseFaults"` // 最短より多く移動・回転したテトリミノの数
+
+	// 表情ごとの判定されていたフレーム数と、どの表情も判定されていなかったフレーム数
+	EmotionFrames []int `json:"emotionFrames"`
+	NeutralFrames int   `json:"neutralFrames"`
+	// 表情ごとの、次のテトリミノを選んだ回数
+	EmotionPicks []int `json:"emotionPicks"`
+
+	combo int
+}
+
+// 同時に消した行数の名前
+var LineClearNames = []string{"", "SINGLE", "DOUBLE", "TRIPLE", "TETRIS"}
+
+func newStats() Stats {
+	return Stats{
+		EmotionFrames: make([]int, constants.EMOTION_COUNT),
+		EmotionPicks:  make([]int, constants.EMOTION_COUNT),
+	}
+}
+
+// recordEmotions は 1 フレームに判定されていた表情を数える
+func (s *Stats) recordEmotions(emotionIndexes []int) {
+	if len(emotionIndexes) == 0 {
+		s.NeutralFrames++
+		return
+	}
+	for _, emotion := range emotionIndexes {
+		if emotion >= 0 && emotion < len(s.EmotionFrames) {
+			s.EmotionFrames[emotion]++
+		}
+	}
+}
+
+// pieceRecord は現在のテトリミノに対する操作の記録
+type pieceRecord struct {
+	spawnX     int
+	spawnShape [][]int
+	inputs     int  // 移動・回転の操作の回数
+	rotated    bool // 最後に成功した操作が回転か（T スピンの判定に使う）
+}
+
+func (p *pieceRecord) record(action input.Action) {
+	switch action {
+	case input.MoveLeft, input.MoveRight, input.RotateCW, input.RotateCCW:
+		p.inputs++
+	}
+}
+
+// recordLock はテトリミノを固定したときの統計を更新する
+func (e *Engine) recordLock(cleared int, tSpin bool) {
+	s := &e.Stats
+	s.Pieces++
+	if cleared > 0 && cleared < len(s.LineClears) {
+		s.LineClears[cleared]++
+	}
+	if tSpin {
+		s.TSpins++
+	}
+
+	if cleared > 0 {
+		s.combo++
+		if s.combo > s.MaxCombo {
+			s.MaxCombo = s.combo
+		}
+	} else {
+		s.combo = 0
+	}
+
+	if e.piece.inputs > e.minimumInputs() {
+		s.FinesseFaults++
+	}
+}
+
+// minimumInputs は出現位置から現在の位置・向きにするための最少の操作の回数を返す
+// 回転で形が変わらない向き（O や I の 180 度など）は同じ向きとみなす
+func (e *Engine) minimumInputs() int {
+	if e.piece.spawnShape == nil {
+		return 0
+	}
+
+	rotations := 0
+	shape := e.piece.spawnShape
+	for k := 0; k < 4; k++ {
+		if sameShape(shape, e.Current.Shape) {
+			rotations = k
+			if 4-k < k {
+				rotations = 4 - k
+			}
+			break
+		}
+		shape = rotateShape(shape)
+	}
+
+	moves := e.Current.X - e.piece.spawnX
+	if moves < 0 {
+		moves = -moves
+	}
+	return rotations + moves
+}
+
+// isTSpin は現在のテトリミノが T スピンで固定されるか判定する
+// 回転で置いた T の中心の斜め 4 マスのうち 3 マス以上が埋まっている（壁も含む）場合に T スピンとする
+func (e *Engine) isTSpin() bool {
+	if e.Current == nil || e.Current.Name != "T" || !e.piece.rotated {
+		return false
+	}
+
+	// 上下左右のうち 3 方向にブロックがあるマスが中心
+	shape := e.Current.Shape
+	filled := func(x, y int) bool {
+		return y >= 0 && y < len(shape) && x >= 0 && x < len(shape[y]) && shape[y][x] == 1
+	}
+	for y := range shape {
+		for x := range shape[y] {
+			if !filled(x, y) {
+				continue
+			}
+			neighbors := 0
+			for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				if filled(x+d[0], y+d[1]) {
+					neighbors++
+				}
+			}
+			if neighbors == 3 {
+				return e.occupiedCorners(e.Current.X+x, e.Current.Y+y) >= 3
+			}
+		}
+	}
+	return false
+}
+
+// occupiedCorners は (x, y) の斜め 4 マスのうち埋まっているか壁であるマスの数を返す
+func (e *Engine) occupiedCorners(x, y int) int {
+	count := 0
+	for _, d := range [][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}} {
+		cx, cy := x+d[0], y+d[1]
+		if cx < 0 || cx >= e.Board.Width() || cy >= e.Board.Height() || (cy >= 0 && e.Board[cy][cx] != 0) {
+			count++
+		}
+	}
+	return count
+}
+
+// rotateShape は形を時計回りに 90 度回転した形を返す
+func rotateShape(shape [][]int) [][]int {
+	rows := len(shape)
+	cols := len(shape[0])
+	rotated := make([][]int, cols)
+	for i := range rotated {
+		rotated[i] = make([]int, rows)
+	}
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			rotated[x][rows-1-y] = shape[y][x]
+		}
+	}
+	return rotated
+}
+
+func sameShape(a, b [][]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for y := range a {
+		if len(a[y]) != len(b[y]) {
+			return false
+		}
+		for x := range a[y] {
+			if a[y][x] != b[y][x] {
+				return false
+			}
+		}
+	}
+	return true
+}
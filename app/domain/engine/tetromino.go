@@ -0,0 +1,538 @@
+package engine
+
+import (
+	"image/color"
+
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/input"
+)
+
+// テトリミノの定義
+type Tetromino struct {
+	Name     string      // テトリミノの種類（"I", "O", "T" など）
+	X, Y     int         // テトリミノの位置
+	Color    color.Color // テトリミノの色
+	Shape    [][]int     // テトリミノの形状（回転可能）
+	Rotation int         // 回転状態（0, 90, 180, 270度）
+	Next     *Tetromino
+}
+
+// 各テトリミノの形状を定義
+var Tetrominos = []Tetromino{
+	{
+		Name:  "I",
+		Color: color.RGBA{255, 0, 0, 255}, // 赤 - I
+		Shape: [][]int{
+			{1, 1, 1, 1}, // 横一列
+		},
+	},
+	{
+		Name:  "O",
+		Color: color.RGBA{0, 255, 0, 255}, // 緑 - O
+		Shape: [][]int{
+			{1, 1},
+			{1, 1}, // 正方形
+		},
+	},
+	{
+		Name:  "T",
+		Color: color.RGBA{0, 0, 255, 255}, // 青 - T
+		Shape: [][]int{
+			{0, 1, 0},
+			{1, 1, 1}, // T字型
+		},
+	},
+	{
+		Name:  "L",
+		Color: color.RGBA{255, 165, 0, 255}, // オレンジ - L
+		Shape: [][]int{
+			{1, 0},
+			{1, 0},
+			{1, 1}, // L字型
+		},
+	},
+	{
+		Name:  "J",
+		Color: color.RGBA{0, 255, 255, 255}, // 水色 - J
+		Shape: [][]int{
+			{0, 1},
+			{0, 1},
+			{1, 1}, // 逆L字型
+		},
+	},
+	{
+		Name:  "S",
+		Color: color.RGBA{255, 255, 0, 255}, // 黄 - S
+		Shape: [][]int{
+			{0, 1, 1},
+			{1, 1, 0}, // S字型
+		},
+	},
+	{
+		Name:  "Z",
+		Color: color.RGBA{128, 0, 128, 255}, // 紫 - Z
+		Shape: [][]int{
+			{1, 1, 0},
+			{0, 1, 1}, // Z字型
+		},
+	},
+}
+
+// TetrominoByName は種類の名前から Tetrominos の定義を返す（見つからなければ nil）
+func TetrominoByName(name string) *Tetromino {
+	for i := range Tetrominos {
+		if Tetrominos[i].Name == name {
+			return &Tetrominos[i]
+		}
+	}
+	return nil
+}
+
+// テトリミノを新しく取得
+// emotionIndexes は現在判定されている表情（constants.SMILE など）
+func (e *Engine) ShiftTetrominoQueue(emotionIndexes []int) {
+	if !e.usesEmotions() {
+		e.shiftRandomQueue()
+		return
+	}
+
+	// 現在のテトリミノをNext[0]として設定
+	e.Current = e.Next[0]
+
+	// Trueの感情のうち、候補の選択に使うものを取得
+	emotionIndexes = e.choosableEmotions(emotionIndexes)
+
+	// Trueの感情から抽選し、対応する候補を次のテトリミノにする
+	// どの感情も出ていなければ候補からランダムに選ぶ
+	drawedIndex := e.drawingEmotionFromFlags(emotionIndexes)
+	if drawedIndex < 0 {
+		drawedIndex = e.ChoiceEmotions[e.rand.Intn(len(e.ChoiceEmotions))]
+	} else {
+		e.Stats.EmotionPicks[drawedIndex]++
+	}
+	e.DrawedEmote = domain.EmotionName(drawedIndex)
+	e.Next[0] = e.Next[e.choiceIndex(drawedIndex)+1]
+
+	// 次の次のテトリミノを生成
+	copy(e.Next[1:], e.GenerateUniqueTetrominos(len(e.ChoiceEmotions)))
+	// 現在のテトリミノの位置を初期化
+	e.spawn()
+}
+
+// spawn は現在のテトリミノを出現位置に置く
+func (e *Engine) spawn() {
+	e.Current.X = (e.Board.Width() - 4) / 2
+	e.Current.Y = 0
+
+	// ドロップのタイマーと統計用の操作の記録をリセット
+	e.dropFrames = 0
+	e.piece = pieceRecord{spawnX: e.Current.X, spawnShape: e.Current.Shape}
+}
+
+// choosableEmotions は emotionIndexes のうち ChoiceEmotions に含まれるものを返す
+func (e *Engine) choosableEmotions(emotionIndexes []int) []int {
+	var choosable []int
+	for _, emotion := range emotionIndexes {
+		if e.choiceIndex(emotion) >= 0 {
+			choosable = append(choosable, emotion)
+		}
+	}
+	return choosable
+}
+
+// choiceIndex は表情が ChoiceEmotions の何番目かを返す（含まれなければ -1）
+func (e *Engine) choiceIndex(emotion int) int {
+	for i, c := range e.ChoiceEmotions {
+		if c == emotion {
+			return i
+		}
+	}
+	return -1
+}
+
+// drawingEmotionFromFlags は、emotionIndexes の長さに基づいて
+// 最大値100をその長さで分割し、ランダムな確率に基づいてインデックスを返す
+// emotionIndexes が空の場合は -1 を返す
+func (e *Engine) drawingEmotionFromFlags(emotionIndexes []int) int {
+	// emotionIndexes が空かどうかをチェック
+	if len(emotionIndexes) == 0 {
+		return -1
+	}
+
+	// emotionIndexes の長さを取得
+	numEmotions := len(emotionIndexes)
+
+	// 最大値100を分割するために、各インデックスが占める確率を計算
+	probabilityRanges := make([]int, numEmotions)
+	rangeSize := 100 / numEmotions
+
+	// 確率範囲を設定
+	for i := 0; i < numEmotions; i++ {
+		probabilityRanges[i] = (i + 1) * rangeSize
+	}
+
+	// ランダムな数値を生成（0から99まで）
+	randomValue := e.rand.Intn(100)
+
+	// ランダムな数値がどの範囲に属するか調べる
+	for i := 0; i < numEmotions; i++ {
+		if randomValue < probabilityRanges[i] {
+			return emotionIndexes[i] // 該当するインデックスを返す
+		}
+	}
+
+	// 万が一、どれにも該当しなかった場合は最後のインデックスを返す
+	return emotionIndexes[numEmotions-1]
+}
+
+// ランダムにテトリミノを生成するヘルパー関数
+func (e *Engine) GenerateRandomTetromino() *Tetromino {
+	randomIndex := e.rand.Intn(len(Tetrominos)) // テトリミノのリストからランダムに選択
+
+	// 選択したテトリミノを新しくインスタンス化して返す
+	newTetromino := Tetromino{
+		Name:     Tetrominos[randomIndex].Name,
+		Color:    Tetrominos[randomIndex].Color,
+		Shape:    append([][]int{}, Tetrominos[randomIndex].Shape...), // Shapeを新しくコピー
+		Rotation: 0,                                                   // 初期回転状態
+	}
+
+	return &newTetromino
+}
+
+// GenerateUniqueTetrominos は n 個のテトリミノを生成する
+// n 個ごとに重複しないように選び、種類の数を超える場合は並べ直して続ける
+func (e *Engine) GenerateUniqueTetrominos(n int) []*Tetromino {
+	tetrominos := make([]*Tetromino, n)
+
+	// シャッフルアルゴリズムを使用して重複を防ぐ
+	var indexes []int
+	for i := range tetrominos {
+		if i%len(Tetrominos) == 0 {
+			indexes = e.rand.Perm(len(Tetrominos)) // 0からlen(Tetrominos)-1までのランダム順列を生成
+		}
+		index := indexes[i%len(Tetrominos)]
+		tetrominos[i] = &Tetromino{
+			Name:     Tetrominos[index].Name,
+			Color:    Tetrominos[index].Color,
+			Shape:    append([][]int{}, Tetrominos[index].Shape...), // Shapeを新しくコピー
+			Rotation: 0,
+		}
+	}
+
+	return tetrominos
+}
+
+// テトリミノの回転処理
+func (e *Engine) RotateTetromino() {
+	// 現在の形状の行数と列数を取得
+	rows := len(e.Current.Shape)
+	cols := len(e.Current.Shape[0])
+
+	// 回転後の形状を計算
+	newShape := make([][]int, cols)
+	for i := range newShape {
+		newShape[i] = make([]int, rows)
+	}
+
+	// 回転処理：90度回転
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			newShape[x][rows-1-y] = e.Current.Shape[y][x]
+		}
+	}
+
+	// 回転後の形状を適用
+	e.Current.Shape = newShape
+
+	// 回転状態を更新
+	e.Current.Rotation = (e.Current.Rotation + 90) % 360
+}
+
+// テトリミノの逆回転処理
+func (e *Engine) RotateTetrominoCCW() {
+	// 現在の形状の行数と列数を取得
+	rows := len(e.Current.Shape)
+	cols := len(e.Current.Shape[0])
+
+	// 回転後の形状を計算
+	newShape := make([][]int, cols)
+	for i := range newShape {
+		newShape[i] = make([]int, rows)
+	}
+
+	// 回転処理：-90度回転
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			newShape[cols-1-x][y] = e.Current.Shape[y][x]
+		}
+	}
+
+	// 回転後の形状を適用
+	e.Current.Shape = newShape
+
+	// 回転状態を更新
+	e.Current.Rotation = (e.Current.Rotation + 270) % 360
+}
+
+// ApplyAction は操作をテトリミノに適用する
+// 移動できない方向への操作は無視する
+// Pause はゲームの進行を止めるだけなので、Step を呼ぶ側で扱う
+func (e *Engine) ApplyAction(action input.Action) {
+	if e.Current == nil {
+		return
+	}
+
+	e.piece.record(action)
+
+	switch action {
+	case input.MoveLeft:
+		if e.IsValidPosition(e.Current, -1, 0) {
+			e.Current.X -= 1
+			e.piece.rotated = false
+		}
+	case input.MoveRight:
+		if e.IsValidPosition(e.Current, 1, 0) {
+			e.Current.X += 1
+			e.piece.rotated = false
+		}
+	case input.SoftDrop:
+		if e.IsValidPosition(e.Current, 0, 1) {
+			e.Current.Y += 1
+			e.piece.rotated = false
+		}
+	case input.HardDrop:
+		e.HardDrop()
+	case input.RotateCW:
+		e.TryRotate(true)
+	case input.RotateCCW:
+		e.TryRotate(false)
+	case input.Hold:
+		e.HoldTetromino()
+	}
+}
+
+// HardDrop はテトリミノを一番下まで落とす
+// 固定はこのフレームの落下処理で行う
+func (e *Engine) HardDrop() {
+	for e.IsValidPosition(e.Current, 0, 1) {
+		e.Current.Y += 1
+		e.piece.rotated = false
+	}
+	e.lockNow = true
+}
+
+// HoldTetromino は現在のテトリミノをホールドし、ホールドしていたものと入れ替える
+// ホールドは 1 つのテトリミノを固定するまでに 1 回だけできる
+func (e *Engine) HoldTetromino() {
+	if e.HoldUsed {
+		return
+	}
+	e.HoldUsed = true
+	// 同じフレームのハードドロップは入れ替えたテトリミノには適用しない
+	// （残っていると次のテトリミノが出現した位置で固定されてしまう）
+	e.lockNow = false
+
+	// 回転していない向きに戻してからホールドする
+	held := e.Current
+	for held.Rotation != 0 {
+		e.RotateTetrominoCCW()
+	}
+
+	if e.Hold == nil {
+		// 次のテトリミノは落下処理の前に ShiftTetrominoQueue で取り出す
+		e.Hold = held
+		e.Current = nil
+		return
+	}
+
+	e.Current, e.Hold = e.Hold, held
+	e.spawn()
+}
+
+// TryRotate はテトリミノを回転し、はみ出しや重なりがあれば位置を調整する
+// clockwise が false の場合は反時計回りに回転する
+func (e *Engine) TryRotate(clockwise bool) {
+	// 回転処理を試みる
+	oldX, oldY := e.Current.X, e.Current.Y
+	if clockwise {
+		e.RotateTetromino()
+	} else {
+		e.RotateTetrominoCCW()
+	}
+
+	// 範囲外または重なりがある場合、位置を調整
+	for e.IsOutOfBounds() == "left" {
+		e.Current.X++
+	}
+	for e.IsOutOfBounds() == "right" {
+		e.Current.X--
+	}
+	for e.IsOutOfBounds() == "bottom" || e.IsOverlapping() {
+		e.Current.Y--
+	}
+
+	// 調整後も無効な場合は回転をキャンセル
+	e.piece.rotated = true
+	if e.IsOutOfBounds() != "" || e.IsOverlapping() {
+		e.piece.rotated = false
+		e.Current.X = oldX
+		e.Current.Y = oldY
+		// 回転を元に戻す
+		if clockwise {
+			e.RotateTetrominoCCW()
+		} else {
+			e.RotateTetromino()
+		}
+	}
+}
+
+func (e *Engine) IsOutOfBounds() string {
+	current := e.Current
+	rows := len(current.Shape)
+	cols := len(current.Shape[0])
+
+	// ブロックの形状の各セルを確認
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			if current.Shape[y][x] != 0 { // 非空ブロックのみ確認
+				boardX := current.X + x
+				boardY := current.Y + y
+
+				// 左側に出ている場合
+				if boardX < 0 {
+					return "left"
+				}
+
+				// 右側に出ている場合
+				if boardX >= e.Board.Width() {
+					return "right"
+				}
+
+				// 下側に出ている場合
+				if boardY >= e.Board.Height() {
+					return "bottom"
+				}
+			}
+		}
+	}
+	// 範囲外ではない場合は空文字を返す
+	return ""
+}
+
+func (e *Engine) IsOverlapping() bool {
+	current := e.Current
+	rows := len(current.Shape)
+	cols := len(current.Shape[0])
+
+	// ブロックの形状の各セルを確認
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			if current.Shape[y][x] != 0 { // 非空ブロックのみ確認
+				boardX := current.X + x
+				boardY := current.Y + y
+
+				// ボード上に位置している場合のみ重なりを確認
+				if boardY >= 0 && boardY < e.Board.Height() &&
+					boardX >= 0 && boardX < e.Board.Width() {
+					if e.Board[boardY][boardX] != 0 {
+						return true // 他のブロックと重なっている
+					}
+				}
+			}
+		}
+	}
+	return false // 重なりがない
+}
+
+// 横一列が揃った行を削除し、スコアを加算
+// 消した行数を返す
+func (e *Engine) ClearFullRows() int {
+	clearedRows := 0
+
+	// 上から下へループ
+	for y := len(e.Board) - 1; y >= 0; y-- {
+		full := true
+		for x := 0; x < len(e.Board[y]); x++ {
+			if e.Board[y][x] == 0 {
+				full = false
+				break
+			}
+		}
+
+		// 横一列が揃っている場合
+		if full {
+			clearedRows++
+
+			// 上の行を下にずらす
+			for yy := y; yy > 0; yy-- {
+				e.Board[yy] = e.Board[yy-1]
+			}
+
+			// 一番上の行を初期化
+			e.Board[0] = make([]int, len(e.Board[0]))
+
+			// 現在の行を再チェック（行をずらしたため）
+			y++
+		}
+	}
+
+	// スコアを加算（1行100点、2行300点、3行600点、4行1000点）
+	if clearedRows > 0 {
+		e.Lines += clearedRows
+		e.Score += clearedRows * (clearedRows + 1) / 2 * 100
+	}
+	return clearedRows
+}
+
+// ボードの範囲と重なりをチェック
+func (e *Engine) IsValidPosition(tetromino *Tetromino, offsetX, offsetY int) bool {
+	for y := 0; y < len(tetromino.Shape); y++ {
+		for x := 0; x < len(tetromino.Shape[y]); x++ {
+			if tetromino.Shape[y][x] == 1 {
+				newX := tetromino.X + x + offsetX
+				newY := tetromino.Y + y + offsetY
+
+				// ボードの範囲外をチェック
+				if newX < 0 || newX >= len(e.Board[0]) || newY >= len(e.Board) {
+					return false
+				}
+
+				// 他のブロックと重なっていないかをチェック
+				if newY >= 0 && e.Board[newY][newX] == 1 {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// ボードにテトリミノを固定
+func (e *Engine) LockTetromino() {
+	// T スピンの判定は固定する前のボードで行う
+	tSpin := e.isTSpin()
+
+	for y := 0; y < len(e.Current.Shape); y++ {
+		for x := 0; x < len(e.Current.Shape[y]); x++ {
+			if e.Current.Shape[y][x] == 1 {
+				// 回転で上にずらしたテトリミノがボードの外で固定された場合はゲームオーバー
+				if e.Current.Y+y < 0 {
+					e.Over = true
+					continue
+				}
+				e.Board[e.Current.Y+y][e.Current.X+x] = 1
+			}
+		}
+	}
+
+	// 横一列が揃っているか確認
+	cleared := e.ClearFullRows()
+	e.recordLock(cleared, tSpin)
+	e.exchangeGarbage(cleared, tSpin)
+	e.recordEvents(cleared)
+
+	// 新しいテトリミノを生成
+	e.Current = nil
+	e.HoldUsed = false
+}
@@ -1,14 +1,14 @@
 package domain
 
 import (
-	"fmt"
 	"math"
 	"square-face-tetris/app/constants"
+	"time"
 )
 
 type Face struct {
 	Snapshot struct {
-		Landmarks [][]int
+		Landmarks Landmarks
 		Horizonal struct {
 			LEyebrowOuter2REyebrowOuter float64 // 左眉外側から右眉外側までの距離
 			LEyebrowTop2REyebrowTop     float64 // 左眉内側から右眉内側までの距離
@@ -31,34 +31,49 @@ type Face struct {
 		Nose2MouthBottomRatio     float64 // 鼻先から口下端までの距離比率
 	}
 
-	// smile, angry, surprised, sus
+	// constants.SMILE から constants.TILT_RIGHT までの各表情・ジェスチャー
 	EmoteFlags []bool
+	// 各表情・ジェスチャーの確からしさ（0 から 1）
+	Confidences []float64
+	// 最後のフレームで検出できたランドマークと瞳の割合（0 から 1）
+	Quality float64
+
+	// 表情・ジェスチャーが判定され始めたときのイベント（PopEvents で取り出す）
+	Events []FaceEvent
+	// ジェスチャーの判定に使う直前までの状態
+	gesture gestureState
+
+	// 表情の判定に使う分類器（nil の場合はルールベースで判定する）
+	Classifier Classifier
 }
 
-func NewFace(landmarks [][]int) Face {
+// NewFace は基準となる（無表情の）ランドマークから顔情報を作る
+// 欠けている点や重なっている点を使う距離と比率は 0 になり、その比率を使う表情は判定されない
+func NewFace(landmarks Landmarks) Face {
+	p := &landmarks.Points
 
 	// 各値を計算
 	// 水平方向
-	LEyeOuter2REyeOuter := calcDistance(landmarks[constants.L_EYEBROW_OUTER], landmarks[constants.R_EYEBROW_OUTER])
+	LEyeOuter2REyeOuter := calcDistance(p[constants.L_EYEBROW_OUTER], p[constants.R_EYEBROW_OUTER])
 	LEyeOuter2REyeOuterRatio := 1.0
 
-	LEyebrowTop2REyebrowTop := calcDistance(landmarks[constants.L_EYEBROW_TOP], landmarks[constants.R_EYEBROW_TOP])
-	LEyebrowTop2REyebrowTopRatio := LEyebrowTop2REyebrowTop / LEyeOuter2REyeOuter
+	LEyebrowTop2REyebrowTop := calcDistance(p[constants.L_EYEBROW_TOP], p[constants.R_EYEBROW_TOP])
+	LEyebrowTop2REyebrowTopRatio := ratio(LEyebrowTop2REyebrowTop, LEyeOuter2REyeOuter)
 
-	LEyebrowInner2REyebrowInner := calcDistance(landmarks[constants.L_EYEBROW_INNER], landmarks[constants.R_EYEBROW_INNER])
-	LEyebrowInner2REyebrowInnerRatio := LEyebrowInner2REyebrowInner / LEyeOuter2REyeOuter
+	LEyebrowInner2REyebrowInner := calcDistance(p[constants.L_EYEBROW_INNER], p[constants.R_EYEBROW_INNER])
+	LEyebrowInner2REyebrowInnerRatio := ratio(LEyebrowInner2REyebrowInner, LEyeOuter2REyeOuter)
 
-	LMouth2RMouth := calcDistance(landmarks[constants.L_MOUTH], landmarks[constants.R_MOUTH])
-	LMouth2RMouthRatio := LMouth2RMouth / LEyeOuter2REyeOuter
+	LMouth2RMouth := calcDistance(p[constants.L_MOUTH], p[constants.R_MOUTH])
+	LMouth2RMouthRatio := ratio(LMouth2RMouth, LEyeOuter2REyeOuter)
 
 	// 垂直方向
-	glabella := calcCenter(landmarks[constants.L_EYEBROW_INNER], landmarks[constants.R_EYEBROW_INNER])
-	mouthCenter := calcCenter(landmarks[constants.T_MOUTH], landmarks[constants.B_MOUTH])
+	glabella := calcCenter(p[constants.L_EYEBROW_INNER], p[constants.R_EYEBROW_INNER])
+	mouthCenter := calcCenter(p[constants.T_MOUTH], p[constants.B_MOUTH])
 	Glabella2MouthCenter := calcDistance(glabella, mouthCenter)
 	Glabella2MouthCenterRatio := 1.0
 
-	Nose2MouthBottom := calcDistance(landmarks[constants.NOSE], landmarks[constants.B_MOUTH])
-	Nose2MouthBottomRatio := Nose2MouthBottom / Glabella2MouthCenter
+	Nose2MouthBottom := calcDistance(p[constants.NOSE], p[constants.B_MOUTH])
+	Nose2MouthBottomRatio := ratio(Nose2MouthBottom, Glabella2MouthCenter)
 
 	// 顔情報を構造体に格納
 	var face Face
@@ -81,33 +96,110 @@ func NewFace(landmarks [][]int) Face {
 	face.VerticalRatio.Glabella2MouthCenterRatio = Glabella2MouthCenterRatio
 	face.VerticalRatio.Nose2MouthBottomRatio = Nose2MouthBottomRatio
 
-	face.EmoteFlags = []bool {false, false, false, false}
+	face.EmoteFlags = make([]bool, constants.EMOTION_COUNT)
 
 	return face
 }
 
 // 顔情報を更新する
-// choices は4つの選択肢に対応するインデックスを格納した配列
-func (f *Face) Update(landmarks [][]int, choices []int) {
-	// スナップショットの比率と現在の比率を比較して、表情を判定する
-	f.EmoteFlags[constants.SMILE] = f.IsSmile(landmarks)
-	f.EmoteFlags[constants.ANGRY] = f.IsAngry(landmarks)
-	f.EmoteFlags[constants.SURPRISED] = f.IsSurprised(landmarks)
-	f.EmoteFlags[constants.SUS] = f.IsSus(landmarks)
-
-	fmt.Println(f.EmoteFlags)
+// 欠けている点が多いほど、確からしさを低くする
+func (f *Face) Update(landmarks Landmarks) {
+	var flags []bool
+	if f.Classifier != nil {
+		flags = f.Classifier.Classify(landmarks)
+	} else {
+		flags = f.Classify(landmarks)
+	}
+
+	// 分類器が判定しない表情・ジェスチャーは false として扱う
+	current := make([]bool, constants.EMOTION_COUNT)
+	copy(current, flags)
+	f.detectGestures(current, landmarks, time.Now())
+
+	// 新しく判定され始めた表情・ジェスチャーをイベントとして通知する
+	for i, flag := range current {
+		if flag && (i >= len(f.EmoteFlags) || !f.EmoteFlags[i]) {
+			f.Events = append(f.Events, FaceEvent{Emotion: i, Time: time.Now()})
+		}
+	}
+	f.EmoteFlags = current
+	f.Quality = landmarks.Quality()
+	f.Confidences = f.confidences(landmarks, current)
+	for i := range f.Confidences {
+		f.Confidences[i] *= f.Quality
+	}
+}
+
+// confidences は判定の確からしさを返す
+// 分類器が確からしさを返さない表情・ジェスチャーは、判定されていれば 1 とする
+func (f *Face) confidences(landmarks Landmarks, flags []bool) []float64 {
+	confidences := make([]float64, constants.EMOTION_COUNT)
+	if c, ok := f.Classifier.(ConfidenceClassifier); ok {
+		copy(confidences, c.Confidences(landmarks))
+	}
+	for i, flag := range flags {
+		if flag && confidences[i] == 0 {
+			confidences[i] = 1
+		}
+	}
+	return confidences
+}
+
+// Clear は表情・ジェスチャーの判定を全て解除する
+// 顔を見失ったときに、最後の表情が残り続けないようにする
+func (f *Face) Clear() {
+	f.EmoteFlags = make([]bool, constants.EMOTION_COUNT)
+	f.Confidences = make([]float64, constants.EMOTION_COUNT)
+	f.Quality = 0
+	f.Events = nil
+	f.gesture = gestureState{}
+}
+
+// PopEvents は溜まっているイベントを取り出す
+func (f *Face) PopEvents() []FaceEvent {
+	events := f.Events
+	f.Events = nil
+	return events
+}
+
+// Calibrate は基準となる（無表情の）ランドマークで顔情報を作り直す
+// 分類器が設定されている場合はそちらも較正する
+func (f *Face) Calibrate(baseline Landmarks) {
+	classifier := f.Classifier
+	*f = NewFace(baseline)
+	f.Classifier = classifier
+	if classifier != nil {
+		classifier.Calibrate(baseline)
+	}
+}
+
+// Classify はスナップショットの比率と現在の比率を比較して、表情を判定する
+// 戻り値は constants.SMILE などのインデックスに対応するフラグ
+func (f *Face) Classify(landmarks Landmarks) []bool {
+	flags := make([]bool, constants.EMOTION_COUNT)
+	flags[constants.SMILE] = f.IsSmile(landmarks)
+	flags[constants.ANGRY] = f.IsAngry(landmarks)
+	flags[constants.SURPRISED] = f.IsSurprised(landmarks)
+	flags[constants.SUS] = f.IsSus(landmarks)
+	return flags
 }
 
 // 🙂
-func (f *Face) IsSmile(landmarks [][]int) bool {
+func (f *Face) IsSmile(landmarks Landmarks) bool {
 	border := 10.0 // TODO: しきい値を定数化
 
-	mouthLeft := landmarks[constants.L_MOUTH]
-	mouthRight := landmarks[constants.R_MOUTH]
-	lEyebrowOuter := landmarks[constants.L_EYEBROW_OUTER]
-	rEyebrowOuter := landmarks[constants.R_EYEBROW_OUTER]
+	if !f.canClassify(landmarks, constants.L_MOUTH, constants.R_MOUTH, constants.L_EYEBROW_OUTER, constants.R_EYEBROW_OUTER) {
+		return false
+	}
+	mouthLeft := landmarks.Points[constants.L_MOUTH]
+	mouthRight := landmarks.Points[constants.R_MOUTH]
+	lEyebrowOuter := landmarks.Points[constants.L_EYEBROW_OUTER]
+	rEyebrowOuter := landmarks.Points[constants.R_EYEBROW_OUTER]
 
 	snapMouthRatio := f.HorizonalRatio.LMouth2RMouthRatio
+	if snapMouthRatio == 0 {
+		return false
+	}
 
 	// スナップショットの比率をもとに、現在の眉尻の距離から基準となる口端の距離を算出する
 	// 笑顔であれば左右に口端が広がるため、基準よりも大きい値になる
@@ -119,26 +211,36 @@ func (f *Face) IsSmile(landmarks [][]int) bool {
 }
 
 // 😠
-func (f *Face) IsAngry(landmarks [][]int) bool {
+func (f *Face) IsAngry(landmarks Landmarks) bool {
 	// TODO: しきい値を定数化
 	eyebrowBorder := -7.0
 	nose2mouthBorder := -5.0
 
+	if !f.canClassify(landmarks,
+		constants.L_EYEBROW_OUTER, constants.R_EYEBROW_OUTER, constants.L_EYEBROW_INNER, constants.R_EYEBROW_INNER,
+		constants.T_MOUTH, constants.B_MOUTH, constants.NOSE) {
+		return false
+	}
+	if f.HorizonalRatio.LEyebrowInner2REyebrowInnerRatio == 0 || f.VerticalRatio.Nose2MouthBottomRatio == 0 {
+		return false
+	}
+	p := &landmarks.Points
+
 	// スナップショットの比率をもとに、現在の眉間の距離を算出する
 	// 怒っていると眉間が狭まるため、基準よりも小さい値になる
-	currentEyebrowOuterDist := calcDistance(landmarks[constants.L_EYEBROW_OUTER], landmarks[constants.R_EYEBROW_OUTER])
+	currentEyebrowOuterDist := calcDistance(p[constants.L_EYEBROW_OUTER], p[constants.R_EYEBROW_OUTER])
 	basisEyebrowInnerDist := currentEyebrowOuterDist * f.HorizonalRatio.LEyebrowInner2REyebrowInnerRatio
-	currentEyebrowInnerDist := calcDistance(landmarks[constants.L_EYEBROW_INNER], landmarks[constants.R_EYEBROW_INNER])
+	currentEyebrowInnerDist := calcDistance(p[constants.L_EYEBROW_INNER], p[constants.R_EYEBROW_INNER])
 
 	isAngryEyebrow := (currentEyebrowInnerDist - basisEyebrowInnerDist) < eyebrowBorder
 
 	// スナップショットの比率をもとに、現在の鼻先から口下端までの距離を算出する
 	// 怒っていると鼻先から口下端までの距離が短くなるため、基準よりも小さい値になる
-	currentGlabella := calcCenter(landmarks[constants.L_EYEBROW_INNER], landmarks[constants.R_EYEBROW_INNER])
-	currentMouthCenter := calcCenter(landmarks[constants.T_MOUTH], landmarks[constants.B_MOUTH])
+	currentGlabella := calcCenter(p[constants.L_EYEBROW_INNER], p[constants.R_EYEBROW_INNER])
+	currentMouthCenter := calcCenter(p[constants.T_MOUTH], p[constants.B_MOUTH])
 	currentGlabella2MouthCenterDist := calcDistance(currentGlabella, currentMouthCenter)
 	basisNose2MouthBottomDist := currentGlabella2MouthCenterDist * f.VerticalRatio.Nose2MouthBottomRatio
-	currentNose2MouthBottomDist := calcDistance(landmarks[constants.NOSE], landmarks[constants.B_MOUTH])
+	currentNose2MouthBottomDist := calcDistance(p[constants.NOSE], p[constants.B_MOUTH])
 
 	isAngryMouth := (currentNose2MouthBottomDist - basisNose2MouthBottomDist) < nose2mouthBorder
 
@@ -146,15 +248,20 @@ func (f *Face) IsAngry(landmarks [][]int) bool {
 }
 
 // 😲
-func (f *Face) IsSurprised(landmarks [][]int) bool {
+func (f *Face) IsSurprised(landmarks Landmarks) bool {
+	// 基準の顔は使わないため、現在の点だけを確かめる
+	if !landmarks.Has(constants.L_MOUTH, constants.R_MOUTH, constants.T_MOUTH, constants.B_MOUTH) {
+		return false
+	}
+
 	// 口の端を結んだ距離
-	mouthLeft := landmarks[constants.L_MOUTH]
-	mouthRight := landmarks[constants.R_MOUTH]
+	mouthLeft := landmarks.Points[constants.L_MOUTH]
+	mouthRight := landmarks.Points[constants.R_MOUTH]
 	mouthWidth := calcDistance(mouthLeft, mouthRight)
 
 	// 口の上下を結んだ距離
-	mouthTop := landmarks[constants.T_MOUTH]
-	mouthBottom := landmarks[constants.B_MOUTH]
+	mouthTop := landmarks.Points[constants.T_MOUTH]
+	mouthBottom := landmarks.Points[constants.B_MOUTH]
 	mouthHeight := calcDistance(mouthTop, mouthBottom)
 
 	// 口の上下を結んだ距離のほうが長ければ驚いていると判別
@@ -162,95 +269,116 @@ func (f *Face) IsSurprised(landmarks [][]int) bool {
 }
 
 // 🤨
-func (f *Face) IsSus(landmarks [][]int) bool {
-	EyebrowBorder := 3 // TODO: しきい値を定数化
-	faceInclinationBorder := 0.075   // TODO: しきい値を定数化
+func (f *Face) IsSus(landmarks Landmarks) bool {
+	EyebrowBorder := 3             // TODO: しきい値を定数化
+	faceInclinationBorder := 0.075 // TODO: しきい値を定数化
 
-	faceInclination := calcFaceInclination(landmarks)
-	fmt.Println(faceInclination)
-	if(math.Abs(faceInclination) > faceInclinationBorder) {
+	// 傾きを求めるには全ての点が必要
+	faceInclination, ok := f.relativePose(landmarks)
+	if !ok || math.Abs(faceInclination.Roll) > faceInclinationBorder {
 		return false
 	}
 
-	leftEyebrowTop := landmarks[constants.L_EYEBROW_TOP]
-	rightEyebrowTop := landmarks[constants.R_EYEBROW_TOP]
-	leftEyebrowInner := landmarks[constants.L_EYEBROW_INNER]
-	rightEyebrowInner := landmarks[constants.R_EYEBROW_INNER]
+	leftEyebrowTop := landmarks.Points[constants.L_EYEBROW_TOP]
+	rightEyebrowTop := landmarks.Points[constants.R_EYEBROW_TOP]
+	leftEyebrowInner := landmarks.Points[constants.L_EYEBROW_INNER]
+	rightEyebrowInner := landmarks.Points[constants.R_EYEBROW_INNER]
 
 	// どちらかの inner がどちらかの top より上にある場合にTrueを返す
-	isLeftHigher := (rightEyebrowTop[1] - leftEyebrowInner[1]) > EyebrowBorder
-	isRightHigher := (leftEyebrowTop[1] - rightEyebrowInner[1]) > EyebrowBorder
+	isLeftHigher := (rightEyebrowTop.Y - leftEyebrowInner.Y) > EyebrowBorder
+	isRightHigher := (leftEyebrowTop.Y - rightEyebrowInner.Y) > EyebrowBorder
 
 	return isLeftHigher || isRightHigher
 }
 
+func (f *Face) GetEmotionIndexes() []int {
+	// 結果を格納するスライス
+	var emotionIndexes []int
 
-func (f *Face)GetEmotionIndexes() []int {
-    // 結果を格納するスライス
-    var emotionIndexes []int
-
-    // EmoteFlags の長さチェック
-    if f.EmoteFlags == nil || len(f.EmoteFlags) <= constants.SUS {
-        fmt.Println("EmoteFlags is nil or too short")
-        return []int{}
-    }
-
-    // 各感情フラグに基づいてインデックスを追加
-    if constants.SMILE < len(f.EmoteFlags) && f.EmoteFlags[constants.SMILE] {
-        emotionIndexes = append(emotionIndexes, 0) // SMILE の場合
-    }
-    if constants.ANGRY < len(f.EmoteFlags) && f.EmoteFlags[constants.ANGRY] {
-        emotionIndexes = append(emotionIndexes, 1) // ANGRY の場合
-    }
-    if constants.SURPRISED < len(f.EmoteFlags) && f.EmoteFlags[constants.SURPRISED] {
-        emotionIndexes = append(emotionIndexes, 2) // SURPRISED の場合
-    }
-    if constants.SUS < len(f.EmoteFlags) && f.EmoteFlags[constants.SUS] {
-        emotionIndexes = append(emotionIndexes, 3) // SUS の場合
-    }
-
-    fmt.Println("EmotionIndexes:", emotionIndexes)
-    // 結果としてインデックスの配列を返す
-    return emotionIndexes
+	// 各感情フラグに基づいてインデックスを追加
+	for i, flag := range f.EmoteFlags {
+		if flag {
+			emotionIndexes = append(emotionIndexes, i)
+		}
+	}
+
+	// 結果としてインデックスの配列を返す
+	return emotionIndexes
 }
 
 func (f *Face) GetEmotionByIndex(index int) string {
-	// 感情に対応する文字列を返す
-	switch index {
-	case 0:
-			return "SMILE"
-	case 1:
-			return "ANGRY"
-	case 2:
-			return "SURPRISED"
-	case 3:
-			return "SUS"
-	default:
-			return "UNKNOWN"
+	return EmotionName(index)
+}
+
+// EmotionName は感情のインデックスに対応する文字列を返す
+func EmotionName(index int) string {
+	if index < 0 || index >= len(emotionNames) {
+		return "UNKNOWN"
+	}
+	return emotionNames[index]
+}
+
+// constants.SMILE などのインデックス順の名前
+var emotionNames = []string{
+	constants.SMILE:        "SMILE",
+	constants.ANGRY:        "ANGRY",
+	constants.SURPRISED:    "SURPRISED",
+	constants.SUS:          "SUS",
+	constants.WINK_LEFT:    "WINK_LEFT",
+	constants.WINK_RIGHT:   "WINK_RIGHT",
+	constants.DOUBLE_BLINK: "DOUBLE_BLINK",
+	constants.MOUTH_OPEN:   "MOUTH_OPEN",
+	constants.TILT_LEFT:    "TILT_LEFT",
+	constants.TILT_RIGHT:   "TILT_RIGHT",
+}
+
+// EmotionIndex は文字列に対応する感情のインデックスを返す
+// 該当しない場合は -1 を返す
+func EmotionIndex(name string) int {
+	for i := 0; i < constants.EMOTION_COUNT; i++ {
+		if EmotionName(i) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// relativePose はスナップショットを基準とした頭の向きを返す
+// 瞳の情報は使わず、ランドマークだけで推定する。どちらかの点が欠けている場合は false を返す
+func (f *Face) relativePose(landmarks Landmarks) (HeadPose, bool) {
+	current, ok := EstimatePose(landmarks.withoutPupils())
+	base, baseOk := EstimatePose(f.Snapshot.Landmarks.withoutPupils())
+	if !ok || !baseOk {
+		return HeadPose{}, false
 	}
+	return current.Sub(base), true
+}
+
+// canClassify は現在とスナップショットの両方で、指定した点が検出できているかを返す
+func (f *Face) canClassify(landmarks Landmarks, indexes ...int) bool {
+	return landmarks.Has(indexes...) && f.Snapshot.Landmarks.Has(indexes...)
 }
 
 // 2点間の距離を求める。
 // ピタゴラスの定理より z = sqrt(x^2 + y^2)
-func calcDistance(p1, p2 []int) float64 {
-	return math.Sqrt(math.Pow(float64(p2[0]-p1[0]), 2) + math.Pow(float64(p2[1]-p1[1]), 2))
+// どちらかの点が検出できていなければ 0 を返す
+func calcDistance(p1, p2 Point) float64 {
+	if !p1.Valid || !p2.Valid {
+		return 0
+	}
+	return math.Sqrt(math.Pow(float64(p2.X-p1.X), 2) + math.Pow(float64(p2.Y-p1.Y), 2))
 }
 
 // 2点を結ぶ線分の中心座標を求める。
 // x, y座標それぞれの平均値をとった座標
-func calcCenter(p1, p2 []int) []int {
-	return []int{(p1[0] + p2[0]) / 2, (p1[1] + p2[1]) / 2}
+func calcCenter(p1, p2 Point) Point {
+	return Point{X: (p1.X + p2.X) / 2, Y: (p1.Y + p2.Y) / 2, Valid: p1.Valid && p2.Valid}
 }
 
-func calcFaceInclination(landmarks [][]int) float64 {
-    // 顔の傾きを計算
-    // 左眉の上端と右眉の上端の中心座標
-    glabella := calcCenter(landmarks[constants.L_EYEBROW_INNER], landmarks[constants.R_EYEBROW_INNER])
-
-    // 口の上端と下端の中心座標
-    mouthCenter := calcCenter(landmarks[constants.T_MOUTH], landmarks[constants.B_MOUTH])
-
-    // 顔の傾きを計算
-		// 水平の場合 1.5 の近似値を返していたので、それを引いている
-    return math.Atan2(float64(mouthCenter[1]-glabella[1]), float64(mouthCenter[0]-glabella[0])) - 1.5
+// ratio は a / b を返す。b が 0 の場合は 0 を返す
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
 }
@@ -0,0 +1,185 @@
+package domain
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"square-face-tetris/app/constants"
+)
+
+// neutralPoints は正面を向いた無表情の顔のランドマーク [x, y, scale]
+// 画像の座標で、L_ の点（本人の左）が画像の右側にある
+func neutralPoints() [][]int {
+	p := make([][]int, constants.LANDMARK_COUNT)
+	p[constants.R_EYEBROW_OUTER] = []int{60, 80, 4}
+	p[constants.L_EYEBROW_OUTER] = []int{180, 80, 4}
+	p[constants.R_EYEBROW_TOP] = []int{80, 72, 4}
+	p[constants.L_EYEBROW_TOP] = []int{160, 72, 4}
+	p[constants.R_EYEBROW_INNER] = []int{100, 80, 4}
+	p[constants.L_EYEBROW_INNER] = []int{140, 80, 4}
+	p[constants.R_EYE_OUTER] = []int{70, 100, 4}
+	p[constants.R_EYE_INNER] = []int{105, 100, 4}
+	p[constants.L_EYE_INNER] = []int{135, 100, 4}
+	p[constants.L_EYE_OUTER] = []int{170, 100, 4}
+	p[constants.NOSE] = []int{120, 140, 4}
+	p[constants.R_MOUTH] = []int{95, 170, 4}
+	p[constants.L_MOUTH] = []int{145, 170, 4}
+	p[constants.T_MOUTH] = []int{120, 165, 4}
+	p[constants.B_MOUTH] = []int{120, 178, 4}
+	return p
+}
+
+// neutralPupils は左右の瞳 [x, y, scale]
+func neutralPupils() [][]int {
+	return [][]int{{152, 100, 4}, {88, 100, 4}}
+}
+
+// landmarksWith は無表情の顔を modify で変えた Landmarks を返す
+func landmarksWith(modify func(points, pupils [][]int) ([][]int, [][]int)) Landmarks {
+	points, pupils := neutralPoints(), neutralPupils()
+	if modify != nil {
+		points, pupils = modify(points, pupils)
+	}
+	return NewLandmarks(points, pupils)
+}
+
+func TestNewLandmarks(t *testing.T) {
+	tests := []struct {
+		name        string
+		points      [][]int
+		pupils      [][]int
+		wantCount   int
+		wantQuality float64
+	}{
+		{"complete", neutralPoints(), neutralPupils(), 15, 1},
+		{"nil pupil", neutralPoints(), [][]int{nil, {88, 100, 4}}, 15, 16.0 / 17},
+		{"no pupils", neutralPoints(), nil, 15, 15.0 / 17},
+		{"short points", [][]int{{1}, {}, nil, {10, 20}}, nil, 1, 1.0 / 17},
+		{"too many points", append(neutralPoints(), []int{1, 2, 3}), append(neutralPupils(), []int{1, 2}), 15, 1},
+		{"empty", nil, nil, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLandmarks(tt.points, tt.pupils)
+			if l.Count() != tt.wantCount || l.Quality() != tt.wantQuality {
+				t.Errorf("Count, Quality = %d, %v, want %d, %v", l.Count(), l.Quality(), tt.wantCount, tt.wantQuality)
+			}
+		})
+	}
+}
+
+func TestFaceUpdate(t *testing.T) {
+	allZero := func(points, pupils [][]int) ([][]int, [][]int) {
+		for i := range points {
+			points[i] = []int{0, 0, 0}
+		}
+		return points, [][]int{{0, 0, 0}, {0, 0, 0}}
+	}
+	// 口を縦に大きく開ける
+	openMouth := func(points, pupils [][]int) ([][]int, [][]int) {
+		points[constants.T_MOUTH] = []int{120, 150, 4}
+		points[constants.B_MOUTH] = []int{120, 215, 4}
+		return points, pupils
+	}
+
+	tests := []struct {
+		name     string
+		baseline Landmarks
+		current  Landmarks
+		want     []int // 判定される表情・ジェスチャー
+	}{
+		{"neutral", landmarksWith(nil), landmarksWith(nil), nil},
+		{"open mouth", landmarksWith(nil), landmarksWith(openMouth), []int{constants.SURPRISED, constants.MOUTH_OPEN}},
+		{"open mouth without the bottom point", landmarksWith(nil), landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points, pupils = openMouth(points, pupils)
+			points[constants.B_MOUTH] = nil
+			return points, pupils
+		}), nil},
+		{"open mouth with an invalid point", landmarksWith(nil), landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points, pupils = openMouth(points, pupils)
+			points[constants.T_MOUTH] = []int{120}
+			return points, pupils
+		}), nil},
+		{"open mouth with a baseline missing the mouth", landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points[constants.T_MOUTH] = nil
+			return points, pupils
+		}), landmarksWith(openMouth), []int{constants.SURPRISED}},
+		{"all-zero current", landmarksWith(nil), landmarksWith(allZero), nil},
+		{"all-zero baseline", landmarksWith(allZero), landmarksWith(openMouth), []int{constants.SURPRISED}},
+		{"empty current", landmarksWith(nil), NewLandmarks(nil, nil), nil},
+		{"empty baseline", NewLandmarks(nil, nil), landmarksWith(openMouth), []int{constants.SURPRISED}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFace(tt.baseline)
+			f.Update(tt.current)
+
+			want := make([]bool, constants.EMOTION_COUNT)
+			for _, i := range tt.want {
+				want[i] = true
+			}
+			for i := range want {
+				if f.EmoteFlags[i] != want[i] {
+					t.Errorf("%s = %v, want %v", EmotionName(i), f.EmoteFlags[i], want[i])
+				}
+			}
+			for i, c := range f.Confidences {
+				if math.IsNaN(c) || c < 0 || c > 1 {
+					t.Errorf("Confidences[%s] = %v", EmotionName(i), c)
+				}
+			}
+			if f.Quality != tt.current.Quality() {
+				t.Errorf("Quality = %v, want %v", f.Quality, tt.current.Quality())
+			}
+		})
+	}
+}
+
+// 判定され始めた表情だけがイベントになり、Clear で全て解除される
+func TestFaceEvents(t *testing.T) {
+	f := NewFace(landmarksWith(nil))
+	surprised := landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+		points[constants.B_MOUTH] = []int{120, 240, 4}
+		return points, pupils
+	})
+
+	f.Update(surprised)
+	f.Update(surprised)
+	events := f.PopEvents()
+	if len(events) != 2 || events[0].Emotion != constants.SURPRISED || events[1].Emotion != constants.MOUTH_OPEN {
+		t.Errorf("events = %+v, want SURPRISED and MOUTH_OPEN once", events)
+	}
+
+	f.Clear()
+	if len(f.GetEmotionIndexes()) != 0 || f.Quality != 0 {
+		t.Errorf("after Clear: emotions %v, quality %v", f.GetEmotionIndexes(), f.Quality)
+	}
+}
+
+func TestPresence(t *testing.T) {
+	start := time.Unix(0, 0)
+	tests := []struct {
+		name     string
+		detected []bool // 100ms ごとの検出結果
+		wantLost bool
+	}{
+		{"never seen", []bool{false, false, false, false}, false},
+		{"briefly lost", []bool{true, false, false}, false},
+		{"lost past the timeout", []bool{true, false, false, false, false}, true},
+		{"found again", []bool{true, false, false, false, true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPresence(300 * time.Millisecond)
+			var now time.Time
+			for i, detected := range tt.detected {
+				now = start.Add(time.Duration(i) * 100 * time.Millisecond)
+				p.Update(detected, now)
+			}
+			if got := p.Lost(now); got != tt.wantLost {
+				t.Errorf("Lost = %v, want %v", got, tt.wantLost)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package game
+
+import (
+	"image/color"
+	"time"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/input"
+	"square-face-tetris/app/domain/wasm"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Controls はキーボード・ゲームパッド・タッチ・顔の入力を
+// Bindings に従って操作（input.Action）に変換する
+type Controls struct {
+	Bindings input.Bindings
+
+	// 頭の向きを入力に変換する
+	Gesture *input.GestureController
+
+	// 一度でもタッチされたら画面上のボタンを表示する
+	touchVisible bool
+
+	gamepadIDs []ebiten.GamepadID
+	touchIDs   []ebiten.TouchID
+}
+
+func NewControls(bindings input.Bindings) *Controls {
+	return &Controls{
+		Bindings: bindings,
+		Gesture:  input.NewGestureController(input.DefaultGestureConfig),
+	}
+}
+
+// 画面上のタッチボタン
+type touchButton struct {
+	action input.Action
+	label  string
+}
+
+var touchButtons = []touchButton{
+	{input.MoveLeft, "←"},
+	{input.MoveRight, "→"},
+	{input.SoftDrop, "↓"},
+	{input.HardDrop, "DROP"},
+	{input.RotateCCW, "CCW"},
+	{input.RotateCW, "CW"},
+	{input.Hold, "HOLD"},
+	{input.Pause, "II"},
+}
+
+const touchButtonHeight = 64
+
+// 入力の種類（ActionsFrom で組み合わせて指定する）
+type Source int
+
+const (
+	SourceKeyboard Source = 1 << iota
+	SourceGamepad
+	SourceTouch
+	SourceFace
+
+	SourceAll = SourceKeyboard | SourceGamepad | SourceTouch | SourceFace
+)
+
+// Actions はこのフレームで発生した操作を返す
+// events はこのフレームまでに発生した顔のイベント
+// 同じ操作は 1 フレームに 1 回だけ発生する
+func (c *Controls) Actions(events []domain.FaceEvent) []input.Action {
+	return c.ActionsFrom(SourceAll, events)
+}
+
+// ActionsFrom は sources に含まれる種類の入力から、このフレームで発生した操作を返す
+// 対戦でプレイヤーごとに入力の種類を分けるときに使う
+func (c *Controls) ActionsFrom(sources Source, events []domain.FaceEvent) []input.Action {
+	var actions []input.Action
+	seen := make(map[input.Action]bool)
+	add := func(action input.Action) {
+		if !seen[action] {
+			seen[action] = true
+			actions = append(actions, action)
+		}
+	}
+
+	// キーボード
+	if sources&SourceKeyboard != 0 {
+		for _, action := range c.keyboardActions() {
+			add(action)
+		}
+	}
+
+	// ゲームパッド
+	if sources&SourceGamepad != 0 {
+		for _, action := range c.gamepadActions() {
+			add(action)
+		}
+	}
+
+	// タッチ
+	if sources&SourceTouch != 0 {
+		for _, action := range c.touchActions() {
+			add(action)
+		}
+	}
+
+	// 顔（頭の向きと、表情・ジェスチャーのイベント）
+	if sources&SourceFace != 0 {
+		for _, action := range c.FaceActions(c.Gesture, wasm.HeadPose, events) {
+			add(action)
+		}
+	}
+
+	return actions
+}
+
+// FaceActions は 1 つの顔の頭の向きと表情・ジェスチャーのイベントを操作に変換する
+// 複数の顔で遊ぶときは、顔ごとの gesture と pose を渡す（pose が nil なら頭の向きは使わない）
+// 頭の向きはプレイヤーから見た左右で操作になり、これは左右反転したプレビューの見た目と一致する
+// プレビューを反転しない場合は、プレビューの見た目に合わせて左右を入れ替える
+func (c *Controls) FaceActions(gesture *input.GestureController, pose *domain.PoseEstimator, events []domain.FaceEvent) []input.Action {
+	var actions []input.Action
+	if pose != nil && pose.IsCalibrated() {
+		p := pose.Pose()
+		if !wasm.Mirrored() {
+			p = p.Mirror()
+		}
+		for _, g := range gesture.Update(p, time.Now()) {
+			actions = append(actions, input.ActionsFor(c.Bindings.Face, g.String())...)
+		}
+	}
+	for _, event := range events {
+		actions = append(actions, input.ActionsFor(c.Bindings.Face, domain.EmotionName(event.Emotion))...)
+	}
+	return actions
+}
+
+// keyboardActions はキーボードの入力を操作に変換する
+func (c *Controls) keyboardActions() []input.Action {
+	var actions []input.Action
+	for _, action := range input.Actions {
+		for _, name := range c.Bindings.Keyboard[action] {
+			var key ebiten.Key
+			if err := key.UnmarshalText([]byte(name)); err != nil {
+				continue
+			}
+			if action.IsHeld() && ebiten.IsKeyPressed(key) || inpututil.IsKeyJustPressed(key) {
+				actions = append(actions, action)
+			}
+		}
+	}
+	return actions
+}
+
+// gamepadActions はゲームパッドの入力を操作に変換する（標準配列のもののみ）
+func (c *Controls) gamepadActions() []input.Action {
+	var actions []input.Action
+	c.gamepadIDs = ebiten.AppendGamepadIDs(c.gamepadIDs[:0])
+	for _, id := range c.gamepadIDs {
+		if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+			continue
+		}
+		for _, action := range input.Actions {
+			for _, name := range c.Bindings.Gamepad[action] {
+				button, ok := gamepadButton(name)
+				if !ok {
+					continue
+				}
+				if action.IsHeld() && ebiten.IsStandardGamepadButtonPressed(id, button) ||
+					inpututil.IsStandardGamepadButtonJustPressed(id, button) {
+					actions = append(actions, action)
+				}
+			}
+		}
+	}
+	return actions
+}
+
+// touchActions は画面上のボタンへのタッチを操作に変換する
+func (c *Controls) touchActions() []input.Action {
+	var actions []input.Action
+
+	c.touchIDs = inpututil.AppendJustPressedTouchIDs(c.touchIDs[:0])
+	for _, id := range c.touchIDs {
+		c.touchVisible = true
+		if i, ok := touchButtonAt(ebiten.TouchPosition(id)); ok && !touchButtons[i].action.IsHeld() {
+			actions = append(actions, touchButtons[i].action)
+		}
+	}
+
+	// 押し続けている間発生する操作
+	c.touchIDs = ebiten.AppendTouchIDs(c.touchIDs[:0])
+	for _, id := range c.touchIDs {
+		if i, ok := touchButtonAt(ebiten.TouchPosition(id)); ok && touchButtons[i].action.IsHeld() {
+			actions = append(actions, touchButtons[i].action)
+		}
+	}
+	return actions
+}
+
+// touchButtonAt は座標にあるタッチボタンの番号を返す
+func touchButtonAt(x, y int) (int, bool) {
+	if y < constants.ScreenHeight-touchButtonHeight {
+		return 0, false
+	}
+	i := x * len(touchButtons) / constants.ScreenWidth
+	if i < 0 || i >= len(touchButtons) {
+		return 0, false
+	}
+	return i, true
+}
+
+// DrawTouchButtons は画面下部にタッチ操作用のボタンを描画する
+// 一度もタッチされていない場合は描画しない
+func (c *Controls) DrawTouchButtons(screen *ebiten.Image) {
+	if !c.touchVisible {
+		return
+	}
+
+	width := float32(constants.ScreenWidth) / float32(len(touchButtons))
+	top := float32(constants.ScreenHeight - touchButtonHeight)
+	for i, button := range touchButtons {
+		left := float32(i) * width
+		vector.DrawFilledRect(screen, left+2, top+2, width-4, touchButtonHeight-4, color.RGBA{255, 255, 255, 48}, false)
+
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(left+width/2), float64(top+touchButtonHeight/2))
+		op.PrimaryAlign = text.AlignCenter
+		op.SecondaryAlign = text.AlignCenter
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, button.label, &text.GoTextFace{
+			Source: mplusFaceSource,
+			Size:   normalFontSize,
+		}, op)
+	}
+}
+
+// gamepadButton はボタン名を ebiten の標準配列のボタンに変換する
+func gamepadButton(name string) (ebiten.StandardGamepadButton, bool) {
+	for i, n := range input.GamepadButtons {
+		if n == name {
+			return gamepadButtonOrder[i], true
+		}
+	}
+	return 0, false
+}
+
+// input.GamepadButtons の順に対応する ebiten のボタン
+var gamepadButtonOrder = []ebiten.StandardGamepadButton{
+	ebiten.StandardGamepadButtonRightBottom,
+	ebiten.StandardGamepadButtonRightRight,
+	ebiten.StandardGamepadButtonRightLeft,
+	ebiten.StandardGamepadButtonRightTop,
+	ebiten.StandardGamepadButtonFrontTopLeft,
+	ebiten.StandardGamepadButtonFrontTopRight,
+	ebiten.StandardGamepadButtonFrontBottomLeft,
+	ebiten.StandardGamepadButtonFrontBottomRight,
+	ebiten.StandardGamepadButtonCenterLeft,
+	ebiten.StandardGamepadButtonCenterRight,
+	ebiten.StandardGamepadButtonLeftStick,
+	ebiten.StandardGamepadButtonRightStick,
+	ebiten.StandardGamepadButtonLeftTop,
+	ebiten.StandardGamepadButtonLeftBottom,
+	ebiten.StandardGamepadButtonLeftLeft,
+	ebiten.StandardGamepadButtonLeftRight,
+	ebiten.StandardGamepadButtonCenterCenter,
+}
+
+// justPressedGamepadButton は押された瞬間のボタン名を返す（設定画面で使う）
+func (c *Controls) justPressedGamepadButton() (string, bool) {
+	c.gamepadIDs = ebiten.AppendGamepadIDs(c.gamepadIDs[:0])
+	for _, id := range c.gamepadIDs {
+		if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+			continue
+		}
+		for i, button := range gamepadButtonOrder {
+			if inpututil.IsStandardGamepadButtonJustPressed(id, button) {
+				return input.GamepadButtons[i], true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,222 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/input"
+	"square-face-tetris/app/domain/leaderboard"
+	"square-face-tetris/app/domain/wasm"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// 協力プレイのモード名（リプレイやランキングの区別に使う）
+const coopMode = leaderboard.ModeCoop
+
+// 複数の顔で遊ぶときの、1 人分の席
+// 追跡している顔の番号を割り当て、その顔の頭の向きと表情で操作する
+type faceSeat struct {
+	id      int // 割り当てた顔の番号（0 なら空席）
+	label   string
+	gesture *input.GestureController
+}
+
+func newFaceSeat(label string) *faceSeat {
+	return &faceSeat{label: label, gesture: input.NewGestureController(input.DefaultGestureConfig)}
+}
+
+// face は席に割り当てた顔を返す（空席や見失った顔なら nil）
+func (s *faceSeat) face() *wasm.TrackedFace {
+	if s.id == 0 {
+		return nil
+	}
+	return wasm.TrackedFaces[s.id]
+}
+
+// actions は席の顔の頭の向きと、表情・ジェスチャーのイベントを操作に変換する
+// pose を false にすると表情・ジェスチャーのイベントだけを使う
+func (s *faceSeat) actions(c *Controls, tracked map[int][]domain.FaceEvent, pose bool) []input.Action {
+	tf := s.face()
+	if tf == nil || !tf.Inited {
+		return nil
+	}
+	var estimator *domain.PoseEstimator
+	if pose && tf.Detected {
+		estimator = tf.HeadPose
+	}
+	return c.FaceActions(s.gesture, estimator, tracked[s.id])
+}
+
+// emotions は席の顔の表情の順位と信頼度を返す（顔が見つからなければ nil）
+func (s *faceSeat) emotions() ([]int, []float64) {
+	tf := s.face()
+	if tf == nil || !tf.Inited || !tf.Detected {
+		return nil, nil
+	}
+	return tf.Face.GetEmotionIndexes(), tf.Face.Confidences
+}
+
+// assignSeats は空いている席に、まだ席のない顔を見つけた順に割り当てる
+// 追跡が切れた顔の席は空ける
+func assignSeats(seats []*faceSeat) {
+	taken := make(map[int]bool, len(seats))
+	for _, s := range seats {
+		if s.id != 0 && s.face() == nil {
+			s.id = 0
+			s.gesture.Reset()
+		}
+		taken[s.id] = true
+	}
+
+	ids := wasm.TrackedFaceIDs()
+	for _, s := range seats {
+		if s.id != 0 {
+			continue
+		}
+		for _, id := range ids {
+			if !taken[id] {
+				s.id = id
+				taken[id] = true
+				break
+			}
+		}
+	}
+}
+
+// 2 つの顔で 1 つのボードを操作する協力プレイの状態
+// mover の頭の向きでテトリミノを動かし、chooser の表情で次のテトリミノを選ぶ
+type coop struct {
+	mover   *faceSeat
+	chooser *faceSeat
+}
+
+// startCoop は協力プレイを始める
+func (g *GameWrapper) startCoop() {
+	g.coop = &coop{
+		mover:   newFaceSeat("移動"),
+		chooser: newFaceSeat("選択"),
+	}
+	if err := g.ResetGame(); err != nil {
+		g.coop = nil
+	}
+}
+
+func (c *coop) seats() []*faceSeat {
+	return []*faceSeat{c.mover, c.chooser}
+}
+
+// mode は遊んでいるモードの名前を返す
+func (g *GameWrapper) mode() string {
+	if g.coop != nil {
+		return coopMode
+	}
+	return gameMode
+}
+
+// faceSeats は遊んでいるモードの顔の席を返す（1 つの顔で遊ぶモードなら nil）
+func (g *GameWrapper) faceSeats() []*faceSeat {
+	switch {
+	case g.coop != nil:
+		return g.coop.seats()
+	case g.versus != nil && g.versus.faces:
+		return g.versus.seats()
+	}
+	return nil
+}
+
+// requiredFaces は遊んでいるモードが必要とする顔の数を返す
+func (g *GameWrapper) requiredFaces() int {
+	return max(1, len(g.faceSeats()))
+}
+
+// playingActions はプレイ中の操作を返す
+// 協力プレイでは顔以外の入力に、mover の頭の向きと表情・ジェスチャーの操作を加える
+func (g *GameWrapper) playingActions(events []domain.FaceEvent) []input.Action {
+	if g.coop == nil {
+		return g.Controls.ActionsFrom(g.faceSources(), events)
+	}
+	assignSeats(g.coop.seats())
+	actions := g.Controls.ActionsFrom(SourceAll&^SourceFace, nil)
+	return mergeActions(actions, g.coop.mover.actions(g.Controls, g.trackedEvents, true))
+}
+
+// playingEmotions は次のテトリミノを選ぶ表情の順位と信頼度を返す
+func (g *GameWrapper) playingEmotions() ([]int, []float64) {
+	if g.coop == nil {
+		return wasm.Face.GetEmotionIndexes(), wasm.Face.Confidences
+	}
+	return g.coop.chooser.emotions()
+}
+
+// mergeActions は actions に、まだ含まれていない extra の操作を加える
+func mergeActions(actions, extra []input.Action) []input.Action {
+	for _, action := range extra {
+		found := false
+		for _, a := range actions {
+			if a == action {
+				found = true
+				break
+			}
+		}
+		if !found {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// プレイ中の顔の席の描画
+func (g *GameWrapper) drawFaceSeats(screen *ebiten.Image) {
+	switch g.Game.State {
+	case "playing", "paused", "versus":
+		drawSeats(screen, g.faceSeats())
+	}
+}
+
+// drawSeats はカメラのプレビューの顔の上に、割り当てた席の名前を描画する
+// 空席がある場合はプレビューの下に案内を表示する
+func drawSeats(screen *ebiten.Image, seats []*faceSeat) {
+	face := &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}
+	waiting := 0
+	for _, s := range seats {
+		tf := s.face()
+		if tf == nil {
+			waiting++
+			continue
+		}
+		if len(tf.Box) < 3 {
+			continue
+		}
+		row, col, size := tf.Box[0], tf.Box[1], tf.Box[2]
+		x, y, ok := wasm.PreviewPosition(col, row-size/2)
+		if !ok {
+			continue
+		}
+		clr := color.RGBA{255, 255, 0, 255}
+		if !tf.Detected {
+			clr = color.RGBA{160, 160, 160, 255}
+		}
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(x, y)
+		op.PrimaryAlign = text.AlignCenter
+		op.SecondaryAlign = text.AlignEnd
+		op.ColorScale.ScaleWithColor(clr)
+		text.Draw(screen, s.label, face, op)
+	}
+
+	if waiting == 0 {
+		return
+	}
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(constants.ScreenWidth-8, 232)
+	op.PrimaryAlign = text.AlignEnd
+	op.ColorScale.ScaleWithColor(color.RGBA{255, 255, 0, 255})
+	text.Draw(screen, fmt.Sprintf("あと %d 人、カメラに顔を映してください", waiting), face, op)
+}
@@ -2,18 +2,22 @@ package game
 
 import (
 	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
 	"square-face-tetris/app/domain/wasm"
 
 	"fmt"
 	"image/color"
-	"time"
+	"math"
+	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 const (
+	smallFontSize  = 16
 	normalFontSize = 24
 	bigFontSize    = 48
 	x              = 20
@@ -26,24 +30,70 @@ func (g *GameWrapper) Draw(screen *ebiten.Image) {
 		g.drawStart(screen)
 	case "playing":
 		g.drawPlaying(screen)
+		g.drawFaceLost(screen)
+	case "faceLost":
+		g.drawPlaying(screen)
+		g.drawFaceLost(screen)
+	case "paused":
+		g.drawPlaying(screen)
+		g.drawPaused(screen)
+	case "keyconfig":
+		g.drawKeyConfig(screen)
+	case "replay":
+		g.drawPlaying(screen)
+		g.drawReplay(screen)
+	case "settings":
+		g.drawSettings(screen)
+	case "versus":
+		g.drawVersus(screen)
+	case "versusResult":
+		g.drawVersusResult(screen)
+	case "onlineWaiting":
+		g.drawOnlineWaiting(screen)
+	case "spectate":
+		g.drawSpectate(screen)
+	case "nameEntry":
+		g.drawNameEntry(screen)
+	case "leaderboard":
+		g.drawLeaderboard(screen)
 	case "showingScore":
 		g.drawScore(screen)
 	}
 
 	wasm.DrawCameraPrev(screen)
+	g.drawFaceSeats(screen)
+	g.drawCameraError(screen)
+	g.drawRecorder(screen)
 }
 
-var InstructionText = []string{
-	"操作方法",    // 1行目
-	"移動: ←↓→", // 2行目
-	"回転: ↑",   // 3行目
+// データセット記録中の表示
+func (g *GameWrapper) drawRecorder(screen *ebiten.Image) {
+	if !wasm.SampleRecorder.IsRecording() {
+		return
+	}
+
+	recText := fmt.Sprintf("● REC %s (%d)", wasm.SampleRecorder.Label(), wasm.SampleRecorder.Len())
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(x, constants.ScreenHeight-40)
+	op.ColorScale.ScaleWithColor(color.RGBA{255, 64, 64, 255})
+	text.Draw(screen, recText, &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   normalFontSize,
+	}, op)
 }
 
-var EmoText = []string{
-	"SMILE",
-	"ANGRY",
-	"SURPRISED",
-	"SUS",
+var InstructionText = []string{
+	"操作方法", // 1行目
+	"移動: ←→  ソフトドロップ: ↓  ハードドロップ: Space",
+	"回転: ↑ X  逆回転: Z  ホールド: C",
+	"ポーズ: Esc P  ヒント: H",
+	"顔: 左右を向いて移動、下を向いてソフトドロップ",
+	"    頭を傾けて回転、ウインクで移動",
+	"",
+	"B: 2 人対戦  C: CPU 対戦  O: 通信対戦",
+	"M: 2 つの顔で協力  N: 2 つの顔で対戦",
+	"K: キー設定  S: 設定  L: ランキング",
+	"V: 前回のリプレイ  W: 観戦",
 }
 
 // スコア画面の描画
@@ -62,7 +112,7 @@ func (g *GameWrapper) drawStart(screen *ebiten.Image) {
 	}, op3)
 
 	// リスタートの指示を表示
-	startText := "スペースキーを押してスタート"
+	startText := "スペース: スタート"
 	op4 := &text.DrawOptions{}
 	op4.GeoM.Translate(x, 100)
 	op4.ColorScale.ScaleWithColor(color.White)
@@ -90,21 +140,7 @@ func (g *GameWrapper) drawPlaying(screen *ebiten.Image) {
 	screen.Fill(color.RGBA{0, 0, 64, 255}) // 紺色
 
 	// 残り時間を計算
-	remainingTime := g.Game.TimeLimit - time.Since(g.Game.StartTime)
-	if remainingTime < 0 {
-		remainingTime = 0
-	}
-
-	op7 := &text.DrawOptions{}
-	op7.GeoM.Translate(constants.BoardWidth*constants.BlockSize+ constants.BlockSize*5, 140)
-	op7.ColorScale.ScaleWithColor(color.White)
-	for _, line := range EmoText {
-		op7.GeoM.Translate(0, float64(constants.BlockSize)*3) // 各行の縦位置をずらす
-		text.Draw(screen, line, &text.GoTextFace{
-			Source: mplusFaceSource,
-			Size:   normalFontSize,
-		}, op7)
-	}
+	remainingTime := g.Game.RemainingTime()
 
 	// 秒数に変換
 	totalSeconds := remainingTime.Seconds()
@@ -134,39 +170,123 @@ func (g *GameWrapper) drawPlaying(screen *ebiten.Image) {
 		Size:   normalFontSize,
 	}, op2)
 
-
-	// ボードの描画（固定されたブロック）
-	for y := 0; y < constants.BoardHeight; y++ {
-		for x := 0; x < constants.BoardWidth; x++ {
-			if g.Game.Board[y][x] == 1 {
-				blockImage := ebiten.NewImage(constants.BlockSize, constants.BlockSize)
-				blockImage.Fill(color.RGBA{0, 0, 255, 255}) // 青
-				opts := &ebiten.DrawImageOptions{}
-				opts.GeoM.Translate(float64(x*constants.BlockSize), float64(y*constants.BlockSize))
-				screen.DrawImage(blockImage, opts)
-			}
-		}
-
-		ebitenutil.DebugPrint(screen, fmt.Sprintf("%f", ebiten.ActualFPS()))
+	// ボードの描画
+	drawBoard(screen, &g.boardLayer, g.Game.Board, g.Game.Current, 0, 0, constants.BlockSize)
+	if g.Game.State == "playing" {
+		g.drawHint(screen)
 	}
+	ebitenutil.DebugPrint(screen, fmt.Sprintf("%f", ebiten.ActualFPS()))
 
 	g.DrawNextTetromino(screen)
 	g.DrawAfterNextTetromino(screen)
+	g.drawHoldTetromino(screen)
+	g.drawHeadPose(screen)
+	g.drawTracking(screen)
+	g.Controls.DrawTouchButtons(screen)
+}
 
-	// 現在のテトリミノの描画
-	if g.Game.Current != nil {
-		for y := 0; y < len(g.Game.Current.Shape); y++ {
-			for x := 0; x < len(g.Game.Current.Shape[y]); x++ {
-				if g.Game.Current.Shape[y][x] == 1 {
-					blockImage := ebiten.NewImage(constants.BlockSize, constants.BlockSize)
-					blockImage.Fill(g.Game.Current.Color)
-					opts := &ebiten.DrawImageOptions{}
-					opts.GeoM.Translate(float64((g.Game.Current.X+x)*constants.BlockSize), float64((g.Game.Current.Y+y)*constants.BlockSize))
-					screen.DrawImage(blockImage, opts)
-				}
-			}
-		}
+// 頭の向きの表示
+// 枠内の点が左右（yaw）・上下（pitch）の向き、線が傾き（roll）を表す
+func (g *GameWrapper) drawHeadPose(screen *ebiten.Image) {
+	if !wasm.HeadPose.IsCalibrated() {
+		return
+	}
+
+	const (
+		size     = 56
+		maxAngle = 0.6 // 枠の端に対応する角度（ラジアン）
+	)
+	left := float32(constants.ScreenWidth - size - 8)
+	top := float32(128)
+	cx, cy := left+size/2, top+size/2
+	pose := wasm.HeadPose.Pose()
+	if wasm.Mirrored() {
+		pose = pose.Mirror() // プレビューと同じ向きで表示する
+	}
+
+	vector.StrokeRect(screen, left, top, size, size, 1, color.White, false)
+
+	// roll: 中心を通る線
+	dx := float32(math.Cos(pose.Roll)) * size / 2
+	dy := float32(math.Sin(pose.Roll)) * size / 2
+	vector.StrokeLine(screen, cx-dx, cy-dy, cx+dx, cy+dy, 1, color.RGBA{128, 128, 255, 255}, true)
+
+	// yaw, pitch: 点の位置
+	px := cx + float32(clamp(pose.Yaw/maxAngle, -1, 1))*size/2
+	py := cy + float32(clamp(pose.Pitch/maxAngle, -1, 1))*size/2
+	vector.DrawFilledCircle(screen, px, py, 4, color.RGBA{255, 64, 64, 255}, true)
+}
+
+// 顔の追跡の状態の表示
+// 検出できたランドマークと瞳の割合をバーで、使えなかった理由を文字で表す
+func (g *GameWrapper) drawTracking(screen *ebiten.Image) {
+	if !g.Settings.Camera {
+		return
+	}
+
+	const (
+		width  = 56
+		height = 6
+	)
+	left := float32(constants.ScreenWidth - width - 8)
+	top := float32(192)
+	tracking := wasm.Tracking
+
+	label, clr := "追跡", color.RGBA{64, 192, 64, 255}
+	switch {
+	case tracking.Faces == 0:
+		label, clr = "顔なし", color.RGBA{192, 64, 64, 255}
+	case tracking.Rejected == "pupils":
+		label, clr = "瞳なし", color.RGBA{192, 64, 64, 255}
+	case tracking.Rejected != "":
+		label, clr = "点不足", color.RGBA{192, 64, 64, 255}
+	case tracking.Quality < 1:
+		clr = color.RGBA{224, 192, 64, 255}
+	}
+
+	vector.StrokeRect(screen, left, top, width, height, 1, color.White, false)
+	vector.DrawFilledRect(screen, left, top, float32(tracking.Quality)*width, height, clr, false)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(float64(left), float64(top+height+2))
+	op.ColorScale.ScaleWithColor(clr)
+	text.Draw(screen, label, &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}, op)
+}
+
+// カメラを開始できなかったときの表示（プレビューの位置に表示する）
+func (g *GameWrapper) drawCameraError(screen *ebiten.Image) {
+	message := wasm.CameraError()
+	if !g.Settings.Camera || message == "" {
+		return
 	}
+
+	lines := strings.Split(message, "\n")
+	const (
+		width      = 300
+		lineHeight = 22
+	)
+	left := float32(constants.ScreenWidth - width - 8)
+	height := float32(len(lines)*lineHeight + 16)
+	vector.DrawFilledRect(screen, left, 8, width, height, color.RGBA{64, 0, 0, 224}, false)
+	vector.StrokeRect(screen, left, 8, width, height, 1, color.RGBA{255, 64, 64, 255}, false)
+
+	face := &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize - 2,
+	}
+	for i, line := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(left)+8, float64(16+i*lineHeight))
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, line, face, op)
+	}
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
 }
 
 // 次のテトロミノの描画
@@ -174,56 +294,94 @@ func (g *GameWrapper) DrawNextTetromino(screen *ebiten.Image) {
 	// 「Next」のラベルを描画
 	emotionText := fmt.Sprintf("%s", g.Game.DrawedEmote)
 	op6 := &text.DrawOptions{}
-	op6.GeoM.Translate(constants.BoardWidth*constants.BlockSize + constants.BlockSize, 32)
+	op6.GeoM.Translate(float64(g.Game.Board.Width()*constants.BlockSize+constants.BlockSize), 32)
 	op6.ColorScale.ScaleWithColor(color.White)
 	text.Draw(screen, emotionText, &text.GoTextFace{
 		Source: mplusFaceSource,
 		Size:   normalFontSize,
 	}, op6)
 
-
 	// 次のテトロミノの描画
-	if g.Game.Next[0] != nil {
-		for y := 0; y < len(g.Game.Next[0].Shape); y++ {
-			for x := 0; x < len(g.Game.Next[0].Shape[y]); x++ {
-				if g.Game.Next[0].Shape[y][x] == 1 {
-					blockImage := ebiten.NewImage(constants.BlockSize, constants.BlockSize)
-					blockImage.Fill(g.Game.Next[0].Color) // 次のテトロミノの色
-					opts := &ebiten.DrawImageOptions{}
-					opts.GeoM.Translate(
-						float64(constants.BoardWidth*constants.BlockSize+ constants.BlockSize +(x*constants.BlockSize)),
-						float64(64+(y*constants.BlockSize)),
-					)
-					screen.DrawImage(blockImage, opts)
-				}
-			}
-		}
+	if next := g.Game.Next[0]; next != nil {
+		drawShape(screen, next.Shape, next.Color,
+			float64(g.Game.Board.Width()*constants.BlockSize+constants.BlockSize), 64, constants.BlockSize, constants.BlockSize)
+	}
+}
+
+// ホールド中のテトロミノを描画（小さく表示する）
+func (g *GameWrapper) drawHoldTetromino(screen *ebiten.Image) {
+	const cellSize = constants.BlockSize / 2
+	left := float64(g.Game.Board.Width()*constants.BlockSize + constants.BlockSize*5)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(left, 32)
+	op.ColorScale.ScaleWithColor(color.White)
+	if g.Game.HoldUsed {
+		op.ColorScale.ScaleAlpha(0.5)
+	}
+	text.Draw(screen, "HOLD", &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}, op)
+
+	hold := g.Game.Hold
+	if hold == nil {
+		return
 	}
+	drawShape(screen, hold.Shape, hold.Color, left, 64, cellSize, cellSize)
+}
+
+// ポーズ中の表示
+func (g *GameWrapper) drawPaused(screen *ebiten.Image) {
+	vector.DrawFilledRect(screen, 0, 0, constants.ScreenWidth, constants.ScreenHeight, color.RGBA{0, 0, 0, 160}, false)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(constants.ScreenWidth/2, constants.ScreenHeight/2)
+	op.PrimaryAlign = text.AlignCenter
+	op.SecondaryAlign = text.AlignCenter
+	op.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, "PAUSE", &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   bigFontSize,
+	}, op)
 }
 
 // 次の次のテトロミノを描画
+// 表情ごとの候補を、対応する表情の名前と並べて縦に描画する
 func (g *GameWrapper) DrawAfterNextTetromino(screen *ebiten.Image) {
+	spacing, cellSize := g.candidateLayout()
+
+	for i := 1; i < len(g.Game.Next); i++ {
+		top := float64(64+128) + float64(i-1)*spacing
+
+		// 候補に対応する表情の名前
+		if i-1 < len(g.Game.ChoiceEmotions) {
+			op := &text.DrawOptions{}
+			op.GeoM.Translate(float64(g.Game.Board.Width()*constants.BlockSize+constants.BlockSize*5), top)
+			op.ColorScale.ScaleWithColor(color.White)
+			text.Draw(screen, domain.EmotionName(g.Game.ChoiceEmotions[i-1]), &text.GoTextFace{
+				Source: mplusFaceSource,
+				Size:   normalFontSize,
+			}, op)
+		}
 
-	// 1から4まで次のテトロミノを描画
-	for i := 1; i <= 5 && i <= len(g.Game.Next); i++ {
-		if g.Game.Next[i] != nil {
-			for y := 0; y < len(g.Game.Next[i].Shape); y++ {
-				for x := 0; x < len(g.Game.Next[i].Shape[y]); x++ {
-					if g.Game.Next[i].Shape[y][x] == 1 {
-						blockImage := ebiten.NewImage(constants.BlockSize, constants.BlockSize)
-						blockImage.Fill(g.Game.Next[i].Color) // 次のテトロミノの色
-						opts := &ebiten.DrawImageOptions{}
-						opts.GeoM.Translate(
-							float64(constants.BoardWidth*constants.BlockSize+constants.BlockSize+(x*constants.BlockSize)),
-							float64(64+(i)*128+(y*constants.BlockSize)), // Y座標をiに基づいて調整
-						)
-						screen.DrawImage(blockImage, opts)
-					}
-				}
-			}
+		if next := g.Game.Next[i]; next != nil {
+			drawShape(screen, next.Shape, next.Color,
+				float64(g.Game.Board.Width()*constants.BlockSize+constants.BlockSize), top, cellSize, int(cellSize))
 		}
 	}
+}
 
+// candidateLayout は候補の数に合わせて、候補どうしの間隔とブロックの大きさを決める
+// 候補が多く画面に収まらない場合は縮小して描画する
+func (g *GameWrapper) candidateLayout() (spacing, cellSize float64) {
+	spacing = 128
+	if n := len(g.Game.Next) - 1; n > 0 {
+		if available := float64(constants.ScreenHeight-64-128) / float64(n); available < spacing {
+			spacing = available
+		}
+	}
+	return spacing, constants.BlockSize * spacing / 128
 }
 
 // スコア画面の描画
@@ -242,7 +400,7 @@ func (g *GameWrapper) drawScore(screen *ebiten.Image) {
 	}, op3)
 
 	// リスタートの指示を表示
-	restartText := "スペースを押して再スタート"
+	restartText := "スペースを押して再スタート  V: リプレイ  D: リプレイを保存"
 	op4 := &text.DrawOptions{}
 	op4.GeoM.Translate(x, 100)
 	op4.ColorScale.ScaleWithColor(color.White)
@@ -260,4 +418,28 @@ func (g *GameWrapper) drawScore(screen *ebiten.Image) {
 		Source: mplusFaceSource,
 		Size:   normalFontSize,
 	}, op5)
+
+	// ランキングに入った場合は順位を表示
+	if g.ranking.rank > 0 {
+		rankText := fmt.Sprintf("ランキング %d 位!", g.ranking.rank)
+		op6 := &text.DrawOptions{}
+		op6.GeoM.Translate(x, 180)
+		op6.ColorScale.ScaleWithColor(color.RGBA{255, 255, 0, 255})
+		text.Draw(screen, rankText, &text.GoTextFace{
+			Source: mplusFaceSource,
+			Size:   normalFontSize,
+		}, op6)
+	}
+
+	// 統計
+	g.drawReport(screen, 220)
+
+	reportText := "J/C: 統計を JSON/CSV で保存  T: 表情の時系列  G: ハイライト GIF"
+	op7 := &text.DrawOptions{}
+	op7.GeoM.Translate(x, float64(constants.ScreenHeight-40))
+	op7.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, reportText, &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}, op7)
 }
@@ -0,0 +1,143 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/input"
+	"square-face-tetris/app/domain/wasm"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// 顔が戻ってから再開するまでの時間
+const faceLostCountdown = 3 * time.Second
+
+// プレイ中に顔を見失ったときの状態
+type faceLost struct {
+	keyboard bool      // キーボードだけで操作している（設定が "keyboard" の場合）
+	resumeAt time.Time // 顔が戻って再開する時刻（ゼロ値なら顔はまだ戻っていない）
+}
+
+// checkFaceLost は設定に合わせて、顔を見失ったら一時停止するか、キーボードだけの操作に切り替える
+// 一時停止した場合は true を返す
+func (g *GameWrapper) checkFaceLost() bool {
+	if !g.Settings.Camera {
+		return false
+	}
+
+	switch g.Settings.FaceLostAction {
+	case "pause":
+		if wasm.FaceLost() {
+			g.faceLost = faceLost{}
+			g.Game.State = "faceLost"
+			return true
+		}
+	case "keyboard":
+		fl := &g.faceLost
+		if wasm.FaceLost() {
+			fl.keyboard = true
+			fl.resumeAt = time.Time{}
+		} else if fl.keyboard && g.countDown() {
+			// 見失っている間の頭の向きで動き出さないよう、顔の操作は離した状態から始める
+			fl.keyboard = false
+			g.Controls.Gesture.Reset()
+		}
+	}
+	return false
+}
+
+// 顔を見失って一時停止している状態を更新
+// 顔が戻ってからカウントダウンして再開する。ポーズの操作で通常のポーズ画面に切り替える
+func (g *GameWrapper) updateFaceLost(events []domain.FaceEvent) {
+	for _, action := range g.Controls.ActionsFrom(SourceAll&^SourceFace, events) {
+		if action == input.Pause {
+			g.Game.Pause()
+			return
+		}
+	}
+
+	if wasm.FaceLost() {
+		g.faceLost.resumeAt = time.Time{}
+		return
+	}
+	if g.countDown() {
+		g.Controls.Gesture.Reset()
+		g.Game.Resume()
+	}
+}
+
+// countDown は顔が戻ってからのカウントダウンを進め、終わったら true を返す
+func (g *GameWrapper) countDown() bool {
+	fl := &g.faceLost
+	if fl.resumeAt.IsZero() {
+		fl.resumeAt = time.Now().Add(faceLostCountdown)
+	}
+	return !time.Now().Before(fl.resumeAt)
+}
+
+// faceSources は顔を見失っている間、顔の入力を除いた入力の種類を返す
+func (g *GameWrapper) faceSources() Source {
+	if g.faceLost.keyboard {
+		return SourceAll &^ SourceFace
+	}
+	return SourceAll
+}
+
+// 顔を見失ったときの表示
+// 一時停止中は画面全体に、キーボードで続けている間は画面の下に表示する
+func (g *GameWrapper) drawFaceLost(screen *ebiten.Image) {
+	fl := g.faceLost
+	if g.Game.State != "faceLost" && !fl.keyboard {
+		return
+	}
+
+	message := "顔が見つかりません"
+	if !fl.resumeAt.IsZero() {
+		remaining := math.Ceil(time.Until(fl.resumeAt).Seconds())
+		message = fmt.Sprintf("再開まで %d", int(math.Max(remaining, 1)))
+	}
+
+	if g.Game.State != "faceLost" {
+		const height = 40
+		top := float32(constants.ScreenHeight - touchButtonHeight - height)
+		vector.DrawFilledRect(screen, 0, top, constants.ScreenWidth, height, color.RGBA{0, 0, 0, 160}, false)
+
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(constants.ScreenWidth/2, float64(top)+height/2)
+		op.PrimaryAlign = text.AlignCenter
+		op.SecondaryAlign = text.AlignCenter
+		op.ColorScale.ScaleWithColor(color.RGBA{255, 255, 0, 255})
+		text.Draw(screen, message+"（キーボードで操作中）", &text.GoTextFace{
+			Source: mplusFaceSource,
+			Size:   smallFontSize,
+		}, op)
+		return
+	}
+
+	vector.DrawFilledRect(screen, 0, 0, constants.ScreenWidth, constants.ScreenHeight, color.RGBA{0, 0, 0, 160}, false)
+	lines := []struct {
+		text string
+		size float64
+	}{
+		{message, bigFontSize},
+		{"カメラに顔を映すと再開します  Esc: ポーズ", smallFontSize},
+	}
+	for i, line := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(constants.ScreenWidth/2, constants.ScreenHeight/2+float64(i*56))
+		op.PrimaryAlign = text.AlignCenter
+		op.SecondaryAlign = text.AlignCenter
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, line.text, &text.GoTextFace{
+			Source: mplusFaceSource,
+			Size:   line.size,
+		}, op)
+	}
+}
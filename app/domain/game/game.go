@@ -1,34 +1,31 @@
 package game
 
 import (
-	"square-face-tetris/app/domain"
-	"time"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/leaderboard"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
+// ゲームのモード名（リプレイやランキングの区別に使う）
+const gameMode = leaderboard.ModeNormal
+
 // ゲームの状態
+// ボードやテトリミノなどのルールに関わる状態は engine.Engine が持つ
 type Game struct {
-	Board        domain.Board               // 10x20 のボード
-	Current      *Tetromino          // 現在のテトリミノ
-	Next         []*Tetromino        // 次のテトリミノ
-	LastDrop     time.Time           // 最後にテトリミノが落下した時刻
-	DropInterval time.Duration       // 落下間隔
-	KeyState     map[ebiten.Key]bool // キーの押下状態
-	CanvasImage  *ebiten.Image       // canvas から取得した画像を保持するフィールドを追加
-	StartTime    time.Time           // ゲーム開始時刻
-	TimeLimit    time.Duration       // タイムリミット
-	State        string              // ゲームの状態
-	Score        int                 // スコア
-	DrawedEmote  string
+	*engine.Engine
+
+	CanvasImage *ebiten.Image // canvas から取得した画像を保持するフィールドを追加
+	State       string        // ゲームの状態
 }
 
-// キーが離された場合に状態をリセット
-func (g *Game) ResetKeyState() {
-	for key := range g.KeyState {
-		if !ebiten.IsKeyPressed(key) {
-			g.KeyState[key] = false // キーが離されたら状態をリセット
-		}
-	}
+// Pause はゲームを一時停止する
+// エンジンはフレーム単位で進むため、止めている間は Step を呼ばないだけでよい
+func (g *Game) Pause() {
+	g.State = "paused"
 }
 
+// Resume は一時停止を解除する
+func (g *Game) Resume() {
+	g.State = "playing"
+}
@@ -0,0 +1,63 @@
+package game
+
+import (
+	"image/color"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain/bot"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// ヒントの枠の色
+var hintColor = color.RGBA{255, 255, 255, 160}
+
+// updateHint は H キーでヒントの表示を切り替える
+func (g *GameWrapper) updateHint() {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyH) {
+		return
+	}
+	if g.hint == nil {
+		g.hint = bot.New()
+	} else {
+		g.hint = nil
+	}
+}
+
+// drawHint は CPU が選ぶ置き場所を枠で描画する
+// ホールドと入れ替えた方が良い場合は、その置き場所と「ホールド」を表示する
+func (g *GameWrapper) drawHint(screen *ebiten.Image) {
+	if g.hint == nil || g.Game.Current == nil {
+		return
+	}
+	p, ok := g.hint.Best(g.Game.Engine)
+	if !ok {
+		return
+	}
+
+	const size = constants.BlockSize
+	for y, row := range p.Shape {
+		for x, cell := range row {
+			if cell != 0 {
+				vector.StrokeRect(screen, float32((p.X+x)*size)+1, float32((p.Y+y)*size)+1, size-2, size-2, 2, hintColor, false)
+			}
+		}
+	}
+
+	if p.Hold {
+		top := p.Y*size - smallFontSize - 4
+		if top < 0 {
+			top = 0
+		}
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(p.X*size), float64(top))
+		op.ColorScale.ScaleWithColor(hintColor)
+		text.Draw(screen, "ホールド", &text.GoTextFace{
+			Source: mplusFaceSource,
+			Size:   smallFontSize,
+		}, op)
+	}
+}
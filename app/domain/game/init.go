@@ -2,18 +2,79 @@ package game
 
 import (
 	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/bot"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/highlight"
+	"square-face-tetris/app/domain/input"
+	"square-face-tetris/app/domain/leaderboard"
+	"square-face-tetris/app/domain/netplay"
+	"square-face-tetris/app/domain/replay"
+	"square-face-tetris/app/domain/report"
+	"square-face-tetris/app/domain/settings"
+	"square-face-tetris/app/domain/timeline"
 
 	"bytes"
+	"log"
 	"time"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/examples/resources/fonts"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 )
 
-
 type GameWrapper struct {
 	Game Game
+
+	// ゲームの設定
+	Settings     settings.Settings
+	settingsMenu settingsMenu
+
+	// プレイ中のゲームの入力の記録と、最後に終了したゲームの記録
+	recorder   *replay.Recorder
+	LastReplay *replay.Replay
+	replayView *replayView
+
+	// 終了したゲームの統計と、統計のために数えている顔を検出できたフレーム数
+	Report     *report.Report
+	faceFrames int
+
+	// 表情と出来事の時系列と、見どころの記録
+	Timeline   *timeline.Timeline
+	highlights *highlight.Recorder
+
+	// 2 人対戦の状態（対戦中以外は nil）
+	versus *versus
+
+	// 2 つの顔で 1 つのボードを操作する協力プレイの状態（協力プレイ以外は nil）
+	coop *coop
+
+	// 追跡している顔ごとの、このフレームまでに発生した顔のイベント
+	trackedEvents map[int][]domain.FaceEvent
+
+	// プレイ中に顔を見失ったときの状態
+	faceLost faceLost
+
+	// プレイ中のヒント（H キーで表示したときだけ作る）
+	hint *bot.Bot
+
+	// 観戦用の配信（設定で有効なときだけ作る）と、観戦画面で受け取っている状態
+	publisher     *netplay.Publisher
+	publishFailed bool
+	spectator     *netplay.Spectator
+	spectatePage  int // 観戦画面で表示しているページ（0 から）
+
+	// 固定されたブロックを描画した画像（プレイ中のボードと、観戦中のゲームごと）
+	boardLayer     boardLayer
+	spectateLayers map[string]*boardLayer
+
+	// モードごとの上位の成績と、その入力・表示の状態
+	Leaderboard leaderboard.Leaderboard
+	ranking     ranking
+
+	// 各デバイスの入力を操作に変換する
+	Controls *Controls
+
+	keyConfig keyConfig
 }
 
 var (
@@ -25,46 +86,47 @@ func (g *GameWrapper) Init() error {
 	// ゲーム全体の初期設定
 	s, err := text.NewGoTextFaceSource(bytes.NewReader(fonts.MPlus1pRegular_ttf))
 	if err != nil {
-			return err
+		return err
 	}
 	mplusFaceSource = s
+
+	// キー設定を読み込む
+	bindings, err := input.LoadBindings()
+	if err != nil {
+		log.Printf("キー設定の読み込みに失敗したため、初期設定を使います: %v", err)
+	}
+	g.Controls = NewControls(bindings)
+
+	// ランキングを読み込む
+	g.Leaderboard, err = leaderboard.Load()
+	if err != nil {
+		log.Printf("ランキングの読み込みに失敗しました: %v", err)
+	}
+
 	g.Game.State = "start"
 	return nil
 }
 
 func (g *GameWrapper) ResetGame() error {
-    // ゲームごとの状態をリセット
-    g.Game.Board.Init()                     // ボードの初期化
-    g.Game.StartTime = time.Now()           // 開始時刻の設定
-    g.Game.TimeLimit = 3 * time.Minute     // タイムリミットの設定
-    g.Game.State = "playing"                // 状態のリセット
-    g.Game.KeyState = make(map[ebiten.Key]bool) // キー状態のリセット
-    g.Game.Current = g.Game.GenerateRandomTetromino() // 現在のテトリミノ
-    // Next[0]からNext[5]までを生成
-		g.Game.Next = make([]*Tetromino, 6)
-		g.Game.Next[0] = g.Game.GenerateRandomTetromino()
-		g.Game.Next[1], g.Game.Next[2], g.Game.Next[3], g.Game.Next[4] = g.Game.GenerateUniqueTetrominos()
-    g.Game.Score = 0
-		g.Game.DrawedEmote = ""
-
-		// wasm.ResetFaceSnapshot()
-
-		return nil
-}
-
-func (g *GameWrapper) NewTetromino() error {
 	// ゲームごとの状態をリセット
-	g.Game.State = "playing"                // 状態のリセット
-	g.Game.KeyState = make(map[ebiten.Key]bool) // キー状態のリセット
-	g.Game.ShiftTetrominoQueue()                  // テトリミノを生成
-	g.Game.Score = 0                       // スコアのリセット
+	g.Game.Engine = engine.New(time.Now().UnixNano(), g.Settings.EngineOptions()) // ボードとテトリミノの初期化
+	g.Game.State = "playing"                                                      // 状態のリセット
+
+	// 入力の記録を開始
+	g.recorder = replay.NewRecorder(g.mode(), g.Game.Engine)
+	g.faceFrames = 0
+	g.Timeline = &timeline.Timeline{}
+	g.highlights = highlight.NewRecorder()
+	g.faceLost = faceLost{}
+
+	// wasm.ResetFaceSnapshot()
+
 	return nil
 }
 
-
 // レイアウトの設定（ウィンドウのサイズ）
 func (g *GameWrapper) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
-	screenWidth = constants.ScreenWidth  // 画面幅を640に設定
+	screenWidth = constants.ScreenWidth   // 画面幅を640に設定
 	screenHeight = constants.ScreenHeight // 画面高さを480に設定
 	return screenWidth, screenHeight
 }
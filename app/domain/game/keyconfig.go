@@ -0,0 +1,142 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strings"
+
+	"square-face-tetris/app/domain/input"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// キー設定画面の状態
+type keyConfig struct {
+	cursor  int  // 選択中の操作（input.Actions の番号）
+	waiting bool // 割り当てるキー・ボタンの入力待ち
+}
+
+var keyConfigHelpText = []string{
+	"↑↓: 選択  Enter: キー/ボタンを割り当て  Backspace: キーを解除",
+	"F: 顔の入力を切り替え  D: 初期設定に戻す  Esc: 保存して戻る",
+}
+
+// キー設定画面の状態を更新
+// 割り当てを変えても操作できなくならないよう、この画面の操作は固定のキーで行う
+func (g *GameWrapper) updateKeyConfig() {
+	kc := &g.keyConfig
+	bindings := &g.Controls.Bindings
+	action := input.Actions[kc.cursor]
+
+	if kc.waiting {
+		// Esc で割り当てを取りやめる
+		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			kc.waiting = false
+			return
+		}
+		if keys := inpututil.AppendJustPressedKeys(nil); len(keys) > 0 {
+			input.Bind(bindings.Keyboard, action, keys[0].String())
+			kc.waiting = false
+			return
+		}
+		if button, ok := g.Controls.justPressedGamepadButton(); ok {
+			input.Bind(bindings.Gamepad, action, button)
+			kc.waiting = false
+		}
+		return
+	}
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyUp):
+		kc.cursor = (kc.cursor + len(input.Actions) - 1) % len(input.Actions)
+	case inpututil.IsKeyJustPressed(ebiten.KeyDown):
+		kc.cursor = (kc.cursor + 1) % len(input.Actions)
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		kc.waiting = true
+	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace):
+		bindings.Keyboard[action] = nil
+	case inpututil.IsKeyJustPressed(ebiten.KeyF):
+		bindings.Face[action] = nextFaceInput(bindings.Face[action])
+	case inpututil.IsKeyJustPressed(ebiten.KeyD):
+		*bindings = input.DefaultBindings()
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		if err := bindings.Save(); err != nil {
+			log.Printf("キー設定の保存に失敗しました: %v", err)
+		}
+		g.Game.State = "start"
+	}
+}
+
+// nextFaceInput は顔の入力の割り当てを順番に切り替える（最後の次は割り当てなし）
+func nextFaceInput(current []string) []string {
+	if len(current) == 0 {
+		return []string{input.FaceInputs[0]}
+	}
+	for i, name := range input.FaceInputs {
+		if name == current[0] && i+1 < len(input.FaceInputs) {
+			return []string{input.FaceInputs[i+1]}
+		}
+	}
+	return nil
+}
+
+// キー設定画面の描画
+func (g *GameWrapper) drawKeyConfig(screen *ebiten.Image) {
+	// 背景を塗りつぶす
+	screen.Fill(color.Black)
+
+	face := &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}
+	bindings := g.Controls.Bindings
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(x, 40)
+	op.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, "キー設定", &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   normalFontSize,
+	}, op)
+
+	for i, action := range input.Actions {
+		line := fmt.Sprintf("%-10s キー: %-20s パッド: %-10s 顔: %s",
+			action,
+			joinOrDash(bindings.Keyboard[action]),
+			joinOrDash(bindings.Gamepad[action]),
+			joinOrDash(bindings.Face[action]),
+		)
+		clr := color.Color(color.White)
+		if i == g.keyConfig.cursor {
+			line = "> " + line
+			clr = color.RGBA{255, 255, 0, 255}
+			if g.keyConfig.waiting {
+				line = fmt.Sprintf("> %-10s キーかボタンを押してください（Esc で取り消し）", action)
+			}
+		} else {
+			line = "  " + line
+		}
+
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(x, float64(100+i*40))
+		op.ColorScale.ScaleWithColor(clr)
+		text.Draw(screen, line, face, op)
+	}
+
+	for i, line := range keyConfigHelpText {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(x, float64(100+len(input.Actions)*40+40+i*28))
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, line, face, op)
+	}
+}
+
+func joinOrDash(names []string) string {
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,184 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"square-face-tetris/app/domain/leaderboard"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// 名前を入力しなかったときの名前
+const defaultPlayerName = "NO NAME"
+
+// ランキングの入力・表示の状態
+type ranking struct {
+	entry leaderboard.Entry // 名前を入力中の成績
+	name  []rune            // 入力中の名前
+	rank  int               // 直前のゲームの順位（ランキングに入らなければ 0）
+	mode  string            // ランキング画面で表示しているモード
+}
+
+var leaderboardHelpText = "←→: モードを切り替え  Esc: 戻る"
+
+// startNameEntry は上位に入った成績の名前の入力を始める
+func (g *GameWrapper) startNameEntry(entry leaderboard.Entry) {
+	g.ranking.entry = entry
+	g.ranking.name = g.ranking.name[:0]
+	g.Game.State = "nameEntry"
+}
+
+// 名前の入力中の状態を更新
+func (g *GameWrapper) updateNameEntry() {
+	r := &g.ranking
+
+	for _, c := range ebiten.AppendInputChars(nil) {
+		if len(r.name) < leaderboard.MaxNameLength {
+			r.name = append(r.name, c)
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(r.name) > 0 {
+		r.name = r.name[:len(r.name)-1]
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		name := strings.TrimSpace(string(r.name))
+		if name == "" {
+			name = defaultPlayerName
+		}
+		r.entry.Name = name
+
+		r.rank = g.Leaderboard.Add(g.mode(), r.entry)
+		if err := g.Leaderboard.Save(); err != nil {
+			log.Printf("ランキングの保存に失敗しました: %v", err)
+		}
+		g.Game.State = "showingScore"
+	}
+}
+
+// 名前の入力画面の描画
+func (g *GameWrapper) drawNameEntry(screen *ebiten.Image) {
+	screen.Fill(color.Black)
+
+	face := &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   normalFontSize,
+	}
+	lines := []string{
+		"ハイスコア!",
+		fmt.Sprintf("スコア: %d", g.ranking.entry.Score),
+		"名前を入力して Enter",
+		"> " + string(g.ranking.name) + "_",
+	}
+	for i, line := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(x, float64(60+i*40))
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, line, face, op)
+	}
+
+	rest := leaderboard.MaxNameLength - utf8.RuneCountInString(string(g.ranking.name))
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(x, float64(60+len(lines)*40))
+	op.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, fmt.Sprintf("あと %d 文字", rest), &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}, op)
+}
+
+// ランキング画面の状態を更新
+func (g *GameWrapper) updateLeaderboard() {
+	modes := g.leaderboardModes()
+	current := sort.SearchStrings(modes, g.ranking.mode)
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+		g.ranking.mode = modes[(current+len(modes)-1)%len(modes)]
+	case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+		g.ranking.mode = modes[(current+1)%len(modes)]
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		g.Game.State = "start"
+	}
+}
+
+// leaderboardModes はランキング画面で切り替えるモードを名前順に返す
+// 成績がなくても今のモードは必ず含める
+func (g *GameWrapper) leaderboardModes() []string {
+	modes := g.Leaderboard.Modes()
+	if i := sort.SearchStrings(modes, g.ranking.mode); i == len(modes) || modes[i] != g.ranking.mode {
+		modes = append(modes, g.ranking.mode)
+		sort.Strings(modes)
+	}
+	return modes
+}
+
+// ランキング画面の描画
+func (g *GameWrapper) drawLeaderboard(screen *ebiten.Image) {
+	screen.Fill(color.Black)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(x, 40)
+	op.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, fmt.Sprintf("ランキング（%s）", g.ranking.mode), &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   normalFontSize,
+	}, op)
+
+	face := &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}
+	entries := g.Leaderboard[g.ranking.mode]
+	lines := []string{fmt.Sprintf("%-3s %-12s %7s %5s %4s %6s  %-10s %s", "#", "名前", "スコア", "行", "Lv", "時間", "日付", "表情")}
+	for i, e := range entries {
+		lines = append(lines, fmt.Sprintf("%-3d %-12s %7d %5d %4d %6s  %-10s %s",
+			i+1, e.Name, e.Score, e.Lines, e.Level,
+			formatDuration(e.Duration), e.Date.Format("2006-01-02"), formatEmotions(e.Emotions)))
+	}
+	if len(entries) == 0 {
+		lines = append(lines, "まだ記録がありません")
+	}
+	lines = append(lines, "", leaderboardHelpText)
+
+	for i, line := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(x, float64(90+i*28))
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, line, face, op)
+	}
+}
+
+// formatDuration は時間を「分:秒」で表す
+func formatDuration(d time.Duration) string {
+	seconds := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// formatEmotions は表情ごとの回数を多い順に並べる
+func formatEmotions(emotions map[string]int) string {
+	names := make([]string, 0, len(emotions))
+	for name := range emotions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if emotions[names[i]] != emotions[names[j]] {
+			return emotions[names[i]] > emotions[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s:%d", name, emotions[name])
+	}
+	return strings.Join(parts, " ")
+}
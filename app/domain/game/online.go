@@ -0,0 +1,109 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/netplay"
+	"square-face-tetris/app/domain/wasm"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// startOnline は中継サーバーに接続し、通信対戦の相手を待つ
+func (g *GameWrapper) startOnline() {
+	s := g.Settings
+	g.versus = &versus{
+		online: netplay.Join(s.ServerURL, s.Room, s.EngineOptions(), s.InputDelay),
+	}
+	g.Game.State = "onlineWaiting"
+}
+
+// 通信対戦の相手を待っている間の状態を更新
+func (g *GameWrapper) updateOnlineWaiting() {
+	v := g.versus
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		v.online.Close()
+		g.versus = nil
+		g.Game.State = "start"
+		return
+	}
+
+	v.online.Update(nil, nil)
+	if !v.online.Started {
+		return
+	}
+
+	// 自分は全ての入力で操作し、表情で次のテトリミノを選ぶ
+	for i, e := range v.online.Engines {
+		p := &versusPlayer{Engine: e, name: fmt.Sprintf("P%d 相手", i+1)}
+		if i == v.online.Player {
+			p.name = fmt.Sprintf("P%d あなた", i+1)
+			p.sources = SourceAll
+			p.face = true
+		}
+		v.players[i] = p
+	}
+	g.Game.State = "versus"
+}
+
+// 通信対戦中の状態を更新
+// 両方のエンジンは Session が 2 人分の入力が揃ったフレームまで進める
+func (g *GameWrapper) updateOnlineVersus(events []domain.FaceEvent) {
+	v := g.versus
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		v.online.Close()
+		v.message = "対戦をやめました"
+		g.Game.State = "versusResult"
+		return
+	}
+
+	me := v.players[v.online.Player]
+	v.online.Update(g.Controls.ActionsFrom(me.sources, events), wasm.Face.GetEmotionIndexes())
+
+	switch s := v.online; {
+	case s.Desync:
+		v.message = "同期がずれたため対戦を中止しました"
+	case s.OpponentLeft:
+		v.message = "相手が切断しました"
+	case s.Err != nil:
+		v.message = s.Err.Error()
+	case s.Over():
+		v.winner = v.judge()
+	default:
+		return
+	}
+	v.online.Close()
+	g.Game.State = "versusResult"
+}
+
+// 通信対戦の相手を待っている画面の描画
+func (g *GameWrapper) drawOnlineWaiting(screen *ebiten.Image) {
+	screen.Fill(color.RGBA{0, 0, 64, 255}) // 紺色
+
+	s := g.versus.online
+	status := "サーバーに接続しています..."
+	switch {
+	case s.Err != nil:
+		status = s.Err.Error()
+	case s.Connected():
+		status = fmt.Sprintf("部屋「%s」で相手を待っています...", g.Settings.Room)
+	}
+
+	lines := []string{"通信対戦", g.Settings.ServerURL, status, "Esc: タイトルへ"}
+	for i, line := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(constants.ScreenWidth/2, float64(constants.ScreenHeight/2-90+i*48))
+		op.PrimaryAlign = text.AlignCenter
+		op.SecondaryAlign = text.AlignCenter
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, line, &text.GoTextFace{
+			Source: mplusFaceSource,
+			Size:   normalFontSize,
+		}, op)
+	}
+}
@@ -0,0 +1,135 @@
+package game
+
+import (
+	"image/color"
+	"math"
+
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/engine"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// ブロックの大きさごとの画像
+// 白いブロックを 1 度だけ作り、色は描画するときに ColorScale で付ける
+// 全てのブロックが同じ画像から描画されるため、ebiten がまとめて 1 回の描画にできる
+var blockSprites = map[int]*ebiten.Image{}
+
+// blockSprite は size x size の白いブロックの画像を返す
+func blockSprite(size int) *ebiten.Image {
+	if img, ok := blockSprites[size]; ok {
+		return img
+	}
+	img := ebiten.NewImage(size, size)
+	img.Fill(color.White)
+	blockSprites[size] = img
+	return img
+}
+
+// drawBlock は (left, top) に size の大きさのブロックを clr の色で描画する
+func drawBlock(dst *ebiten.Image, left, top float64, size int, clr color.Color) {
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(left, top)
+	op.ColorScale.ScaleWithColor(clr)
+	dst.DrawImage(blockSprite(size), op)
+}
+
+// drawShape はテトリミノの形を (left, top) を左上として、blockSize 間隔で size の大きさのブロックで描画する
+func drawShape(dst *ebiten.Image, shape [][]int, clr color.Color, left, top, blockSize float64, size int) {
+	for y := range shape {
+		for x := range shape[y] {
+			if shape[y][x] == 1 {
+				drawBlock(dst, left+float64(x)*blockSize, top+float64(y)*blockSize, size, clr)
+			}
+		}
+	}
+}
+
+// 固定されたブロックの色
+var lockedBlockColor = color.RGBA{0, 0, 255, 255} // 青
+
+// boardLayer は固定されたブロックを描画した画像
+// テトリミノを固定して行を消したとき（ボードが変わったとき）だけ描き直す
+type boardLayer struct {
+	image     *ebiten.Image
+	cells     [][]int // image に描画したボード
+	blockSize int
+}
+
+// draw はボードが変わっていれば描き直してから、screen の (left, top) に描画する
+func (l *boardLayer) draw(screen *ebiten.Image, board domain.Board, left, top float64, blockSize int) {
+	width, height := board.Width()*blockSize, board.Height()*blockSize
+	if width == 0 || height == 0 {
+		return
+	}
+	if l.image == nil || l.blockSize != blockSize || l.image.Bounds().Dx() != width || l.image.Bounds().Dy() != height {
+		if l.image != nil {
+			l.image.Deallocate()
+		}
+		l.image = ebiten.NewImage(width, height)
+		l.blockSize = blockSize
+		l.cells = nil
+	}
+
+	if !sameCells(l.cells, board) {
+		l.image.Clear()
+		for y := range board {
+			for x := range board[y] {
+				if board[y][x] == 1 {
+					drawBlock(l.image, float64(x*blockSize), float64(y*blockSize), blockSize, lockedBlockColor)
+				}
+			}
+		}
+		l.cells = copyCells(l.cells, board)
+	}
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(left, top)
+	screen.DrawImage(l.image, op)
+}
+
+// drawBoard は固定されたブロックと現在のテトリミノを (left, top) を左上として描画する
+// 固定されたブロックは layer に描画したものを使い回す。blockSize は 1 マスの大きさ
+func drawBoard(screen *ebiten.Image, layer *boardLayer, board domain.Board, current *engine.Tetromino, left, top, blockSize float64) {
+	size := int(blockSize)
+	layer.draw(screen, board, left, top, size)
+
+	// 現在のテトリミノ
+	if current != nil {
+		drawShape(screen, current.Shape, current.Color,
+			left+float64(current.X)*blockSize, top+float64(current.Y)*blockSize, blockSize, size)
+	}
+}
+
+// drawTetromino はテトリミノの形を (left, top) を左上として、隙間を空けて描画する
+func drawTetromino(screen *ebiten.Image, t *engine.Tetromino, left, top, blockSize float64) {
+	drawShape(screen, t.Shape, t.Color, left, top, blockSize, int(math.Max(blockSize-1, 1)))
+}
+
+func sameCells(cells [][]int, board domain.Board) bool {
+	if len(cells) != len(board) {
+		return false
+	}
+	for y := range board {
+		if len(cells[y]) != len(board[y]) {
+			return false
+		}
+		for x := range board[y] {
+			if cells[y][x] != board[y][x] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// copyCells は board を dst に（大きさが違えば作り直して）コピーする
+func copyCells(dst [][]int, board domain.Board) [][]int {
+	if len(dst) != len(board) {
+		dst = make([][]int, len(board))
+	}
+	for y := range board {
+		dst[y] = append(dst[y][:0], board[y]...)
+	}
+	return dst
+}
@@ -0,0 +1,123 @@
+package game
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"log"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/replay"
+	"square-face-tetris/app/domain/wasm"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// 早送りのときに 1 回の Update で進めるフレーム数
+const replayFastSpeed = 4
+
+// リプレイ再生画面の状態
+type replayView struct {
+	replay   *replay.Replay
+	player   *replay.Player
+	speed    int            // 1 回の Update で進めるフレーム数
+	stepping bool           // コマ送り中
+	saved    *engine.Engine // 再生前のエンジン（再生を終えたら戻す）
+	back     string         // 再生を終えたら戻る画面
+}
+
+var replayHelpText = "1: 等速  2: 早送り  S: コマ送り  →: 1 コマ進める  Esc: 戻る"
+
+// startReplay は記録の再生を始める
+// 描画はプレイ中と同じものを使うため、再生中は g.Game.Engine を記録用のエンジンに差し替える
+func (g *GameWrapper) startReplay(r *replay.Replay) {
+	g.replayView = &replayView{
+		replay: r,
+		player: replay.NewPlayer(r),
+		speed:  1,
+		saved:  g.Game.Engine,
+		back:   g.Game.State,
+	}
+	g.Game.Engine = r.NewEngine()
+	g.Game.State = "replay"
+}
+
+// stopReplay は再生を終えて元の画面に戻る
+func (g *GameWrapper) stopReplay() {
+	g.Game.Engine = g.replayView.saved
+	g.Game.State = g.replayView.back
+	g.replayView = nil
+}
+
+// リプレイ再生中の状態を更新
+func (g *GameWrapper) updateReplay() {
+	rv := g.replayView
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		g.stopReplay()
+		return
+	case inpututil.IsKeyJustPressed(ebiten.Key1):
+		rv.speed = 1
+		rv.stepping = false
+	case inpututil.IsKeyJustPressed(ebiten.Key2):
+		rv.speed = replayFastSpeed
+		rv.stepping = false
+	case inpututil.IsKeyJustPressed(ebiten.KeyS):
+		rv.stepping = !rv.stepping
+	}
+
+	frames := rv.speed
+	if rv.stepping {
+		frames = 0
+		if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+			frames = 1
+		}
+	}
+
+	for i := 0; i < frames; i++ {
+		actions, emotionIndexes, ok := rv.player.Next()
+		if !ok {
+			break
+		}
+		g.Game.Step(actions, emotionIndexes)
+		g.Game.PopEvents()
+	}
+}
+
+// リプレイ再生中の表示
+func (g *GameWrapper) drawReplay(screen *ebiten.Image) {
+	rv := g.replayView
+	face := &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}
+
+	mode := fmt.Sprintf("%dx", rv.speed)
+	if rv.stepping {
+		mode = "コマ送り"
+	}
+	status := fmt.Sprintf("▶ REPLAY %s  %d / %d  (記録時のスコア: %d)",
+		mode, rv.player.Frame(), rv.replay.Frames, rv.replay.Score)
+
+	for i, line := range []string{status, replayHelpText} {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(x, float64(constants.ScreenHeight-60+i*24))
+		op.ColorScale.ScaleWithColor(color.RGBA{255, 255, 0, 255})
+		text.Draw(screen, line, face, op)
+	}
+}
+
+// downloadReplay は記録を JSON ファイルとしてダウンロードさせる
+func (g *GameWrapper) downloadReplay(r *replay.Replay) {
+	var buf bytes.Buffer
+	if err := r.Save(&buf); err != nil {
+		log.Printf("リプレイの書き出しに失敗しました: %v", err)
+		return
+	}
+	filename := fmt.Sprintf("replay-%s.json", r.Date.Format("20060102-150405"))
+	wasm.Download(filename, "application/json", buf.Bytes())
+}
@@ -0,0 +1,159 @@
+package game
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"log"
+	"time"
+
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/highlight"
+	"square-face-tetris/app/domain/report"
+	"square-face-tetris/app/domain/timeline"
+	"square-face-tetris/app/domain/wasm"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// 統計の棒グラフの大きさ
+const (
+	chartLabelWidth = 120
+	chartBarWidth   = 120
+	chartRowHeight  = 18
+)
+
+// 統計の表示
+func (g *GameWrapper) drawReport(screen *ebiten.Image, top float64) {
+	r := g.Report
+	if r == nil {
+		return
+	}
+	face := &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}
+
+	// 左の列: 数値の統計
+	lines := []string{
+		fmt.Sprintf("消した行: %d  レベル: %d", r.Lines, r.Level),
+		fmt.Sprintf("テトリミノ: %d  (%.2f 個/秒)", r.Pieces, r.PPS),
+		fmt.Sprintf("T スピン: %d  最大コンボ: %d", r.TSpins, r.MaxCombo),
+		fmt.Sprintf("操作の無駄: %d", r.FinesseFaults),
+		fmt.Sprintf("顔の検出: %.0f%%", r.FaceUptime*100),
+	}
+	for i, line := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(x, top+float64(i*chartRowHeight))
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, line, face, op)
+	}
+
+	// 消した行数の内訳
+	chartTop := top + float64(len(lines)*chartRowHeight) + 12
+	var labels []string
+	var values []float64
+	for n := 1; n < len(engine.LineClearNames); n++ {
+		name := engine.LineClearNames[n]
+		labels = append(labels, name)
+		values = append(values, float64(r.LineClears[name]))
+	}
+	drawBarChart(screen, face, x, chartTop, "消した行数", labels, values, "%.0f", color.RGBA{255, 165, 0, 255})
+
+	// 右の列: 表情ごとの時間と、次のテトリミノを選んだ回数
+	right := float64(x + chartLabelWidth + chartBarWidth + 40)
+	names := report.EmotionNames()
+	times := make([]float64, len(names))
+	picks := make([]float64, len(names))
+	for i, name := range names {
+		times[i] = r.EmotionTime[name]
+		picks[i] = float64(r.EmotionPicks[name])
+	}
+	drawBarChart(screen, face, right, top, "表情の時間（秒）", names, times, "%.1f", color.RGBA{128, 200, 255, 255})
+	drawBarChart(screen, face, right, top+float64((len(names)+1)*chartRowHeight)+18, "表情で選んだ回数", names, picks, "%.0f", color.RGBA{255, 128, 200, 255})
+}
+
+// drawBarChart は横向きの棒グラフを描画する
+func drawBarChart(screen *ebiten.Image, face text.Face, left, top float64, title string, labels []string, values []float64, format string, clr color.Color) {
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(left, top)
+	op.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, title, face, op)
+
+	max := 0.0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	for i, label := range labels {
+		y := top + float64((i+1)*chartRowHeight)
+
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(left, y)
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, label, face, op)
+
+		width := 0.0
+		if max > 0 {
+			width = values[i] / max * chartBarWidth
+		}
+		barLeft := float32(left + chartLabelWidth)
+		vector.DrawFilledRect(screen, barLeft, float32(y+4), float32(width), chartRowHeight-8, clr, false)
+
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(left+chartLabelWidth+width+4, y)
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, fmt.Sprintf(format, values[i]), face, op)
+	}
+}
+
+// downloadReport は統計を JSON か CSV のファイルとしてダウンロードさせる
+func (g *GameWrapper) downloadReport(r *report.Report, format string) {
+	var buf bytes.Buffer
+	var err error
+	mimeType := "application/json"
+	if format == "csv" {
+		err = r.WriteCSV(&buf)
+		mimeType = "text/csv"
+	} else {
+		err = r.WriteJSON(&buf)
+	}
+	if err != nil {
+		log.Printf("統計の書き出しに失敗しました: %v", err)
+		return
+	}
+	wasm.Download(g.exportFilename("report", format), mimeType, buf.Bytes())
+}
+
+// downloadTimeline は表情と出来事の時系列を JSON ファイルとしてダウンロードさせる
+func (g *GameWrapper) downloadTimeline(t *timeline.Timeline) {
+	var buf bytes.Buffer
+	if err := t.WriteJSON(&buf); err != nil {
+		log.Printf("時系列の書き出しに失敗しました: %v", err)
+		return
+	}
+	wasm.Download(g.exportFilename("timeline", "json"), "application/json", buf.Bytes())
+}
+
+// downloadHighlights は見どころをアニメーション GIF としてダウンロードさせる
+func (g *GameWrapper) downloadHighlights(h *highlight.Recorder) {
+	var buf bytes.Buffer
+	if err := h.EncodeGIF(&buf); err != nil {
+		log.Printf("ハイライトの書き出しに失敗しました: %v", err)
+		return
+	}
+	wasm.Download(g.exportFilename("highlight", "gif"), "image/gif", buf.Bytes())
+}
+
+// exportFilename は終了したゲームの日時を含むファイル名を返す
+func (g *GameWrapper) exportFilename(name, ext string) string {
+	date := time.Now()
+	if g.LastReplay != nil {
+		date = g.LastReplay.Date
+	}
+	return fmt.Sprintf("%s-%s.%s", name, date.Format("20060102-150405"), ext)
+}
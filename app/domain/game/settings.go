@@ -0,0 +1,323 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"time"
+
+	"square-face-tetris/app/domain/bot"
+	"square-face-tetris/app/domain/detection"
+	"square-face-tetris/app/domain/settings"
+	"square-face-tetris/app/domain/wasm"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// 設定画面の状態
+type settingsMenu struct {
+	cursor int // 選択中の項目
+	scroll int // 表示している先頭の項目
+}
+
+// 設定画面に一度に表示する項目の数（残りはスクロールして表示する）
+const visibleSettingItems = 13
+
+// 設定画面の 1 項目
+type settingItem struct {
+	label  string
+	value  func(s *settings.Settings) string
+	change func(s *settings.Settings, delta int) // delta は -1 か 1
+}
+
+var settingItems = []settingItem{
+	{
+		label:  "カメラ（再起動後に反映）",
+		value:  func(s *settings.Settings) string { return onOff(s.Camera) },
+		change: func(s *settings.Settings, delta int) { s.Camera = !s.Camera },
+	},
+	{
+		label:  "カメラのプレビュー",
+		value:  func(s *settings.Settings) string { return onOff(s.CameraPreview) },
+		change: func(s *settings.Settings, delta int) { s.CameraPreview = !s.CameraPreview },
+	},
+	{
+		label:  "カメラ",
+		value:  func(s *settings.Settings) string { return cameraLabel(s.CameraDevice) },
+		change: func(s *settings.Settings, delta int) { s.CameraDevice = cycle(cameraIDs(), s.CameraDevice, delta) },
+	},
+	{
+		label: "カメラの解像度",
+		value: func(s *settings.Settings) string { return s.CameraResolution },
+		change: func(s *settings.Settings, delta int) {
+			s.CameraResolution = cycle(settings.CameraResolutions, s.CameraResolution, delta)
+		},
+	},
+	{
+		label: "カメラの FPS",
+		value: func(s *settings.Settings) string { return fmt.Sprint(s.CameraFPS) },
+		change: func(s *settings.Settings, delta int) {
+			s.CameraFPS = clampInt(s.CameraFPS+delta*5, settings.MinFPS, settings.MaxFPS)
+		},
+	},
+	{
+		label:  "プレビューの左右反転",
+		value:  func(s *settings.Settings) string { return onOff(s.MirrorCamera) },
+		change: func(s *settings.Settings, delta int) { s.MirrorCamera = !s.MirrorCamera },
+	},
+	{
+		label: "プレビューの FPS",
+		value: func(s *settings.Settings) string { return fmt.Sprint(s.CameraPreviewFPS) },
+		change: func(s *settings.Settings, delta int) {
+			s.CameraPreviewFPS = clampInt(s.CameraPreviewFPS+delta, settings.MinFPS, settings.MaxFPS)
+		},
+	},
+	{
+		label: "表情分析の FPS",
+		value: func(s *settings.Settings) string { return fmt.Sprint(s.EmotionAnalysisFPS) },
+		change: func(s *settings.Settings, delta int) {
+			s.EmotionAnalysisFPS = clampInt(s.EmotionAnalysisFPS+delta, settings.MinFPS, settings.MaxFPS)
+		},
+	},
+	{
+		label: "表情の分類器",
+		value: func(s *settings.Settings) string { return s.Classifier },
+		change: func(s *settings.Settings, delta int) {
+			s.Classifier = cycle(settings.Classifiers, s.Classifier, delta)
+		},
+	},
+	{
+		label:  "顔検出の Worker（再起動後に反映）",
+		value:  func(s *settings.Settings) string { return onOff(s.DetectionWorker) },
+		change: func(s *settings.Settings, delta int) { s.DetectionWorker = !s.DetectionWorker },
+	},
+	{
+		label: "追跡する顔の選び方",
+		value: func(s *settings.Settings) string { return s.Detector.Selection },
+		change: func(s *settings.Settings, delta int) {
+			s.Detector.Selection = cycle(detection.Selections, s.Detector.Selection, delta)
+		},
+	},
+	{
+		label: "顔の信頼度の下限",
+		value: func(s *settings.Settings) string { return fmt.Sprint(s.Detector.MinQuality) },
+		change: func(s *settings.Settings, delta int) {
+			s.Detector.MinQuality = math.Max(s.Detector.MinQuality+float64(delta), 0)
+		},
+	},
+	{
+		label: "頭の向きの平滑化",
+		value: func(s *settings.Settings) string { return fmt.Sprintf("%.1f", s.HeadPoseSmoothing) },
+		change: func(s *settings.Settings, delta int) {
+			v := math.Round((s.HeadPoseSmoothing+float64(delta)*0.1)*10) / 10
+			s.HeadPoseSmoothing = clamp(v, 0.1, 1)
+		},
+	},
+	{
+		label: "顔を見失ったとき",
+		value: func(s *settings.Settings) string { return s.FaceLostAction },
+		change: func(s *settings.Settings, delta int) {
+			s.FaceLostAction = cycle(settings.FaceLostActions, s.FaceLostAction, delta)
+		},
+	},
+	{
+		label: "見失うまでの時間",
+		value: func(s *settings.Settings) string { return s.FaceLostTimeout.String() },
+		change: func(s *settings.Settings, delta int) {
+			s.FaceLostTimeout = clampDuration(s.FaceLostTimeout+settings.Duration(delta)*settings.Duration(500*time.Millisecond),
+				settings.MinFaceLostTimeout, settings.MaxFaceLostTimeout)
+		},
+	},
+	{
+		label: "ボードの幅",
+		value: func(s *settings.Settings) string { return fmt.Sprint(s.BoardWidth) },
+		change: func(s *settings.Settings, delta int) {
+			s.BoardWidth = clampInt(s.BoardWidth+delta, settings.MinBoardWidth, settings.MaxBoardWidth)
+		},
+	},
+	{
+		label: "ボードの高さ",
+		value: func(s *settings.Settings) string { return fmt.Sprint(s.BoardHeight) },
+		change: func(s *settings.Settings, delta int) {
+			s.BoardHeight = clampInt(s.BoardHeight+delta, settings.MinBoardHeight, settings.MaxBoardHeight)
+		},
+	},
+	{
+		label: "落下間隔",
+		value: func(s *settings.Settings) string { return s.DropInterval.String() },
+		change: func(s *settings.Settings, delta int) {
+			s.DropInterval = clampDuration(s.DropInterval+settings.Duration(delta)*settings.Duration(100*time.Millisecond),
+				settings.MinDropInterval, settings.MaxDropInterval)
+		},
+	},
+	{
+		label: "タイムリミット",
+		value: func(s *settings.Settings) string { return s.TimeLimit.String() },
+		change: func(s *settings.Settings, delta int) {
+			s.TimeLimit = clampDuration(s.TimeLimit+settings.Duration(delta)*settings.Duration(30*time.Second),
+				settings.MinTimeLimit, settings.MaxTimeLimit)
+		},
+	},
+	{
+		label: "通信対戦の入力遅延",
+		value: func(s *settings.Settings) string { return fmt.Sprintf("%d フレーム", s.InputDelay) },
+		change: func(s *settings.Settings, delta int) {
+			s.InputDelay = clampInt(s.InputDelay+delta, settings.MinInputDelay, settings.MaxInputDelay)
+		},
+	},
+	{
+		label: "CPU の強さ",
+		value: func(s *settings.Settings) string { return fmt.Sprint(s.CPULevel) },
+		change: func(s *settings.Settings, delta int) {
+			s.CPULevel = clampInt(s.CPULevel+delta, bot.MinLevel, bot.MaxLevel)
+		},
+	},
+	{
+		label:  "観戦用の配信",
+		value:  func(s *settings.Settings) string { return onOff(s.Broadcast) },
+		change: func(s *settings.Settings, delta int) { s.Broadcast = !s.Broadcast },
+	},
+}
+
+var settingsHelpText = []string{
+	"↑↓: 選択  ←→: 変更  D: 初期設定に戻す  Esc: 保存して戻る",
+	"項目は上下にスクロールする",
+	"ゲームのルールの設定は次のゲームから反映される",
+}
+
+// 設定画面の状態を更新
+func (g *GameWrapper) updateSettings() {
+	menu := &g.settingsMenu
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyUp):
+		menu.cursor = (menu.cursor + len(settingItems) - 1) % len(settingItems)
+	case inpututil.IsKeyJustPressed(ebiten.KeyDown):
+		menu.cursor = (menu.cursor + 1) % len(settingItems)
+	case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+		settingItems[menu.cursor].change(&g.Settings, -1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+		settingItems[menu.cursor].change(&g.Settings, 1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyD):
+		g.Settings = settings.Default()
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		if err := g.Settings.Validate(); err != nil {
+			log.Printf("設定の値を修正しました: %v", err)
+		}
+		if err := g.Settings.Save(); err != nil {
+			log.Printf("設定の保存に失敗しました: %v", err)
+		}
+		wasm.Configure(g.Settings)
+		g.Game.State = "start"
+	}
+
+	// 選択中の項目が見えるようにスクロールする
+	if menu.cursor < menu.scroll {
+		menu.scroll = menu.cursor
+	}
+	if menu.cursor >= menu.scroll+visibleSettingItems {
+		menu.scroll = menu.cursor - visibleSettingItems + 1
+	}
+}
+
+// 設定画面の描画
+func (g *GameWrapper) drawSettings(screen *ebiten.Image) {
+	screen.Fill(color.Black)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(x, 40)
+	op.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, "設定", &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   normalFontSize,
+	}, op)
+
+	face := &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}
+	end := min(g.settingsMenu.scroll+visibleSettingItems, len(settingItems))
+	for i := g.settingsMenu.scroll; i < end; i++ {
+		item := settingItems[i]
+		line := fmt.Sprintf("  %-24s %s", item.label, item.value(&g.Settings))
+		clr := color.Color(color.White)
+		if i == g.settingsMenu.cursor {
+			line = fmt.Sprintf("> %-24s < %s >", item.label, item.value(&g.Settings))
+			clr = color.RGBA{255, 255, 0, 255}
+		}
+
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(x, float64(100+(i-g.settingsMenu.scroll)*36))
+		op.ColorScale.ScaleWithColor(clr)
+		text.Draw(screen, line, face, op)
+	}
+
+	for i, line := range settingsHelpText {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(x, float64(100+visibleSettingItems*36+28+i*28))
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, line, face, op)
+	}
+}
+
+// cameraIDs は選択できるカメラの deviceId を返す（先頭の空文字列は既定のカメラ）
+func cameraIDs() []string {
+	ids := []string{""}
+	for _, d := range wasm.CameraDevices() {
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
+
+// cameraLabel は deviceId のカメラの名前を返す
+func cameraLabel(id string) string {
+	if id == "" {
+		return "既定"
+	}
+	for _, d := range wasm.CameraDevices() {
+		if d.ID == id {
+			return d.Label
+		}
+	}
+	return "見つからない"
+}
+
+func onOff(v bool) string {
+	if v {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// cycle は values の中で current の delta 個先の値を返す
+func cycle(values []string, current string, delta int) string {
+	for i, v := range values {
+		if v == current {
+			return values[(i+delta+len(values))%len(values)]
+		}
+	}
+	return values[0]
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func clampDuration(v, min, max settings.Duration) settings.Duration {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,194 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"strings"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/netplay"
+	"square-face-tetris/app/domain/wasm"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// 観戦画面の 1 ページに並べるゲームの数（それより多い場合はページを切り替えて表示する）
+const (
+	spectateColumns = 4
+	spectateRows    = 2
+	spectatePerPage = spectateColumns * spectateRows
+)
+
+// publish は設定で配信が有効なとき、プレイ中のゲームの状態を中継サーバーへ送る
+func (g *GameWrapper) publish() {
+	if !g.Settings.Broadcast {
+		if g.publisher != nil {
+			g.publisher.Close()
+			g.publisher = nil
+		}
+		return
+	}
+	if g.publisher == nil {
+		g.publisher = netplay.NewPublisher(g.Settings.ServerEndpoint("/publish"))
+	}
+
+	switch g.Game.State {
+	case "playing":
+		g.publisher.Publish(g.Settings.PlayerName, g.Game.Engine, wasm.Face.GetEmotionIndexes())
+	case "versus":
+		for _, p := range g.versus.players {
+			var emotionIndexes []int
+			if p.face {
+				emotionIndexes = wasm.Face.GetEmotionIndexes()
+			}
+			g.publisher.Publish(g.Settings.PlayerName+" "+p.name, p.Engine, emotionIndexes)
+		}
+	}
+
+	if err := g.publisher.Err; err != nil && !g.publishFailed {
+		log.Printf("観戦用の配信ができません: %v", err)
+		g.publishFailed = true
+	}
+}
+
+// startSpectate は中継サーバーに接続し、配信されているゲームの観戦を始める
+func (g *GameWrapper) startSpectate() {
+	g.spectator = netplay.Watch(g.Settings.ServerEndpoint("/watch"))
+	g.spectateLayers = map[string]*boardLayer{}
+	g.spectatePage = 0
+	g.Game.State = "spectate"
+}
+
+// 観戦画面の状態を更新
+func (g *GameWrapper) updateSpectate() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.spectator.Close()
+		g.spectator = nil
+		g.spectateLayers = nil
+		g.Game.State = "start"
+		return
+	}
+	g.spectator.Update()
+
+	// ← → キーでページを切り替える
+	pages := spectatePages(len(g.spectator.Games))
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+		g.spectatePage = (g.spectatePage + pages - 1) % pages
+	case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+		g.spectatePage = (g.spectatePage + 1) % pages
+	}
+	// 配信が終わってゲームが減った場合は最後のページにする
+	g.spectatePage = min(g.spectatePage, pages-1)
+}
+
+// spectatePages は n ゲームを並べるのに必要なページ数を返す（ゲームがなくても 1）
+func spectatePages(n int) int {
+	return max(1, (n+spectatePerPage-1)/spectatePerPage)
+}
+
+// 観戦画面の描画
+// 配信されているゲームを名前順に、画面を分割して並べる
+func (g *GameWrapper) drawSpectate(screen *ebiten.Image) {
+	screen.Fill(color.RGBA{0, 0, 64, 255}) // 紺色
+
+	face := &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}
+	s := g.spectator
+	names := s.Names()
+
+	pages := spectatePages(len(names))
+	page := min(g.spectatePage, pages-1)
+	status := fmt.Sprintf("観戦中 %d ゲーム  Esc: タイトルへ", len(names))
+	if pages > 1 {
+		// このページに表示しきれないゲームの数も示す
+		shown := min(spectatePerPage, len(names)-page*spectatePerPage)
+		status = fmt.Sprintf("観戦中 %d ゲーム（%d/%d ページ、他 %d ゲーム）  ←→: ページ  Esc: タイトルへ",
+			len(names), page+1, pages, len(names)-shown)
+	}
+	switch {
+	case s.Err != nil:
+		status = fmt.Sprintf("観戦できません: %v", s.Err)
+	case !s.Connected():
+		status = "サーバーに接続しています..."
+	}
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(8, 4)
+	op.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, status, face, op)
+
+	const top = 28
+	cellWidth := float64(constants.ScreenWidth) / spectateColumns
+	cellHeight := float64(constants.ScreenHeight-top) / spectateRows
+	end := min(len(names), (page+1)*spectatePerPage)
+	for i, name := range names[page*spectatePerPage : end] {
+		left := float64(i%spectateColumns) * cellWidth
+		cellTop := top + float64(i/spectateColumns)*cellHeight
+		layer, ok := g.spectateLayers[name]
+		if !ok {
+			layer = &boardLayer{}
+			g.spectateLayers[name] = layer
+		}
+		drawSpectatedGame(screen, face, layer, name, s.Games[name], left, cellTop, cellWidth, cellHeight)
+	}
+}
+
+// drawSpectatedGame は配信されている 1 ゲームを (left, top) から width x height の範囲に描画する
+func drawSpectatedGame(screen *ebiten.Image, face text.Face, layer *boardLayer, name string, game *netplay.Snapshot, left, top, width, height float64) {
+	remaining := engine.Duration(game.TimeLimit - game.Frame)
+	if remaining < 0 {
+		remaining = 0
+	}
+	emotions := "NEUTRAL"
+	if len(game.Emotions) > 0 {
+		emotions = strings.Join(game.Emotions, " ")
+	}
+	lines := []string{
+		name,
+		fmt.Sprintf("%d点 %d行 %s", game.Score, game.Lines, formatDuration(remaining)),
+		emotions,
+	}
+	for i, line := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(left+6, top+float64(i*18))
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, line, face, op)
+	}
+
+	// ボードを残りの範囲に収まる大きさで描画する
+	board := game.Cells()
+	if board.Width() == 0 || board.Height() == 0 {
+		return
+	}
+	const infoHeight = 60
+	blockSize := math.Floor(math.Min((width-12)/float64(board.Width()), (height-infoHeight-6)/float64(board.Height())))
+	boardLeft := left + (width-blockSize*float64(board.Width()))/2
+	boardTop := top + infoHeight
+	boardWidth := float32(blockSize * float64(board.Width()))
+	boardHeight := float32(blockSize * float64(board.Height()))
+
+	vector.DrawFilledRect(screen, float32(boardLeft), float32(boardTop), boardWidth, boardHeight, color.RGBA{0, 0, 32, 255}, false)
+	vector.StrokeRect(screen, float32(boardLeft), float32(boardTop), boardWidth, boardHeight, 1, color.White, false)
+	var current *engine.Tetromino
+	if game.Current != nil && !game.Over {
+		current = game.Current.Tetromino()
+	}
+	drawBoard(screen, layer, board, current, boardLeft, boardTop, blockSize)
+
+	if game.Over {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(boardLeft+float64(boardWidth)/2, boardTop+float64(boardHeight)/2)
+		op.PrimaryAlign = text.AlignCenter
+		op.SecondaryAlign = text.AlignCenter
+		op.ColorScale.ScaleWithColor(color.RGBA{255, 64, 64, 255})
+		text.Draw(screen, "GAME OVER", face, op)
+	}
+}
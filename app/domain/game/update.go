@@ -2,8 +2,13 @@ package game
 
 import (
 	"log"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/dataset"
+	"square-face-tetris/app/domain/input"
+	"square-face-tetris/app/domain/leaderboard"
+	"square-face-tetris/app/domain/replay"
+	"square-face-tetris/app/domain/report"
 	"square-face-tetris/app/domain/wasm"
-	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
@@ -12,125 +17,256 @@ import (
 // ゲームの状態更新
 func (g *GameWrapper) Update() error {
 	wasm.UpdateCamera()
+	g.updateRecorder()
+
+	// 顔のイベントはプレイ中以外でも溜まるので毎フレーム取り出す
+	events := wasm.Face.PopEvents()
+	g.trackedEvents = wasm.PopTrackedEvents()
+	wasm.RequireFaces(g.requiredFaces())
 
 	switch g.Game.State {
 	case "start":
 		g.updateStart()
 	case "playing":
-		g.updatePlaying()
+		g.updatePlaying(events)
+	case "paused":
+		g.updatePaused(events)
+	case "faceLost":
+		g.updateFaceLost(events)
+	case "keyconfig":
+		g.updateKeyConfig()
+	case "replay":
+		g.updateReplay()
+	case "settings":
+		g.updateSettings()
+	case "versus":
+		g.updateVersus(events)
+	case "versusResult":
+		g.updateVersusResult()
+	case "onlineWaiting":
+		g.updateOnlineWaiting()
+	case "spectate":
+		g.updateSpectate()
+	case "nameEntry":
+		g.updateNameEntry()
+	case "leaderboard":
+		g.updateLeaderboard()
 	case "showingScore":
 		g.updateShowingScore()
 	}
+	g.publish()
 	return nil
 }
 
-
 func (g *GameWrapper) updateStart() {
-	// スコア画面ではスペースキーを押すと終了
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+	// K キーでキー設定画面へ
+	if inpututil.IsKeyJustPressed(ebiten.KeyK) {
+		g.keyConfig = keyConfig{}
+		g.Game.State = "keyconfig"
+		return
+	}
+
+	// B キーで 2 人対戦へ
+	if inpututil.IsKeyJustPressed(ebiten.KeyB) {
+		g.startVersus()
+		return
+	}
+
+	// C キーで CPU 対戦へ
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+		g.startVersusCPU()
+		return
+	}
+
+	// M キーで 2 つの顔の協力プレイへ、N キーで 2 つの顔の対戦へ
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.startCoop()
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyN) {
+		g.startVersusFaces()
+		return
+	}
+
+	// O キーで通信対戦へ
+	if inpututil.IsKeyJustPressed(ebiten.KeyO) {
+		g.startOnline()
+		return
+	}
+
+	// W キーで観戦画面へ
+	if inpututil.IsKeyJustPressed(ebiten.KeyW) {
+		g.startSpectate()
+		return
+	}
+
+	// S キーで設定画面へ
+	if inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		g.settingsMenu = settingsMenu{}
+		g.Game.State = "settings"
+		return
+	}
+
+	// L キーでランキング画面へ
+	if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		g.ranking.mode = gameMode
+		g.Game.State = "leaderboard"
+		return
+	}
+
+	// V キーで保存されている最後のゲームのリプレイを再生
+	if inpututil.IsKeyJustPressed(ebiten.KeyV) {
+		r, err := replay.LoadLatest()
+		if err != nil {
+			log.Printf("リプレイを読み込めません: %v", err)
+			return
+		}
+		g.startReplay(r)
+		return
+	}
+
+	// タイトル画面ではスペースキーを押すと開始
+	// スペースキーはハードドロップにも使うので、押し続けている間は反応しないよう押した瞬間だけを見る
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		g.coop = nil
 		err := g.ResetGame() // ゲームを初期化
 		if err != nil {
-				log.Fatalf("Failed to initialize the game: %v", err)
+			log.Fatalf("Failed to initialize the game: %v", err)
 		}
 	}
 }
 
-
 // プレイ中の状態を更新
-func (g *GameWrapper) updatePlaying() {
-	// タイムリミットを超えている場合はスコア画面へ遷移
-	if time.Since(g.Game.StartTime) >= g.Game.TimeLimit {
-		g.Game.State = "showingScore"
+// events はこのフレームまでに発生した顔のイベント
+func (g *GameWrapper) updatePlaying(events []domain.FaceEvent) {
+	g.updateHint()
+	// 協力プレイでは顔ごとに席を割り当て直すので、顔を見失っても止めない
+	if g.coop == nil && g.checkFaceLost() {
 		return
 	}
 
-	if g.Game.Current == nil {
-		g.Game.ShiftTetrominoQueue()
+	// 各デバイスの入力を操作に変換する（顔を見失ってキーボードで続けている間は顔の入力を除く）
+	actions := g.playingActions(events)
+	for _, action := range actions {
+		if action == input.Pause {
+			g.Game.Pause()
+			return
+		}
 	}
 
-	// ユーザー入力でテトリミノを操作
-	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) && g.Game.IsValidPosition(g.Game.Current, -1, 0) ||
-	   wasm.MoveLeft && g.Game.IsValidPosition(g.Game.Current, -1, 0) {
-			wasm.MoveLeft = false
-		g.Game.Current.X -= 1
+	// 入力を記録してから 1 フレーム進める
+	emotionIndexes, confidences := g.playingEmotions()
+	g.recorder.Record(actions, emotionIndexes)
+	g.Game.Step(actions, emotionIndexes)
+	if wasm.FaceDetected {
+		g.faceFrames++
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyRight) && g.Game.IsValidPosition(g.Game.Current, 1, 0) || 
-	wasm.MoveRight && g.Game.IsValidPosition(g.Game.Current, 1, 0) {
-		  wasm.MoveRight = false
-		g.Game.Current.X += 1
+
+	// 表情と出来事を時系列に記録し、出来事の前後を見どころとして残す
+	g.Timeline.AddEmotions(g.Game.Frame, emotionIndexes, confidences)
+	for _, ev := range g.Game.PopEvents() {
+		g.Timeline.AddEvent(ev)
+		g.highlights.Mark(ev.Type)
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyDown) && g.Game.IsValidPosition(g.Game.Current, 0, 1) || 
-	wasm.MoveDown && g.Game.IsValidPosition(g.Game.Current, 0, 1) {
-		  wasm.MoveDown = false
-		g.Game.Current.Y += 1
+	g.highlights.Capture(g.Game.Engine, wasm.CameraFrame, confidences)
+
+	// タイムリミットかゲームオーバーでスコア画面へ遷移
+	if g.Game.Over {
+		g.finishGame()
 	}
+}
 
-	// 回転用ボタンの処理（1回の入力で1回だけ回転）
-	if ebiten.IsKeyPressed(ebiten.KeyUp) && !g.Game.KeyState[ebiten.KeyUp] || wasm.MoveUp {
-		// 回転処理を試みる
-		oldX, oldY := g.Game.Current.X, g.Game.Current.Y
-		g.Game.RotateTetromino()
+// finishGame はスコア画面へ遷移し、入力の記録を保存する
+// ランキングに入る場合は先に名前の入力画面へ遷移する
+func (g *GameWrapper) finishGame() {
+	g.Game.State = "showingScore"
 
-		// 範囲外または重なりがある場合、位置を調整
-		for g.Game.IsOutOfBounds() == "left" {
-			g.Game.Current.X++
-		}
-		for g.Game.IsOutOfBounds() == "right" {
-			g.Game.Current.X--
-		}
-		for g.Game.IsOutOfBounds() == "bottom" || g.Game.IsOverlapping() {
-			g.Game.Current.Y--
-		}
+	g.LastReplay = g.recorder.Finish(g.Game.Score)
+	if err := g.LastReplay.SaveLatest(); err != nil {
+		log.Printf("リプレイの保存に失敗しました: %v", err)
+	}
+
+	g.Report = report.New(g.mode(), g.Game.Engine, g.faceFrames, g.LastReplay.Date)
+
+	g.ranking.rank = 0
+	if g.Game.Score > 0 && g.Leaderboard.Qualifies(g.mode(), g.Game.Score) {
+		g.startNameEntry(leaderboard.NewEntry(g.Game.Engine, g.LastReplay.Date))
+	}
+}
 
-		// 調整後も無効な場合は回転をキャンセル
-		if g.Game.IsOutOfBounds() != "" || g.Game.IsOverlapping() {
-			g.Game.Current.X = oldX
-			g.Game.Current.Y = oldY
-			// 回転を元に戻す（`rotateTetromino` のロジックを逆回転にする必要あり）
+// ポーズ中の状態を更新
+func (g *GameWrapper) updatePaused(events []domain.FaceEvent) {
+	for _, action := range g.Controls.Actions(events) {
+		if action == input.Pause {
+			g.Game.Resume()
+			return
 		}
+	}
+}
 
-		// 回転ボタンの押下を記録
-		g.Game.KeyState[ebiten.KeyUp] = true
+// データセット記録の操作
+// R: 記録の開始・終了, 0: NEUTRAL, 1-4: SMILE, ANGRY, SURPRISED, SUS
+func (g *GameWrapper) updateRecorder() {
+	if !g.Settings.Camera {
+		return
+	}
+	// 他の画面では同じキーを別の操作（リプレイの再生速度や名前の入力など）に使うため、
+	// タイトル画面とプレイ中だけ受け付ける
+	if g.Game.State != "start" && g.Game.State != "playing" {
+		return
 	}
 
-	// 2秒間隔で落下
-	if time.Since(g.Game.LastDrop) > g.Game.DropInterval {
-		if g.Game.IsValidPosition(g.Game.Current, 0, 1) {
-			g.Game.Current.Y += 1
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		if wasm.SampleRecorder.IsRecording() {
+			wasm.SampleRecorder.Stop()
 		} else {
-			// テトリミノが固定されるべき条件を満たす
-			g.Game.LockTetromino()
-
-			// 最上段にブロックがあるか確認（ゲームオーバーの判定）
-			if g.isTopRowFilled() {
-				g.Game.State = "showingScore" // ゲームオーバー状態に遷移
-				return
-			}
+			wasm.SampleRecorder.Start()
 		}
-		g.Game.LastDrop = time.Now() // 落下タイマーをリセット
 	}
 
-	// キーが離された場合に状態をリセット（回転だけリセット）
-	g.Game.ResetKeyState()
-}
-
-// 最上段が埋まっているか確認
-func (g *GameWrapper) isTopRowFilled() bool {
-	topRow := g.Game.Board[0] // 最上段の行を取得
-	for _, cell := range topRow {
-		if cell != 0 { // 0 以外ならブロックがある
-			return true
+	if inpututil.IsKeyJustPressed(ebiten.Key0) {
+		wasm.SampleRecorder.SetLabel(dataset.Neutral)
+	}
+	labelKeys := []ebiten.Key{ebiten.Key1, ebiten.Key2, ebiten.Key3, ebiten.Key4}
+	for i, key := range labelKeys {
+		if inpututil.IsKeyJustPressed(key) {
+			wasm.SampleRecorder.SetLabel(domain.EmotionName(i))
 		}
 	}
-	return false
 }
 
 func (g *GameWrapper) updateShowingScore() {
+	// V キーで今のゲームのリプレイを再生、D キーでダウンロード
+	if inpututil.IsKeyJustPressed(ebiten.KeyV) && g.LastReplay != nil {
+		g.startReplay(g.LastReplay)
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyD) && g.LastReplay != nil {
+		g.downloadReplay(g.LastReplay)
+	}
+
+	// T キーで表情の時系列、G キーで見どころの GIF を保存
+	if inpututil.IsKeyJustPressed(ebiten.KeyT) && g.Timeline != nil {
+		g.downloadTimeline(g.Timeline)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyG) && g.highlights != nil {
+		g.downloadHighlights(g.highlights)
+	}
+
+	// J キー・C キーで統計を JSON・CSV で保存
+	if inpututil.IsKeyJustPressed(ebiten.KeyJ) && g.Report != nil {
+		g.downloadReport(g.Report, "json")
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) && g.Report != nil {
+		g.downloadReport(g.Report, "csv")
+	}
+
 	// スコア画面ではスペースキーを押すと終了
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+	// ハードドロップでゲームが終わったときに、押したままのスペースキーで画面を飛ばさないようにする
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
 		err := g.ResetGame() // ゲームを初期化
 		if err != nil {
-				log.Fatalf("Failed to initialize the game: %v", err)
+			log.Fatalf("Failed to initialize the game: %v", err)
 		}
 	}
 }
@@ -0,0 +1,340 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/bot"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/input"
+	"square-face-tetris/app/domain/netplay"
+	"square-face-tetris/app/domain/wasm"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// 対戦画面のレイアウト
+const (
+	versusBlockSize  = 20  // ボードの 1 マス
+	versusNextSize   = 12  // 次のテトリミノの 1 マス
+	versusAreaWidth  = 344 // プレイヤー 1 人分の幅
+	versusBoardTop   = 80
+	versusMeterWidth = 10 // おじゃま行のメーターの幅
+)
+
+// 対戦中のプレイヤー
+type versusPlayer struct {
+	*engine.Engine
+
+	name    string
+	sources Source    // 操作に使う入力の種類
+	face    bool      // 表情で次のテトリミノを選ぶ
+	seat    *faceSeat // 2 つの顔での対戦で、このプレイヤーが操作に使う顔
+	bot     *bot.Bot  // CPU の場合は入力のかわりに使う
+
+	layer boardLayer
+}
+
+// 2 人対戦の状態
+type versus struct {
+	players [2]*versusPlayer
+	paused  bool
+	winner  int // 勝ったプレイヤーの番号（引き分けは -1）
+
+	cpu     bool             // CPU との対戦
+	faces   bool             // 2 つの顔での対戦（顔ごとに 1 つのボード）
+	online  *netplay.Session // 通信対戦の場合の接続（1 つの画面での対戦では nil）
+	message string           // 勝敗がつく前に終わった理由（通信対戦で切断されたなど）
+}
+
+// startVersus は 2 人対戦を始める
+// 同じシードで始め、最初のテトリミノの並びを揃える
+func (g *GameWrapper) startVersus() {
+	seed := time.Now().UnixNano()
+	opts := g.Settings.EngineOptions()
+	g.versus = &versus{
+		players: [2]*versusPlayer{
+			{
+				Engine:  engine.New(seed, opts),
+				name:    "P1 キーボード",
+				sources: SourceKeyboard | SourceTouch,
+			},
+			{
+				Engine:  engine.New(seed, opts),
+				name:    "P2 ゲームパッド・顔",
+				sources: SourceGamepad | SourceFace,
+				face:    true,
+			},
+		},
+	}
+	g.Game.State = "versus"
+}
+
+// startVersusCPU は CPU との対戦を始める
+// プレイヤーは全ての入力で操作し、表情で次のテトリミノを選ぶ
+func (g *GameWrapper) startVersusCPU() {
+	seed := time.Now().UnixNano()
+	opts := g.Settings.EngineOptions()
+	g.versus = &versus{
+		players: [2]*versusPlayer{
+			{
+				Engine:  engine.New(seed, opts),
+				name:    "P1 あなた",
+				sources: SourceAll,
+				face:    true,
+			},
+			{
+				Engine: engine.New(seed, opts),
+				name:   fmt.Sprintf("P2 CPU（強さ %d）", g.Settings.CPULevel),
+				bot:    bot.NewLevel(g.Settings.CPULevel),
+			},
+		},
+		cpu: true,
+	}
+	g.Game.State = "versus"
+}
+
+// startVersusFaces は 2 つの顔での対戦を始める
+// カメラに映った顔を見つけた順に P1・P2 に割り当て、それぞれの頭の向きと表情で操作する
+func (g *GameWrapper) startVersusFaces() {
+	seed := time.Now().UnixNano()
+	opts := g.Settings.EngineOptions()
+	g.versus = &versus{
+		players: [2]*versusPlayer{
+			{
+				Engine:  engine.New(seed, opts),
+				name:    "P1 顔・キーボード",
+				sources: SourceKeyboard | SourceTouch,
+				seat:    newFaceSeat("P1"),
+			},
+			{
+				Engine:  engine.New(seed, opts),
+				name:    "P2 顔・ゲームパッド",
+				sources: SourceGamepad,
+				seat:    newFaceSeat("P2"),
+			},
+		},
+		faces: true,
+	}
+	g.Game.State = "versus"
+}
+
+// seats は 2 つの顔での対戦の、プレイヤーごとの顔の席を返す
+func (v *versus) seats() []*faceSeat {
+	var seats []*faceSeat
+	for _, p := range v.players {
+		if p.seat != nil {
+			seats = append(seats, p.seat)
+		}
+	}
+	return seats
+}
+
+// 対戦中の状態を更新
+func (g *GameWrapper) updateVersus(events []domain.FaceEvent) {
+	v := g.versus
+	if v.online != nil {
+		g.updateOnlineVersus(events)
+		return
+	}
+
+	if v.faces {
+		assignSeats(v.seats())
+	}
+	actions := make([][]input.Action, len(v.players))
+	for i, p := range v.players {
+		if p.bot != nil {
+			continue
+		}
+		actions[i] = g.Controls.ActionsFrom(p.sources, events)
+		if p.seat != nil {
+			actions[i] = mergeActions(actions[i], p.seat.actions(g.Controls, g.trackedEvents, true))
+		}
+		for _, action := range actions[i] {
+			if action == input.Pause {
+				v.paused = !v.paused
+				return
+			}
+		}
+	}
+	if v.paused {
+		return
+	}
+
+	for i, p := range v.players {
+		var emotionIndexes []int
+		switch {
+		case p.face:
+			emotionIndexes = wasm.Face.GetEmotionIndexes()
+		case p.seat != nil:
+			emotionIndexes, _ = p.seat.emotions()
+		}
+		if p.bot != nil {
+			actions[i] = p.bot.Actions(p.Engine)
+		}
+		p.Step(actions[i], emotionIndexes)
+		p.PopEvents()
+	}
+
+	// 消した行数に応じて、相手におじゃま行を送る
+	engine.ExchangeGarbage(v.players[0].Engine, v.players[1].Engine)
+
+	if v.players[0].Over || v.players[1].Over {
+		v.winner = v.judge()
+		g.Game.State = "versusResult"
+	}
+}
+
+// judge は勝ったプレイヤーの番号を返す（引き分けは -1）
+// 先に積み上がった方の負けで、タイムリミットまで続いた場合はスコアの高い方の勝ち
+func (v *versus) judge() int {
+	toppedOut := func(p *versusPlayer) bool {
+		return p.Over && p.RemainingTime() > 0
+	}
+	p0, p1 := v.players[0], v.players[1]
+
+	switch t0, t1 := toppedOut(p0), toppedOut(p1); {
+	case t0 && t1:
+		return -1
+	case t0:
+		return 1
+	case t1:
+		return 0
+	}
+	switch {
+	case p0.Score > p1.Score:
+		return 0
+	case p1.Score > p0.Score:
+		return 1
+	}
+	return -1
+}
+
+// 対戦の結果画面の状態を更新
+func (g *GameWrapper) updateVersusResult() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeySpace):
+		switch {
+		case g.versus.online != nil:
+			g.startOnline()
+		case g.versus.cpu:
+			g.startVersusCPU()
+		case g.versus.faces:
+			g.startVersusFaces()
+		default:
+			g.startVersus()
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		g.versus = nil
+		g.Game.State = "start"
+	}
+}
+
+// 対戦画面の描画
+func (g *GameWrapper) drawVersus(screen *ebiten.Image) {
+	screen.Fill(color.RGBA{0, 0, 64, 255}) // 紺色
+
+	face := &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   smallFontSize,
+	}
+	for i, p := range g.versus.players {
+		left := float64(i*versusAreaWidth) + 16
+		g.drawVersusPlayer(screen, face, p, left)
+	}
+
+	// 残り時間
+	remaining := g.versus.players[0].RemainingTime()
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(constants.ScreenWidth/2, 20)
+	op.PrimaryAlign = text.AlignCenter
+	op.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, formatDuration(remaining), &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   normalFontSize,
+	}, op)
+
+	if g.versus.paused {
+		g.drawPaused(screen)
+	}
+}
+
+// drawVersusPlayer はプレイヤー 1 人分のボード・おじゃま行のメーター・次のテトリミノを描画する
+func (g *GameWrapper) drawVersusPlayer(screen *ebiten.Image, face text.Face, p *versusPlayer, left float64) {
+	for i, line := range []string{p.name, fmt.Sprintf("Score: %d", p.Score)} {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(left, float64(24+i*22))
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, line, face, op)
+	}
+
+	// おじゃま行のメーター（ボードの左、下から伸びる）
+	boardLeft := left + versusMeterWidth + 6
+	boardWidth := float32(p.Board.Width() * versusBlockSize)
+	boardHeight := float32(p.Board.Height() * versusBlockSize)
+	incoming := p.IncomingGarbage
+	if incoming > p.Board.Height() {
+		incoming = p.Board.Height()
+	}
+	meterHeight := float32(incoming * versusBlockSize)
+	vector.StrokeRect(screen, float32(left), versusBoardTop, versusMeterWidth, boardHeight, 1, color.White, false)
+	vector.DrawFilledRect(screen, float32(left), versusBoardTop+boardHeight-meterHeight, versusMeterWidth, meterHeight, color.RGBA{255, 64, 64, 255}, false)
+
+	// ボード
+	vector.DrawFilledRect(screen, float32(boardLeft), versusBoardTop, boardWidth, boardHeight, color.RGBA{0, 0, 32, 255}, false)
+	vector.StrokeRect(screen, float32(boardLeft), versusBoardTop, boardWidth, boardHeight, 1, color.White, false)
+	drawBoard(screen, &p.layer, p.Board, p.Current, boardLeft, versusBoardTop, versusBlockSize)
+
+	// 次のテトリミノ
+	nextLeft := boardLeft + float64(boardWidth) + 10
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(nextLeft, versusBoardTop-24)
+	op.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, "NEXT", face, op)
+	if len(p.Next) > 0 && p.Next[0] != nil {
+		drawTetromino(screen, p.Next[0], nextLeft, versusBoardTop, versusNextSize)
+	}
+}
+
+// 対戦の結果画面の描画
+func (g *GameWrapper) drawVersusResult(screen *ebiten.Image) {
+	g.drawVersus(screen)
+	vector.DrawFilledRect(screen, 0, 0, constants.ScreenWidth, constants.ScreenHeight, color.RGBA{0, 0, 0, 160}, false)
+
+	result := "引き分け"
+	switch v := g.versus; {
+	case v.message != "":
+		result = v.message
+	case v.online != nil && v.winner >= 0:
+		result = "あなたの負け..."
+		if v.winner == v.online.Player {
+			result = "あなたの勝ち!"
+		}
+	case v.winner >= 0:
+		result = fmt.Sprintf("P%d の勝ち!", v.winner+1)
+	}
+	lines := []struct {
+		text string
+		size float64
+	}{
+		{result, bigFontSize},
+		{"スペース: もう一度  Esc: タイトルへ", normalFontSize},
+	}
+	for i, line := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(constants.ScreenWidth/2, float64(constants.ScreenHeight/2-40+i*72))
+		op.PrimaryAlign = text.AlignCenter
+		op.SecondaryAlign = text.AlignCenter
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, line.text, &text.GoTextFace{
+			Source: mplusFaceSource,
+			Size:   line.size,
+		}, op)
+	}
+}
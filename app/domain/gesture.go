@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"math"
+	"square-face-tetris/app/constants"
+	"time"
+)
+
+// FaceEvent は表情・ジェスチャーが判定され始めたことを表す
+// Emotion は constants.SMILE などのインデックス
+type FaceEvent struct {
+	Emotion int
+	Time    time.Time
+}
+
+// ジェスチャーの判定に使うしきい値
+// TODO: 設定から変更できるようにする
+const (
+	winkFrames        = 3                      // ウインクとみなす片目を閉じ続けるフレーム数
+	doubleBlinkWindow = 700 * time.Millisecond // 2 回のまばたきの最大間隔
+	eyeClosedBorder   = 0.35                   // 瞳が目の中心線からずれる割合（目の幅基準）
+	mouthOpenBorder   = 0.15                   // 口の高さの増加量（眉尻間の距離基準）
+	tiltBorder        = 15 * math.Pi / 180     // 頭を傾けたとみなす角度
+	blinkMaxFrames    = winkFrames - 1         // まばたきとみなす両目を閉じるフレーム数の上限
+)
+
+// ジェスチャーの判定に使う直前までの状態
+type gestureState struct {
+	leftClosedFrames  int       // 左目を閉じ続けているフレーム数
+	rightClosedFrames int       // 右目を閉じ続けているフレーム数
+	bothClosedFrames  int       // 両目を閉じ続けているフレーム数
+	lastBlink         time.Time // 直前のまばたきの時刻
+	doubleBlinkUntil  time.Time // ダブルまばたきのフラグを立てておく期限
+}
+
+// detectGestures は瞳とランドマークからジェスチャーを判定し、flags に書き込む
+// 判定に必要な点が欠けているジェスチャーは判定しない（目の開閉は直前までの状態を保つ）
+func (f *Face) detectGestures(flags []bool, landmarks Landmarks, now time.Time) {
+	if !f.Snapshot.Landmarks.Complete() {
+		return
+	}
+	g := &f.gesture
+
+	if landmarks.Has(constants.L_EYE_INNER, constants.L_EYE_OUTER, constants.R_EYE_INNER, constants.R_EYE_OUTER) {
+		f.detectBlinks(flags, landmarks, now)
+	}
+
+	if f.canClassify(landmarks, constants.L_EYEBROW_OUTER, constants.R_EYEBROW_OUTER, constants.T_MOUTH, constants.B_MOUTH) {
+		flags[constants.MOUTH_OPEN] = f.isMouthOpen(landmarks)
+	}
+
+	// 左肩側に傾けると画像上では時計回り（roll が正）になる
+	if pose, ok := f.relativePose(landmarks); ok {
+		flags[constants.TILT_LEFT] = pose.Roll > tiltBorder
+		flags[constants.TILT_RIGHT] = pose.Roll < -tiltBorder
+	}
+	flags[constants.DOUBLE_BLINK] = now.Before(g.doubleBlinkUntil)
+}
+
+// detectBlinks は目の開閉からウインクとまばたきを判定する
+func (f *Face) detectBlinks(flags []bool, landmarks Landmarks, now time.Time) {
+	leftClosed, rightClosed := closedEyes(landmarks)
+	g := &f.gesture
+
+	// 片目だけを一定フレーム閉じ続けたらウインク
+	g.leftClosedFrames = countFrames(g.leftClosedFrames, leftClosed && !rightClosed)
+	g.rightClosedFrames = countFrames(g.rightClosedFrames, rightClosed && !leftClosed)
+	flags[constants.WINK_LEFT] = g.leftClosedFrames >= winkFrames
+	flags[constants.WINK_RIGHT] = g.rightClosedFrames >= winkFrames
+
+	// 両目を短く閉じて開けたらまばたき、それが素早く 2 回続いたらダブルまばたき
+	bothClosed := leftClosed && rightClosed
+	if !bothClosed && g.bothClosedFrames > 0 && g.bothClosedFrames <= blinkMaxFrames {
+		if now.Sub(g.lastBlink) <= doubleBlinkWindow {
+			g.doubleBlinkUntil = now.Add(doubleBlinkWindow / 2)
+			g.lastBlink = time.Time{}
+		} else {
+			g.lastBlink = now
+		}
+	}
+	g.bothClosedFrames = countFrames(g.bothClosedFrames, bothClosed)
+}
+
+// closedEyes は左右の目が閉じているかを返す
+// 目を閉じると瞳が検出できないか、瞳の位置がまぶたや眉のほうにずれるため、
+// 目頭と目尻を結んだ線からの瞳の距離で判定する
+func closedEyes(landmarks Landmarks) (left, right bool) {
+	p := &landmarks.Points
+	left = isEyeClosed(landmarks.Pupils[0], p[constants.L_EYE_INNER], p[constants.L_EYE_OUTER])
+	right = isEyeClosed(landmarks.Pupils[1], p[constants.R_EYE_INNER], p[constants.R_EYE_OUTER])
+	return left, right
+}
+
+func isEyeClosed(pupil, inner, outer Point) bool {
+	if !pupil.Valid {
+		return true
+	}
+	eyeWidth := calcDistance(inner, outer)
+	if eyeWidth == 0 {
+		return false
+	}
+	eyeCenter := calcCenter(inner, outer)
+	return math.Abs(float64(pupil.Y-eyeCenter.Y))/eyeWidth > eyeClosedBorder
+}
+
+// isMouthOpen は口の上下の距離が基準より大きく広がっているかを返す
+func (f *Face) isMouthOpen(landmarks Landmarks) bool {
+	base, p := &f.Snapshot.Landmarks.Points, &landmarks.Points
+	baseScale := calcDistance(base[constants.L_EYEBROW_OUTER], base[constants.R_EYEBROW_OUTER])
+	scale := calcDistance(p[constants.L_EYEBROW_OUTER], p[constants.R_EYEBROW_OUTER])
+	if baseScale == 0 || scale == 0 {
+		return false
+	}
+
+	baseHeight := calcDistance(base[constants.T_MOUTH], base[constants.B_MOUTH]) / baseScale
+	height := calcDistance(p[constants.T_MOUTH], p[constants.B_MOUTH]) / scale
+	return height-baseHeight > mouthOpenBorder
+}
+
+// 条件を満たし続けているフレーム数を数える
+func countFrames(frames int, ok bool) int {
+	if ok {
+		return frames + 1
+	}
+	return 0
+}
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"square-face-tetris/app/constants"
+)
+
+// closedLeft は左目を閉じて瞳が検出できなくなった顔
+func closedLeft(points, pupils [][]int) ([][]int, [][]int) {
+	return points, [][]int{nil, pupils[1]}
+}
+
+// closedBoth は両目を閉じた顔
+func closedBoth(points, pupils [][]int) ([][]int, [][]int) {
+	return points, [][]int{nil, nil}
+}
+
+func TestDetectGestures(t *testing.T) {
+	open := landmarksWith(nil)
+	left := landmarksWith(closedLeft)
+	both := landmarksWith(closedBoth)
+	repeat := func(l Landmarks, n int) []Landmarks {
+		frames := make([]Landmarks, n)
+		for i := range frames {
+			frames[i] = l
+		}
+		return frames
+	}
+
+	tests := []struct {
+		name     string
+		baseline Landmarks
+		frames   []Landmarks // 100ms ごとのフレーム
+		want     []int
+	}{
+		{"neutral", open, repeat(open, 3), nil},
+		{"wink with a nil pupil", open, repeat(left, winkFrames), []int{constants.WINK_LEFT}},
+		{"too short for a wink", open, repeat(left, winkFrames-1), nil},
+		{"wink with the right eye", open, repeat(landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			// 瞳が目頭と目尻を結んだ線から大きくずれている
+			return points, [][]int{pupils[0], {88, 120, 4}}
+		}), winkFrames), []int{constants.WINK_RIGHT}},
+		{"both eyes closed", open, repeat(both, winkFrames), nil},
+		{"double blink", open, []Landmarks{both, open, both, open}, []int{constants.DOUBLE_BLINK}},
+		{"single blink", open, []Landmarks{both, open, open}, nil},
+		{"closed eye with an invalid eye point", open, repeat(landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points[constants.L_EYE_OUTER] = []int{170}
+			return points, [][]int{nil, pupils[1]}
+		}), winkFrames), nil},
+		{"mouth open", open, repeat(landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points[constants.B_MOUTH] = []int{120, 210, 4}
+			return points, pupils
+		}), 1), []int{constants.MOUTH_OPEN}},
+		{"tilt left", open, repeat(landmarksWith(rotated(0.4)), 1), []int{constants.TILT_LEFT}},
+		{"tilt right", open, repeat(landmarksWith(rotated(-0.4)), 1), []int{constants.TILT_RIGHT}},
+		{"small tilt", open, repeat(landmarksWith(rotated(0.1)), 1), nil},
+		{"tilt without the nose", open, repeat(landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points, pupils = rotated(0.4)(points, pupils)
+			points[constants.NOSE] = nil
+			return points, pupils
+		}), 1), nil},
+		{"incomplete baseline", landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points[constants.NOSE] = nil
+			return points, pupils
+		}), append(repeat(left, winkFrames), landmarksWith(rotated(0.4))), nil},
+		{"all-zero frames", open, repeat(landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			for i := range points {
+				points[i] = []int{0, 0, 0}
+			}
+			return points, [][]int{{0, 0, 0}, {0, 0, 0}}
+		}), winkFrames), nil},
+		{"empty frames", open, repeat(Landmarks{}, winkFrames), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFace(tt.baseline)
+			start := time.Unix(0, 0)
+			var flags []bool
+			for i, l := range tt.frames {
+				flags = make([]bool, constants.EMOTION_COUNT)
+				f.detectGestures(flags, l, start.Add(time.Duration(i)*100*time.Millisecond))
+			}
+
+			want := make([]bool, constants.EMOTION_COUNT)
+			for _, i := range tt.want {
+				want[i] = true
+			}
+			for i := range want {
+				if flags[i] != want[i] {
+					t.Errorf("%s = %v, want %v", EmotionName(i), flags[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+// ダブルまばたきは 2 回のまばたきの間隔が doubleBlinkWindow を超えると判定しない
+func TestDoubleBlinkWindow(t *testing.T) {
+	open, both := landmarksWith(nil), landmarksWith(closedBoth)
+	tests := []struct {
+		gap  time.Duration
+		want bool
+	}{
+		{doubleBlinkWindow / 2, true},
+		{doubleBlinkWindow, true},
+		{doubleBlinkWindow + time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		f := NewFace(open)
+		start := time.Unix(0, 0)
+		frame := func(l Landmarks, at time.Time) []bool {
+			flags := make([]bool, constants.EMOTION_COUNT)
+			f.detectGestures(flags, l, at)
+			return flags
+		}
+		frame(both, start)
+		frame(open, start.Add(50*time.Millisecond))
+		frame(both, start.Add(50*time.Millisecond+tt.gap-time.Millisecond))
+		flags := frame(open, start.Add(50*time.Millisecond+tt.gap))
+		if flags[constants.DOUBLE_BLINK] != tt.want {
+			t.Errorf("gap %v: DOUBLE_BLINK = %v, want %v", tt.gap, flags[constants.DOUBLE_BLINK], tt.want)
+		}
+	}
+}
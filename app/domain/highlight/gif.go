@@ -0,0 +1,138 @@
+package highlight
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"square-face-tetris/app/domain/engine"
+)
+
+// GIF の各部の大きさ
+const (
+	cellSize      = 6  // ボードの 1 マス
+	margin        = 4  // 各部の間隔
+	barHeight     = 6  // 見どころの種類を表す帯
+	meterHeight   = 24 // 表情の確からしさのメーター
+	defaultHeight = 96 // カメラがない場合の映像部分の高さ
+)
+
+var (
+	backgroundColor = color.RGBA{16, 16, 16, 255}
+	boardColor      = color.RGBA{40, 40, 40, 255}
+	blockColor      = color.RGBA{160, 160, 160, 255}
+	meterColor      = color.RGBA{128, 200, 255, 255}
+
+	// 見どころの種類ごとの帯の色
+	clipColors = map[string]color.Color{
+		engine.EventLineClear: color.RGBA{64, 220, 64, 255},
+		engine.EventNearDeath: color.RGBA{240, 48, 48, 255},
+	}
+)
+
+// ErrNoClips は書き出す見どころがないことを表す
+var ErrNoClips = errors.New("highlight: no clips")
+
+// EncodeGIF は見どころをつなげたアニメーション GIF を書き出す
+// 各コマはカメラの映像・表情の確からしさ・ボードを横に並べ、上端の帯で見どころの種類を表す
+func (r *Recorder) EncodeGIF(w io.Writer) error {
+	clips := r.Clips()
+	if len(clips) == 0 {
+		return ErrNoClips
+	}
+
+	bounds := frameBounds(clips[0].Frames[0])
+	delay := r.Interval * 100 / engine.TPS // 1/100 秒単位
+
+	anim := &gif.GIF{}
+	for _, clip := range clips {
+		for _, s := range clip.Frames {
+			anim.Image = append(anim.Image, renderFrame(bounds, clip.Type, s))
+			anim.Delay = append(anim.Delay, delay)
+		}
+		// 見どころの区切りで少し止める
+		anim.Delay[len(anim.Delay)-1] = delay * 4
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// frameBounds はスナップショットから 1 コマの大きさを求める
+func frameBounds(s Snapshot) image.Rectangle {
+	cameraW, cameraH := cameraWidth, defaultHeight
+	if s.Camera != nil {
+		cameraH = s.Camera.Bounds().Dy()
+	}
+	boardW, boardH := 0, len(s.Board)*cellSize
+	if len(s.Board) > 0 {
+		boardW = len(s.Board[0]) * cellSize
+	}
+
+	left := cameraH + margin + meterHeight
+	if boardH > left {
+		left = boardH
+	}
+	return image.Rect(0, 0, margin+cameraW+margin+boardW+margin, barHeight+margin+left+margin)
+}
+
+// renderFrame は 1 コマを描画する
+func renderFrame(bounds image.Rectangle, clipType string, s Snapshot) *image.Paletted {
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, image.NewUniform(backgroundColor), image.Point{}, draw.Src)
+
+	// 見どころの種類の帯
+	if c, ok := clipColors[clipType]; ok {
+		fillRect(img, image.Rect(0, 0, bounds.Dx(), barHeight), c)
+	}
+
+	// カメラの映像
+	top := barHeight + margin
+	cameraH := defaultHeight
+	if s.Camera != nil {
+		cameraH = s.Camera.Bounds().Dy()
+		draw.Draw(img, image.Rect(margin, top, margin+cameraWidth, top+cameraH), s.Camera, image.Point{}, draw.Src)
+	}
+
+	// 表情の確からしさ（表情ごとの縦棒）
+	meterTop := top + cameraH + margin
+	if n := len(s.Confidences); n > 0 {
+		width := cameraWidth / n
+		for i, c := range s.Confidences {
+			h := int(c * meterHeight)
+			x := margin + i*width
+			fillRect(img, image.Rect(x+1, meterTop+meterHeight-h, x+width-1, meterTop+meterHeight), meterColor)
+		}
+	}
+
+	// ボード
+	boardLeft := margin + cameraWidth + margin
+	if len(s.Board) > 0 {
+		fillRect(img, image.Rect(boardLeft, top, boardLeft+len(s.Board[0])*cellSize, top+len(s.Board)*cellSize), boardColor)
+	}
+	for y := range s.Board {
+		for x := range s.Board[y] {
+			var c color.Color
+			switch {
+			case s.Piece[y][x] && s.PieceColor != nil:
+				c = s.PieceColor
+			case s.Board[y][x] != 0:
+				c = blockColor
+			default:
+				continue
+			}
+			cx, cy := boardLeft+x*cellSize, top+y*cellSize
+			fillRect(img, image.Rect(cx, cy, cx+cellSize-1, cy+cellSize-1), c)
+		}
+	}
+
+	paletted := image.NewPaletted(bounds, palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, bounds, img, image.Point{})
+	return paletted
+}
+
+func fillRect(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
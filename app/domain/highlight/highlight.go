@@ -0,0 +1,153 @@
+// Package highlight はゲーム中の見どころ（行を消した・危なくなったなど）の前後の
+// カメラの映像とボードを記録し、アニメーション GIF として書き出す
+package highlight
+
+import (
+	"image"
+	"image/color"
+
+	"square-face-tetris/app/domain/engine"
+)
+
+// 既定の記録の間隔と長さ
+const (
+	DefaultInterval = 10 // 何フレームごとに記録するか（60 TPS で 6 fps）
+	DefaultBefore   = 12 // 出来事の前に残すスナップショットの数
+	DefaultAfter    = 12 // 出来事の後に残すスナップショットの数
+	DefaultMaxClips = 8  // 残す見どころの数
+)
+
+// 記録するカメラの映像の幅（高さは縦横比に合わせる）
+const cameraWidth = 128
+
+// Snapshot はある時点のカメラの映像とボード
+type Snapshot struct {
+	Frame       int
+	Camera      *image.RGBA // 縮小したカメラの映像（カメラがない場合は nil）
+	Board       [][]int     // 固定されたブロック
+	Piece       [][]bool    // 操作中のテトリミノがあるマス
+	PieceColor  color.Color // 操作中のテトリミノの色
+	Confidences []float64   // 表情ごとの確からしさ
+}
+
+// Clip は 1 つの見どころ
+type Clip struct {
+	Type   string // engine.EventLineClear など
+	Frames []Snapshot
+}
+
+// Recorder は直近のスナップショットを保持し、出来事があったときにその前後を見どころとして残す
+type Recorder struct {
+	Interval int
+	Before   int
+	After    int
+	MaxClips int
+
+	history   []Snapshot // 直近の Before 個のスナップショット
+	clips     []Clip
+	recording *Clip // 出来事の後を記録中の見どころ
+	remaining int   // recording に追加する残りのスナップショット数
+}
+
+// NewRecorder は既定の設定で記録を始める
+func NewRecorder() *Recorder {
+	return &Recorder{
+		Interval: DefaultInterval,
+		Before:   DefaultBefore,
+		After:    DefaultAfter,
+		MaxClips: DefaultMaxClips,
+	}
+}
+
+// Capture は Interval フレームごとにスナップショットを記録する
+// camera は現在のカメラの映像（ない場合は nil）
+func (r *Recorder) Capture(e *engine.Engine, camera image.Image, confidences []float64) {
+	if e.Frame%r.Interval != 0 {
+		return
+	}
+	s := newSnapshot(e, camera, confidences)
+
+	if r.recording != nil {
+		r.recording.Frames = append(r.recording.Frames, s)
+		r.remaining--
+		if r.remaining <= 0 {
+			r.clips = append(r.clips, *r.recording)
+			r.recording = nil
+		}
+	}
+
+	r.history = append(r.history, s)
+	if len(r.history) > r.Before {
+		r.history = r.history[len(r.history)-r.Before:]
+	}
+}
+
+// Mark は出来事があったことを記録し、その前後を見どころとして残す
+// 前の見どころを記録中の場合は、その見どころを延長する
+func (r *Recorder) Mark(eventType string) {
+	if r.recording != nil {
+		r.remaining = r.After
+		return
+	}
+	if len(r.clips) >= r.MaxClips {
+		return
+	}
+	r.recording = &Clip{
+		Type:   eventType,
+		Frames: append([]Snapshot{}, r.history...),
+	}
+	r.remaining = r.After
+}
+
+// Clips は残した見どころを返す（記録中のものも含む）
+func (r *Recorder) Clips() []Clip {
+	clips := append([]Clip{}, r.clips...)
+	if r.recording != nil && len(r.recording.Frames) > 0 {
+		clips = append(clips, *r.recording)
+	}
+	return clips
+}
+
+func newSnapshot(e *engine.Engine, camera image.Image, confidences []float64) Snapshot {
+	s := Snapshot{
+		Frame:       e.Frame,
+		Camera:      shrink(camera, cameraWidth),
+		Board:       make([][]int, len(e.Board)),
+		Piece:       make([][]bool, len(e.Board)),
+		Confidences: append([]float64{}, confidences...),
+	}
+	for y, row := range e.Board {
+		s.Board[y] = append([]int{}, row...)
+		s.Piece[y] = make([]bool, len(row))
+	}
+
+	if c := e.Current; c != nil {
+		s.PieceColor = c.Color
+		for y := range c.Shape {
+			for x := range c.Shape[y] {
+				by, bx := c.Y+y, c.X+x
+				if c.Shape[y][x] == 1 && by >= 0 && by < len(s.Piece) && bx >= 0 && bx < len(s.Piece[by]) {
+					s.Piece[by][bx] = true
+				}
+			}
+		}
+	}
+	return s
+}
+
+// shrink は画像を幅 width に縮小した複製を返す（最近傍法）
+func shrink(src image.Image, width int) *image.RGBA {
+	if src == nil || src.Bounds().Dx() == 0 {
+		return nil
+	}
+	b := src.Bounds()
+	height := b.Dy() * width / b.Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := b.Min.Y + y*b.Dy()/height
+		for x := 0; x < width; x++ {
+			dst.Set(x, y, src.At(b.Min.X+x*b.Dx()/width, sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,84 @@
+package input
+
+import "fmt"
+
+// Action はテトリミノに対する操作
+// キーボードや顔のジェスチャーなどの入力は、全て Action に変換してからゲームに渡す
+type Action int
+
+const (
+	MoveLeft Action = iota
+	MoveRight
+	SoftDrop
+	HardDrop
+	RotateCW
+	RotateCCW
+	Hold
+	Pause
+)
+
+// 全ての操作（設定画面の表示順）
+var Actions = []Action{MoveLeft, MoveRight, SoftDrop, HardDrop, RotateCW, RotateCCW, Hold, Pause}
+
+var actionNames = map[Action]string{
+	MoveLeft:  "MoveLeft",
+	MoveRight: "MoveRight",
+	SoftDrop:  "SoftDrop",
+	HardDrop:  "HardDrop",
+	RotateCW:  "RotateCW",
+	RotateCCW: "RotateCCW",
+	Hold:      "Hold",
+	Pause:     "Pause",
+}
+
+func (a Action) String() string {
+	if name, ok := actionNames[a]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// IsHeld は押している間ずっと発生し続ける操作かを返す
+// それ以外の操作は押した瞬間に 1 回だけ発生する
+func (a Action) IsHeld() bool {
+	return a == SoftDrop
+}
+
+// MarshalText は設定ファイルに操作の名前で保存するために使う
+func (a Action) MarshalText() ([]byte, error) {
+	if _, ok := actionNames[a]; !ok {
+		return nil, fmt.Errorf("不明な操作です: %d", int(a))
+	}
+	return []byte(a.String()), nil
+}
+
+func (a *Action) UnmarshalText(text []byte) error {
+	for action, name := range actionNames {
+		if name == string(text) {
+			*a = action
+			return nil
+		}
+	}
+	return fmt.Errorf("不明な操作です: %s", text)
+}
+
+// Mask は操作の組を、Action 番目のビットを立てたビットマスクにする
+// リプレイや通信で 1 フレームの操作をまとめて扱うために使う
+func Mask(actions []Action) int {
+	mask := 0
+	for _, a := range actions {
+		mask |= 1 << uint(a)
+	}
+	return mask
+}
+
+// FromMask はビットマスクを Actions の順の操作の組に戻す
+func FromMask(mask int) []Action {
+	var actions []Action
+	for _, a := range Actions {
+		if mask&(1<<uint(a)) != 0 {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
@@ -0,0 +1,127 @@
+package input
+
+import (
+	"encoding/json"
+	"errors"
+
+	"square-face-tetris/app/domain/storage"
+)
+
+// 設定を保存するときのキー
+const bindingsStorageKey = "bindings"
+
+// Bindings は入力と操作の対応
+// キーボードのキーは ebiten.Key の名前、ゲームパッドのボタンは GamepadButtons の名前、
+// 顔の入力は Gesture の名前か表情・ジェスチャーの名前（WINK_LEFT など）で表す
+type Bindings struct {
+	Keyboard map[Action][]string `json:"keyboard"`
+	Gamepad  map[Action][]string `json:"gamepad"`
+	Face     map[Action][]string `json:"face"`
+}
+
+// 標準配列のゲームパッドのボタン名（ebiten.StandardGamepadButton の順）
+var GamepadButtons = []string{
+	"A", "B", "X", "Y",
+	"LB", "RB", "LT", "RT",
+	"Back", "Start", "LS", "RS",
+	"Up", "Down", "Left", "Right",
+	"Home",
+}
+
+// 顔の入力として割り当てられる名前
+var FaceInputs = []string{
+	HeadLeft.String(), HeadRight.String(), HeadDown.String(), HeadTiltLeft.String(), HeadTiltRight.String(),
+	"WINK_LEFT", "WINK_RIGHT", "DOUBLE_BLINK", "MOUTH_OPEN",
+}
+
+func DefaultBindings() Bindings {
+	return Bindings{
+		Keyboard: map[Action][]string{
+			MoveLeft:  {"ArrowLeft"},
+			MoveRight: {"ArrowRight"},
+			SoftDrop:  {"ArrowDown"},
+			HardDrop:  {"Space"},
+			RotateCW:  {"ArrowUp", "X"},
+			RotateCCW: {"Z"},
+			Hold:      {"C", "ShiftLeft"},
+			Pause:     {"Escape", "P"},
+		},
+		Gamepad: map[Action][]string{
+			MoveLeft:  {"Left"},
+			MoveRight: {"Right"},
+			SoftDrop:  {"Down"},
+			HardDrop:  {"Up"},
+			RotateCW:  {"A"},
+			RotateCCW: {"B"},
+			Hold:      {"LB", "RB"},
+			Pause:     {"Start"},
+		},
+		Face: map[Action][]string{
+			MoveLeft:  {HeadLeft.String(), "WINK_LEFT"},
+			MoveRight: {HeadRight.String(), "WINK_RIGHT"},
+			SoftDrop:  {HeadDown.String()},
+			RotateCW:  {HeadTiltRight.String(), "DOUBLE_BLINK"},
+			RotateCCW: {HeadTiltLeft.String()},
+		},
+	}
+}
+
+// LoadBindings は保存されている設定を読み込む
+// 保存されていない場合は初期設定を返す
+func LoadBindings() (Bindings, error) {
+	data, err := storage.Load(bindingsStorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return DefaultBindings(), nil
+	}
+	if err != nil {
+		return DefaultBindings(), err
+	}
+
+	b := DefaultBindings()
+	if err := json.Unmarshal(data, &b); err != nil {
+		return DefaultBindings(), err
+	}
+	return b, nil
+}
+
+// Save は設定を保存する
+func (b Bindings) Save() error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return storage.Save(bindingsStorageKey, data)
+}
+
+// ActionsFor は入力名に割り当てられている操作を返す
+func ActionsFor(bindings map[Action][]string, name string) []Action {
+	var actions []Action
+	for _, action := range Actions {
+		for _, n := range bindings[action] {
+			if n == name {
+				actions = append(actions, action)
+				break
+			}
+		}
+	}
+	return actions
+}
+
+// Bind は操作に入力を 1 つだけ割り当てる（既存の割り当ては置き換える）
+// 同じ入力が他の操作に割り当てられていた場合は外す
+func Bind(bindings map[Action][]string, action Action, name string) {
+	for a, names := range bindings {
+		bindings[a] = remove(names, name)
+	}
+	bindings[action] = []string{name}
+}
+
+func remove(names []string, name string) []string {
+	var result []string
+	for _, n := range names {
+		if n != name {
+			result = append(result, n)
+		}
+	}
+	return result
+}
@@ -0,0 +1,149 @@
+package input
+
+import (
+	"math"
+	"time"
+
+	"square-face-tetris/app/domain"
+)
+
+// Gesture は頭の動きによる入力
+// Bindings.Face で操作に割り当てる
+type Gesture int
+
+const (
+	HeadLeft      Gesture = iota // プレイヤーから見て左を向く
+	HeadRight                    // プレイヤーから見て右を向く
+	HeadDown                     // 下を向く
+	HeadTiltLeft                 // 頭を左肩側に傾ける
+	HeadTiltRight                // 頭を右肩側に傾ける
+)
+
+func (g Gesture) String() string {
+	switch g {
+	case HeadLeft:
+		return "HEAD_LEFT"
+	case HeadRight:
+		return "HEAD_RIGHT"
+	case HeadDown:
+		return "HEAD_DOWN"
+	case HeadTiltLeft:
+		return "HEAD_TILT_LEFT"
+	case HeadTiltRight:
+		return "HEAD_TILT_RIGHT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// GestureConfig は頭の動きを入力に変換するときの設定
+// 角度は全て較正した正面の向きからの差（ラジアン）
+type GestureConfig struct {
+	YawDeadZone   float64 // この角度を超えて左右を向くと移動する
+	PitchDeadZone float64 // この角度を超えて下を向くと入力になる
+	RollDeadZone  float64 // この角度を超えて傾けると入力になる
+	MaxAngle      float64 // リピートが最も速くなる角度
+
+	// 向きが DeadZone * ReleaseRatio より正面に戻ったら、次の入力を受け付ける
+	ReleaseRatio float64
+
+	RepeatDelay       time.Duration // 向け続けたときにリピートが始まるまでの時間
+	RepeatInterval    time.Duration // DeadZone ぎりぎりで向け続けたときのリピート間隔
+	MinRepeatInterval time.Duration // MaxAngle まで向けたときのリピート間隔
+
+	// 角度に対するリピートの速さの曲線（1 で線形、大きいほど大きく向けたときだけ速くなる）
+	Curve float64
+}
+
+var DefaultGestureConfig = GestureConfig{
+	YawDeadZone:       0.25,
+	PitchDeadZone:     0.2,
+	RollDeadZone:      0.25,
+	MaxAngle:          0.6,
+	ReleaseRatio:      0.6,
+	RepeatDelay:       400 * time.Millisecond,
+	RepeatInterval:    250 * time.Millisecond,
+	MinRepeatInterval: 60 * time.Millisecond,
+	Curve:             2,
+}
+
+// GestureController は頭の向きから入力を作る
+//
+// 各方向はボタンのように扱い、DeadZone を超えた瞬間に 1 回だけ入力を発生させる。
+// 左右と下は向け続けるとリピートし、傾きはリピートしない。
+// 一度発生した方向は正面付近に戻るまで再び発生しない
+type GestureController struct {
+	Config GestureConfig
+
+	left, right, down, tiltLeft, tiltRight gestureButton
+}
+
+func NewGestureController(config GestureConfig) *GestureController {
+	return &GestureController{Config: config}
+}
+
+// 1 方向分の状態
+type gestureButton struct {
+	pressed    bool
+	nextRepeat time.Time
+}
+
+// Update は現在の頭の向きから、このフレームで発生した入力を返す
+//
+// 較正した向きはカメラ映像の座標系なので、映像の右側を向く（yaw が正）のは
+// プレイヤーにとって左を向いたことになる。傾きも同様に、映像上で時計回り（roll が正）は
+// 左肩側に傾けたことになる
+func (c *GestureController) Update(pose domain.HeadPose, now time.Time) []Gesture {
+	var gestures []Gesture
+	cfg := c.Config
+
+	gestures = c.left.update(gestures, HeadLeft, pose.Yaw, cfg.YawDeadZone, true, cfg, now)
+	gestures = c.right.update(gestures, HeadRight, -pose.Yaw, cfg.YawDeadZone, true, cfg, now)
+	gestures = c.down.update(gestures, HeadDown, pose.Pitch, cfg.PitchDeadZone, true, cfg, now)
+	gestures = c.tiltLeft.update(gestures, HeadTiltLeft, pose.Roll, cfg.RollDeadZone, false, cfg, now)
+	gestures = c.tiltRight.update(gestures, HeadTiltRight, -pose.Roll, cfg.RollDeadZone, false, cfg, now)
+
+	return gestures
+}
+
+// Reset は全ての方向を離した状態に戻す
+func (c *GestureController) Reset() {
+	c.left, c.right, c.down = gestureButton{}, gestureButton{}, gestureButton{}
+	c.tiltLeft, c.tiltRight = gestureButton{}, gestureButton{}
+}
+
+// update は value（その方向への角度）に応じて入力を追加する
+func (b *gestureButton) update(gestures []Gesture, gesture Gesture, value, deadZone float64, repeat bool, cfg GestureConfig, now time.Time) []Gesture {
+	if !b.pressed {
+		if value > deadZone {
+			b.pressed = true
+			b.nextRepeat = now.Add(cfg.RepeatDelay)
+			gestures = append(gestures, gesture)
+		}
+		return gestures
+	}
+
+	// 正面付近に戻ったら離したとみなす
+	if value < deadZone*cfg.ReleaseRatio {
+		b.pressed = false
+		return gestures
+	}
+
+	if repeat && value > deadZone && !now.Before(b.nextRepeat) {
+		gestures = append(gestures, gesture)
+		b.nextRepeat = now.Add(cfg.repeatInterval(value, deadZone))
+	}
+	return gestures
+}
+
+// repeatInterval は角度が大きいほど短くなるリピート間隔を返す
+func (cfg GestureConfig) repeatInterval(value, deadZone float64) time.Duration {
+	t := 0.0
+	if cfg.MaxAngle > deadZone {
+		t = (value - deadZone) / (cfg.MaxAngle - deadZone)
+	}
+	t = math.Pow(math.Max(0, math.Min(1, t)), cfg.Curve)
+
+	interval := float64(cfg.RepeatInterval) + t*float64(cfg.MinRepeatInterval-cfg.RepeatInterval)
+	return time.Duration(interval)
+}
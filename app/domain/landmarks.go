@@ -0,0 +1,104 @@
+package domain
+
+import "square-face-tetris/app/constants"
+
+// Point はランドマークや瞳の 1 点
+type Point struct {
+	X, Y, Scale int
+	Valid       bool // 検出できたか（false の場合、座標には意味がない）
+}
+
+// Landmarks は 1 フレーム分の顔のランドマークと左右の瞳
+// Points は constants.R_EYEBROW_OUTER などのインデックス順
+type Landmarks struct {
+	Points [constants.LANDMARK_COUNT]Point
+	Pupils [2]Point // 左右の瞳
+}
+
+// NewLandmarks は検出結果の [x, y, scale] の配列から Landmarks を作る
+// 要素が足りない点や nil の点は、検出できなかった点として扱う
+func NewLandmarks(points, pupils [][]int) Landmarks {
+	var l Landmarks
+	for i := 0; i < len(points) && i < len(l.Points); i++ {
+		l.Points[i] = newPoint(points[i])
+	}
+	for i := 0; i < len(pupils) && i < len(l.Pupils); i++ {
+		l.Pupils[i] = newPoint(pupils[i])
+	}
+	return l
+}
+
+func newPoint(p []int) Point {
+	if len(p) < 2 {
+		return Point{}
+	}
+	point := Point{X: p[0], Y: p[1], Valid: true}
+	if len(p) >= 3 {
+		point.Scale = p[2]
+	}
+	return point
+}
+
+// Has は指定したインデックスの点がすべて検出できているかを返す
+func (l Landmarks) Has(indexes ...int) bool {
+	for _, i := range indexes {
+		if !l.Points[i].Valid {
+			return false
+		}
+	}
+	return true
+}
+
+// Complete は 15 点すべてのランドマークが検出できているかを返す
+func (l Landmarks) Complete() bool {
+	return l.Count() == len(l.Points)
+}
+
+// Count は検出できたランドマークの点の数を返す
+func (l Landmarks) Count() int {
+	n := 0
+	for _, p := range l.Points {
+		if p.Valid {
+			n++
+		}
+	}
+	return n
+}
+
+// Quality は検出できたランドマークと瞳の割合（0 から 1）を返す
+func (l Landmarks) Quality() float64 {
+	n := l.Count()
+	for _, p := range l.Pupils {
+		if p.Valid {
+			n++
+		}
+	}
+	return float64(n) / float64(len(l.Points)+len(l.Pupils))
+}
+
+// Slice は [x, y, scale] の配列に戻したランドマークを返す（検出できなかった点は nil）
+// データセットの記録など、JSON に書き出すときに使う
+func (l Landmarks) Slice() [][]int {
+	return pointsToSlice(l.Points[:])
+}
+
+// PupilSlice は [x, y, scale] の配列に戻した左右の瞳を返す（検出できなかった瞳は nil）
+func (l Landmarks) PupilSlice() [][]int {
+	return pointsToSlice(l.Pupils[:])
+}
+
+// withoutPupils は瞳を検出できなかったものとした Landmarks を返す
+func (l Landmarks) withoutPupils() Landmarks {
+	l.Pupils = [2]Point{}
+	return l
+}
+
+func pointsToSlice(points []Point) [][]int {
+	s := make([][]int, len(points))
+	for i, p := range points {
+		if p.Valid {
+			s[i] = []int{p.X, p.Y, p.Scale}
+		}
+	}
+	return s
+}
@@ -0,0 +1,139 @@
+// Package leaderboard はモードごとの上位の成績を保存する
+package leaderboard
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/storage"
+)
+
+// モードごとに残す成績の数
+const MaxEntries = 10
+
+// 名前の最大文字数
+const MaxNameLength = 12
+
+// 成績を記録するモード名
+const (
+	ModeNormal = "normal" // 1 人で遊ぶ
+	ModeCoop   = "coop"   // 2 つの顔で 1 つのボードを操作する協力プレイ
+)
+
+// GameModes はゲームが成績を記録するモード名（名前順）
+var GameModes = []string{ModeCoop, ModeNormal}
+
+// 保存先のキー
+const storageKey = "leaderboard"
+
+// Entry は 1 ゲーム分の成績
+type Entry struct {
+	Name     string         `json:"name"`
+	Score    int            `json:"score"`
+	Lines    int            `json:"lines"`
+	Level    int            `json:"level"`
+	Duration time.Duration  `json:"duration"`
+	Date     time.Time      `json:"date"`
+	Emotions map[string]int `json:"emotions"` // 表情ごとの、次のテトリミノを選んだ回数
+}
+
+// NewEntry は終了したゲームから成績を作る
+func NewEntry(e *engine.Engine, date time.Time) Entry {
+	emotions := map[string]int{}
+	for i := 0; i < constants.EMOTION_COUNT && i < len(e.Stats.EmotionPicks); i++ {
+		if e.Stats.EmotionPicks[i] > 0 {
+			emotions[domain.EmotionName(i)] = e.Stats.EmotionPicks[i]
+		}
+	}
+	return Entry{
+		Score:    e.Score,
+		Lines:    e.Lines,
+		Level:    e.Level(),
+		Duration: e.Elapsed(),
+		Date:     date,
+		Emotions: emotions,
+	}
+}
+
+// Leaderboard はモード名ごとの成績の一覧（スコアの高い順）
+type Leaderboard map[string][]Entry
+
+// Load は保存されている成績を読み込む
+// 保存されていない場合は空の一覧を返す
+func Load() (Leaderboard, error) {
+	data, err := storage.Load(storageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return Leaderboard{}, nil
+	}
+	if err != nil {
+		return Leaderboard{}, err
+	}
+
+	lb := Leaderboard{}
+	if err := json.Unmarshal(data, &lb); err != nil {
+		return Leaderboard{}, err
+	}
+	return lb, nil
+}
+
+// Save は成績を保存する
+func (lb Leaderboard) Save() error {
+	data, err := json.Marshal(lb)
+	if err != nil {
+		return err
+	}
+	return storage.Save(storageKey, data)
+}
+
+// Qualifies はスコアが mode の上位に入るか判定する
+func (lb Leaderboard) Qualifies(mode string, score int) bool {
+	entries := lb[mode]
+	if len(entries) < MaxEntries {
+		return true
+	}
+	return score > entries[len(entries)-1].Score
+}
+
+// Add は成績を追加し、順位（1 から始まる）を返す
+// 上位に入らなかった場合は 0 を返す
+func (lb Leaderboard) Add(mode string, entry Entry) int {
+	if !lb.Qualifies(mode, entry.Score) {
+		return 0
+	}
+
+	entries := append(lb[mode], entry)
+	// 同点の場合は先に記録したものを上にする
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Score > entries[j].Score
+	})
+	if len(entries) > MaxEntries {
+		entries = entries[:MaxEntries]
+	}
+	lb[mode] = entries
+
+	// 全く同じ成績が既にある場合も、追加したものは同点の中で一番下にある
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Date.Equal(entry.Date) && entries[i].Name == entry.Name && entries[i].Score == entry.Score {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Modes は GameModes と、成績のあるモード名を名前順に返す
+func (lb Leaderboard) Modes() []string {
+	modes := append([]string{}, GameModes...)
+	for mode := range lb {
+		if !slices.Contains(GameModes, mode) {
+			modes = append(modes, mode)
+		}
+	}
+	sort.Strings(modes)
+	return modes
+}
@@ -0,0 +1,179 @@
+package leaderboard
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"square-face-tetris/app/domain/storage"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// entry は start から minute 分後に記録した成績
+func entry(name string, score, minute int) Entry {
+	return Entry{Name: name, Score: score, Date: start.Add(time.Duration(minute) * time.Minute)}
+}
+
+// full は MaxEntries 件の成績（スコアは 100, 90, ..., 10）
+func full() []Entry {
+	entries := make([]Entry, MaxEntries)
+	for i := range entries {
+		entries[i] = entry("CPU", 100-10*i, i)
+	}
+	return entries
+}
+
+func names(entries []Entry) []string {
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   []Entry
+		add       Entry
+		wantRank  int
+		wantNames []string
+	}{
+		{"empty", nil, entry("A", 10, 0), 1, []string{"A"}},
+		{"highest", []Entry{entry("B", 20, 0), entry("C", 10, 1)}, entry("A", 30, 2), 1, []string{"A", "B", "C"}},
+		{"middle", []Entry{entry("B", 20, 0), entry("C", 10, 1)}, entry("A", 15, 2), 2, []string{"B", "A", "C"}},
+		{"lowest", []Entry{entry("B", 20, 0), entry("C", 10, 1)}, entry("A", 5, 2), 3, []string{"B", "C", "A"}},
+		{"tie goes below earlier entries", []Entry{entry("B", 20, 0), entry("C", 20, 1), entry("D", 10, 2)}, entry("A", 20, 3), 3, []string{"B", "C", "A", "D"}},
+		{"same name and score at another date", []Entry{entry("A", 20, 0), entry("A", 20, 1)}, entry("A", 20, 2), 3, []string{"A", "A", "A"}},
+		{"identical entry", []Entry{entry("A", 20, 0), entry("B", 10, 1)}, entry("A", 20, 0), 2, []string{"A", "A", "B"}},
+		{"same date and score with another name", []Entry{entry("B", 20, 0)}, entry("A", 20, 0), 2, []string{"B", "A"}},
+		{"full and higher", full(), entry("A", 95, 20), 2, []string{"CPU", "A", "CPU", "CPU", "CPU", "CPU", "CPU", "CPU", "CPU", "CPU"}},
+		{"full and above the last", full(), entry("A", 11, 20), 10, []string{"CPU", "CPU", "CPU", "CPU", "CPU", "CPU", "CPU", "CPU", "CPU", "A"}},
+		{"full and tied with the last", full(), entry("A", 10, 20), 0, names(full())},
+		{"full and lower", full(), entry("A", 0, 20), 0, names(full())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := Leaderboard{ModeNormal: tt.entries, ModeCoop: full()}
+			if got := lb.Add(ModeNormal, tt.add); got != tt.wantRank {
+				t.Errorf("rank = %d, want %d", got, tt.wantRank)
+			}
+			if got := names(lb[ModeNormal]); !reflect.DeepEqual(got, tt.wantNames) {
+				t.Errorf("names = %v, want %v", got, tt.wantNames)
+			}
+			if tt.wantRank > 0 && !reflect.DeepEqual(lb[ModeNormal][tt.wantRank-1], tt.add) {
+				t.Errorf("entry at rank %d = %+v, want %+v", tt.wantRank, lb[ModeNormal][tt.wantRank-1], tt.add)
+			}
+			if len(lb[ModeNormal]) > MaxEntries {
+				t.Errorf("%d entries, want at most %d", len(lb[ModeNormal]), MaxEntries)
+			}
+			if !reflect.DeepEqual(lb[ModeCoop], full()) {
+				t.Error("another mode changed")
+			}
+		})
+	}
+}
+
+func TestQualifies(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []Entry
+		score   int
+		want    bool
+	}{
+		{"empty", nil, 0, true},
+		{"not full", full()[:MaxEntries-1], 0, true},
+		{"full and higher than the last", full(), 11, true},
+		{"full and tied with the last", full(), 10, false},
+		{"full and lower", full(), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := Leaderboard{ModeNormal: tt.entries}
+			if got := lb.Qualifies(ModeNormal, tt.score); got != tt.want {
+				t.Errorf("Qualifies(%d) = %v, want %v", tt.score, got, tt.want)
+			}
+			if got := lb.Qualifies(ModeCoop, tt.score); !got {
+				t.Error("Qualifies in an empty mode = false")
+			}
+		})
+	}
+}
+
+// 成績を追加し続けても、上位 MaxEntries 件だけがスコアの高い順に残る
+func TestAddKeepsTopEntries(t *testing.T) {
+	lb := Leaderboard{}
+	for i := 0; i < 3*MaxEntries; i++ {
+		lb.Add(ModeNormal, entry("A", (i*7)%25, i))
+	}
+	entries := lb[ModeNormal]
+	if len(entries) != MaxEntries {
+		t.Fatalf("%d entries, want %d", len(entries), MaxEntries)
+	}
+	for i := 1; i < len(entries); i++ {
+		prev, cur := entries[i-1], entries[i]
+		if prev.Score < cur.Score || prev.Score == cur.Score && prev.Date.After(cur.Date) {
+			t.Errorf("entries %d and %d are out of order: %+v, %+v", i-1, i, prev, cur)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string // 保存されているデータ（空なら保存されていない）
+		want    Leaderboard
+		wantErr bool
+	}{
+		{"not saved", "", Leaderboard{}, false},
+		{"saved", `{"normal":[{"name":"A","score":20,"date":"2024-01-01T00:00:00Z"}]}`,
+			Leaderboard{ModeNormal: {{Name: "A", Score: 20, Date: start}}}, false},
+		{"broken", `{"normal":`, Leaderboard{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// storage は設定ディレクトリに保存するため、一時ディレクトリに向ける
+			dir := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", dir)
+			t.Setenv("HOME", dir)
+			t.Setenv("AppData", dir)
+			if tt.data != "" {
+				if err := storage.Save(storageKey, []byte(tt.data)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			lb, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(lb, tt.want) {
+				t.Errorf("Load = %+v, want %+v", lb, tt.want)
+			}
+		})
+	}
+}
+
+// 保存した成績を読み込むと、同じ成績になる
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+
+	lb := Leaderboard{ModeNormal: full(), ModeCoop: {entry("A", 10, 0)}}
+	if err := lb.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, lb) {
+		t.Errorf("Load = %+v, want %+v", loaded, lb)
+	}
+	if rank := loaded.Add(ModeCoop, entry("B", 20, 1)); rank != 1 {
+		t.Errorf("rank after loading = %d, want 1", rank)
+	}
+}
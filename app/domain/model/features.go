@@ -0,0 +1,68 @@
+package model
+
+import (
+	"math"
+
+	"square-face-tetris/app/constants"
+)
+
+// FeatureCount は Features が返す特徴量の数（全ペア間の距離と 3 つの比率）
+const FeatureCount = constants.LANDMARK_COUNT*(constants.LANDMARK_COUNT-1)/2 + 3
+
+// Features はランドマークから分類器に渡す特徴量を作る
+//
+// 顔の大きさやカメラとの距離に左右されないよう、全ての距離は眉尻間の距離
+// （NewFace の基準値と同じ）で正規化し、基準（無表情）の値との差をとる。
+// 特徴量は 15 点の全ペア間の距離と、口の縦横比などの比率からなる
+func Features(baseline, landmarks [][]int) []float64 {
+	current := rawFeatures(landmarks)
+	base := rawFeatures(baseline)
+	for i := range current {
+		current[i] -= base[i]
+	}
+	return current
+}
+
+// 1 フレーム分の正規化済み特徴量
+func rawFeatures(landmarks [][]int) []float64 {
+	scale := distance(landmarks[constants.L_EYEBROW_OUTER], landmarks[constants.R_EYEBROW_OUTER])
+	if scale == 0 {
+		scale = 1
+	}
+
+	n := constants.LANDMARK_COUNT
+	features := make([]float64, 0, FeatureCount)
+
+	// 全ペア間の距離
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			features = append(features, distance(landmarks[i], landmarks[j])/scale)
+		}
+	}
+
+	// 口の縦横比（驚き）
+	mouthWidth := distance(landmarks[constants.L_MOUTH], landmarks[constants.R_MOUTH])
+	mouthHeight := distance(landmarks[constants.T_MOUTH], landmarks[constants.B_MOUTH])
+	features = append(features, ratio(mouthHeight, mouthWidth))
+
+	// 眉間と眉尻の比（怒り）
+	eyebrowInner := distance(landmarks[constants.L_EYEBROW_INNER], landmarks[constants.R_EYEBROW_INNER])
+	features = append(features, eyebrowInner/scale)
+
+	// 左右の眉の高さの差（疑い）
+	eyebrowDiff := float64(landmarks[constants.L_EYEBROW_TOP][1] - landmarks[constants.R_EYEBROW_TOP][1])
+	features = append(features, eyebrowDiff/scale)
+
+	return features
+}
+
+func distance(p1, p2 []int) float64 {
+	return math.Hypot(float64(p2[0]-p1[0]), float64(p2[1]-p1[1]))
+}
+
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// KNN は k 近傍法による分類器
+// 学習データの特徴量をそのまま保持し、近い k 個の多数決でラベルを決める
+type KNN struct {
+	K       int         `json:"k"`
+	Points  [][]float64 `json:"points"`
+	Classes []int       `json:"classes"`
+}
+
+func trainKNN(x [][]float64, y []int, k int) *KNN {
+	if k <= 0 {
+		k = 5
+	}
+	return &KNN{K: k, Points: x, Classes: y}
+}
+
+// validate は k と学習データの形、クラス番号の範囲を確かめる
+func (m *KNN) validate(numClasses int) error {
+	if m.K <= 0 {
+		return fmt.Errorf("knn.k が不正です: %d", m.K)
+	}
+	if len(m.Points) == 0 || len(m.Points) != len(m.Classes) {
+		return errors.New("knn.points と knn.classes の数が合いません")
+	}
+	for i, p := range m.Points {
+		if len(p) != FeatureCount {
+			return fmt.Errorf("knn.points[%d] の特徴量の数は %d 個必要です: %d", i, FeatureCount, len(p))
+		}
+		if c := m.Classes[i]; c < 0 || c >= numClasses {
+			return fmt.Errorf("knn.classes[%d] が範囲外です: %d", i, c)
+		}
+	}
+	return nil
+}
+
+// probabilities は x に最も近い k 個のうち、各クラスだった割合を返す
+// 票数が同じ場合に距離の合計が小さいクラスが選ばれるよう、距離に応じてわずかに差を付ける
+func (m *KNN) probabilities(x []float64, numClasses int) []float64 {
+	type neighbor struct {
+		dist  float64
+		class int
+	}
+	neighbors := make([]neighbor, len(m.Points))
+	for i, p := range m.Points {
+		neighbors[i] = neighbor{squaredDistance(p, x), m.Classes[i]}
+	}
+	sort.Slice(neighbors, func(i, j int) bool {
+		return neighbors[i].dist < neighbors[j].dist
+	})
+
+	votes := make([]int, numClasses)
+	dists := make([]float64, numClasses)
+	k := 0
+	for ; k < m.K && k < len(neighbors); k++ {
+		votes[neighbors[k].class]++
+		dists[neighbors[k].class] += neighbors[k].dist
+	}
+
+	probs := make([]float64, numClasses)
+	if k == 0 {
+		return probs
+	}
+	for c := range probs {
+		probs[c] = float64(votes[c]) / float64(k)
+		if votes[c] > 0 {
+			probs[c] -= 1e-9 * dists[c] / (1 + dists[c])
+		}
+	}
+	return probs
+}
+
+func squaredDistance(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
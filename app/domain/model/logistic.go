@@ -0,0 +1,111 @@
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+// Logistic は多クラスロジスティック回帰（ソフトマックス回帰）による分類器
+type Logistic struct {
+	Weights [][]float64 `json:"weights"` // Weights[クラス][特徴量]（末尾はバイアス）
+}
+
+// LogisticOptions は学習時のパラメータ
+type LogisticOptions struct {
+	Epochs       int     // 勾配降下の反復回数
+	LearningRate float64 // 学習率
+	L2           float64 // L2 正則化の強さ
+}
+
+var DefaultLogisticOptions = LogisticOptions{
+	Epochs:       500,
+	LearningRate: 0.1,
+	L2:           0.001,
+}
+
+// trainLogistic はバッチ勾配降下法で重みを求める
+func trainLogistic(x [][]float64, y []int, numClasses int, opts LogisticOptions) *Logistic {
+	dim := len(x[0]) + 1
+	m := &Logistic{Weights: make([][]float64, numClasses)}
+	for c := range m.Weights {
+		m.Weights[c] = make([]float64, dim)
+	}
+
+	grad := make([][]float64, numClasses)
+	for c := range grad {
+		grad[c] = make([]float64, dim)
+	}
+
+	n := float64(len(x))
+	for epoch := 0; epoch < opts.Epochs; epoch++ {
+		for c := range grad {
+			for j := range grad[c] {
+				grad[c][j] = 0
+			}
+		}
+
+		for i, xi := range x {
+			probs := m.probabilities(xi)
+			for c := range probs {
+				diff := probs[c]
+				if c == y[i] {
+					diff -= 1
+				}
+				for j, v := range xi {
+					grad[c][j] += diff * v
+				}
+				grad[c][dim-1] += diff
+			}
+		}
+
+		for c := range m.Weights {
+			for j := range m.Weights[c] {
+				g := grad[c][j] / n
+				if j < dim-1 {
+					g += opts.L2 * m.Weights[c][j]
+				}
+				m.Weights[c][j] -= opts.LearningRate * g
+			}
+		}
+	}
+	return m
+}
+
+// validate は重みがクラスの数 x（特徴量の数 + バイアス）であるかを確かめる
+func (m *Logistic) validate(numClasses int) error {
+	if len(m.Weights) != numClasses {
+		return fmt.Errorf("logistic.weights の数は %d 個必要です: %d", numClasses, len(m.Weights))
+	}
+	for c, w := range m.Weights {
+		if len(w) != FeatureCount+1 {
+			return fmt.Errorf("logistic.weights[%d] の数は %d 個必要です: %d", c, FeatureCount+1, len(w))
+		}
+	}
+	return nil
+}
+
+// probabilities は各クラスである確率を返す
+func (m *Logistic) probabilities(x []float64) []float64 {
+	scores := make([]float64, len(m.Weights))
+	max := math.Inf(-1)
+	for c, w := range m.Weights {
+		score := w[len(w)-1]
+		for j, v := range x {
+			score += w[j] * v
+		}
+		scores[c] = score
+		if score > max {
+			max = score
+		}
+	}
+
+	var sum float64
+	for c := range scores {
+		scores[c] = math.Exp(scores[c] - max)
+		sum += scores[c]
+	}
+	for c := range scores {
+		scores[c] /= sum
+	}
+	return scores
+}
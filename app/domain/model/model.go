@@ -0,0 +1,223 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/dataset"
+)
+
+// モデルの種類
+const (
+	TypeKNN      = "knn"
+	TypeLogistic = "logistic"
+)
+
+// Model は記録したランドマークから学習した表情分類器
+// domain.Classifier を実装しているため、ルールベースの Face と差し替えて使える
+type Model struct {
+	Type     string    `json:"type"`
+	Labels   []string  `json:"labels"` // クラス番号に対応するラベル
+	Mean     []float64 `json:"mean"`   // 特徴量の標準化に使う平均
+	Std      []float64 `json:"std"`    // 特徴量の標準化に使う標準偏差
+	KNN      *KNN      `json:"knn,omitempty"`
+	Logistic *Logistic `json:"logistic,omitempty"`
+
+	baseline domain.Landmarks
+}
+
+// Options は学習時のパラメータ
+type Options struct {
+	Type     string
+	K        int // k 近傍法の k
+	Logistic LogisticOptions
+}
+
+// Train はデータセットからモデルを学習する
+// ランドマークが欠けているフレームや未知のラベルのフレームは使わない
+func Train(samples []dataset.Sample, opts Options) (*Model, error) {
+	m := &Model{Type: opts.Type, Labels: dataset.Labels()}
+	classes := make(map[string]int, len(m.Labels))
+	for i, label := range m.Labels {
+		classes[label] = i
+	}
+
+	var (
+		x [][]float64
+		y []int
+	)
+	for _, s := range samples {
+		class, ok := classes[s.Label]
+		if !ok || !s.Current().Complete() || !s.BaselineLandmarks().Complete() {
+			continue
+		}
+		x = append(x, Features(s.Baseline, s.Landmarks))
+		y = append(y, class)
+	}
+	if len(x) == 0 {
+		return nil, errors.New("学習に使えるフレームがありません")
+	}
+
+	m.Mean, m.Std = meanStd(x)
+	for _, xi := range x {
+		m.standardize(xi)
+	}
+
+	switch opts.Type {
+	case TypeKNN:
+		m.KNN = trainKNN(x, y, opts.K)
+	case TypeLogistic:
+		m.Logistic = trainLogistic(x, y, len(m.Labels), opts.Logistic)
+	default:
+		return nil, fmt.Errorf("不明なモデルの種類です: %s", opts.Type)
+	}
+	return m, nil
+}
+
+// Load は JSON 形式のモデルを読み込む
+func Load(r io.Reader) (*Model, error) {
+	var m Model
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("モデルの内容が不正です: %w", err)
+	}
+	return &m, nil
+}
+
+// validate は読み込んだモデルが分類に使える形かを確かめる
+// 古いモデルや手で編集したモデルで、分類中に範囲外の参照をしないようにする
+func (m *Model) validate() error {
+	if len(m.Labels) == 0 {
+		return errors.New("labels がありません")
+	}
+	if len(m.Mean) != FeatureCount || len(m.Std) != FeatureCount {
+		return fmt.Errorf("mean と std の数は %d 個必要です: %d, %d", FeatureCount, len(m.Mean), len(m.Std))
+	}
+	for j, s := range m.Std {
+		if s == 0 {
+			return fmt.Errorf("std[%d] が 0 です", j)
+		}
+	}
+
+	switch {
+	case m.Type == TypeKNN && m.KNN != nil:
+		return m.KNN.validate(len(m.Labels))
+	case m.Type == TypeLogistic && m.Logistic != nil:
+		return m.Logistic.validate(len(m.Labels))
+	}
+	return fmt.Errorf("不明なモデルの種類か、%s の内容がありません", m.Type)
+}
+
+// LoadFile はファイルからモデルを読み込む
+func LoadFile(path string) (*Model, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Save はモデルを JSON 形式で書き出す
+func (m *Model) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(m)
+}
+
+// Calibrate は基準となる（無表情の）ランドマークを設定する
+func (m *Model) Calibrate(baseline domain.Landmarks) {
+	m.baseline = baseline
+}
+
+// Classify は constants.SMILE などのインデックスに対応するフラグを返す
+// NEUTRAL と判定した場合やランドマークが欠けている場合は全て false になる
+func (m *Model) Classify(landmarks domain.Landmarks) []bool {
+	flags := make([]bool, constants.EMOTION_COUNT)
+	probs := m.classProbabilities(landmarks)
+	if probs == nil {
+		return flags
+	}
+
+	class := 0
+	for c := range probs {
+		if probs[c] > probs[class] {
+			class = c
+		}
+	}
+	if i := domain.EmotionIndex(m.Labels[class]); i >= 0 && i < len(flags) {
+		flags[i] = true
+	}
+	return flags
+}
+
+// Confidences は constants.SMILE などのインデックスに対応する確からしさを返す
+// k 近傍法では近い k 個のうちそのラベルだった割合、ロジスティック回帰では確率を使う
+func (m *Model) Confidences(landmarks domain.Landmarks) []float64 {
+	confidences := make([]float64, constants.EMOTION_COUNT)
+	for class, p := range m.classProbabilities(landmarks) {
+		if i := domain.EmotionIndex(m.Labels[class]); i >= 0 && i < len(confidences) {
+			confidences[i] = p
+		}
+	}
+	return confidences
+}
+
+// classProbabilities はクラスごとの確からしさを返す
+// ランドマークが欠けている場合は nil を返す
+func (m *Model) classProbabilities(landmarks domain.Landmarks) []float64 {
+	if !m.baseline.Complete() || !landmarks.Complete() {
+		return nil
+	}
+
+	x := Features(m.baseline.Slice(), landmarks.Slice())
+	m.standardize(x)
+
+	switch m.Type {
+	case TypeKNN:
+		return m.KNN.probabilities(x, len(m.Labels))
+	case TypeLogistic:
+		return m.Logistic.probabilities(x)
+	}
+	return nil
+}
+
+func (m *Model) standardize(x []float64) {
+	for j := range x {
+		x[j] = (x[j] - m.Mean[j]) / m.Std[j]
+	}
+}
+
+// 特徴量ごとの平均と標準偏差
+// 標準偏差が 0 の特徴量は 1 として扱う
+func meanStd(x [][]float64) ([]float64, []float64) {
+	dim := len(x[0])
+	mean := make([]float64, dim)
+	std := make([]float64, dim)
+	n := float64(len(x))
+
+	for _, xi := range x {
+		for j, v := range xi {
+			mean[j] += v / n
+		}
+	}
+	for _, xi := range x {
+		for j, v := range xi {
+			d := v - mean[j]
+			std[j] += d * d / n
+		}
+	}
+	for j := range std {
+		std[j] = math.Sqrt(std[j])
+		if std[j] < 1e-9 {
+			std[j] = 1
+		}
+	}
+	return mean, std
+}
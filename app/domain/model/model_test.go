@@ -0,0 +1,104 @@
+package model
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain/dataset"
+)
+
+// landmarks は口を open だけ縦に開いた 15 点のランドマークを返す
+func landmarks(open int) [][]int {
+	p := make([][]int, constants.LANDMARK_COUNT)
+	for i := range p {
+		p[i] = []int{100 + i*7, 100 + (i*13)%40, 1}
+	}
+	p[constants.B_MOUTH][1] += open
+	p[constants.L_EYEBROW_OUTER] = []int{60, 80, 1}
+	p[constants.R_EYEBROW_OUTER] = []int{160, 80, 1}
+	return p
+}
+
+func samples() []dataset.Sample {
+	var s []dataset.Sample
+	for i := 0; i < 6; i++ {
+		s = append(s,
+			dataset.Sample{Landmarks: landmarks(i), Baseline: landmarks(0), Label: dataset.Neutral},
+			dataset.Sample{Landmarks: landmarks(30 + i), Baseline: landmarks(0), Label: "SURPRISED"},
+		)
+	}
+	return s
+}
+
+func train(t *testing.T, typ string) *Model {
+	t.Helper()
+	m, err := Train(samples(), Options{Type: typ, K: 3, Logistic: DefaultLogisticOptions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSaveLoad(t *testing.T) {
+	for _, typ := range []string{TypeKNN, TypeLogistic} {
+		t.Run(typ, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := train(t, typ).Save(&buf); err != nil {
+				t.Fatal(err)
+			}
+			m, err := Load(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := dataset.Evaluate(m, samples())
+			if r.Accuracy() != 1 {
+				t.Errorf("Accuracy = %v, want 1", r.Accuracy())
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidModels(t *testing.T) {
+	tests := []struct {
+		name   string
+		typ    string
+		modify func(m *Model)
+	}{
+		{"short mean", TypeKNN, func(m *Model) { m.Mean = m.Mean[:10] }},
+		{"short std", TypeLogistic, func(m *Model) { m.Std = m.Std[:FeatureCount-1] }},
+		{"zero std", TypeKNN, func(m *Model) { m.Std[3] = 0 }},
+		{"no labels", TypeKNN, func(m *Model) { m.Labels = nil }},
+		{"missing knn", TypeKNN, func(m *Model) { m.KNN = nil }},
+		{"knn class out of range", TypeKNN, func(m *Model) { m.KNN.Classes[0] = len(m.Labels) }},
+		{"negative knn class", TypeKNN, func(m *Model) { m.KNN.Classes[1] = -1 }},
+		{"knn point dimension", TypeKNN, func(m *Model) { m.KNN.Points[2] = m.KNN.Points[2][1:] }},
+		{"knn classes count", TypeKNN, func(m *Model) { m.KNN.Classes = m.KNN.Classes[1:] }},
+		{"knn k", TypeKNN, func(m *Model) { m.KNN.K = 0 }},
+		{"logistic classes", TypeLogistic, func(m *Model) { m.Logistic.Weights = m.Logistic.Weights[1:] }},
+		{"logistic features", TypeLogistic, func(m *Model) { m.Logistic.Weights[0] = m.Logistic.Weights[0][:FeatureCount] }},
+		{"unknown type", TypeLogistic, func(m *Model) { m.Type = "svm" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := train(t, tt.typ)
+			tt.modify(m)
+
+			var buf bytes.Buffer
+			if err := m.Save(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(&buf); err == nil || !strings.Contains(err.Error(), "モデルの内容が不正です") {
+				t.Errorf("Load error = %v, want an invalid model error", err)
+			}
+		})
+	}
+}
+
+func TestFeatureCount(t *testing.T) {
+	if n := len(Features(landmarks(0), landmarks(5))); n != FeatureCount {
+		t.Errorf("len(Features) = %d, want %d", n, FeatureCount)
+	}
+}
@@ -0,0 +1,412 @@
+package netplay
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/engine"
+)
+
+// 観戦用の状態の配信
+//
+// ゲームを遊んでいるクライアント（Publisher）は、ゲームの状態を前回から変わった部分（Delta）だけ
+// 中継サーバーに送る。サーバーはゲームごとの最新の状態を持ち、観戦するクライアント（Spectator）には
+// 最初に全ての状態を、その後は届いた Delta をそのまま送る
+
+// 状態を送る間隔（フレーム数）
+const BroadcastInterval = 3
+
+// Snapshot は観戦に必要なゲームの状態
+type Snapshot struct {
+	Frame     int      `json:"frame"`
+	TimeLimit int      `json:"timeLimit"` // タイムリミット（フレーム数）
+	Board     []string `json:"board"`     // 行ごとのセルを "0" と "1" の文字列にしたもの
+	Current   *Piece   `json:"current,omitempty"`
+	Next      []string `json:"next"`
+	Hold      string   `json:"hold,omitempty"`
+	Score     int      `json:"score"`
+	Lines     int      `json:"lines"`
+	Emotions  []string `json:"emotions"` // 判定されている表情の名前（空なら無表情）
+	Over      bool     `json:"over"`
+}
+
+// Piece は落下中のテトリミノ
+type Piece struct {
+	Name  string   `json:"name"`
+	X     int      `json:"x"`
+	Y     int      `json:"y"`
+	Shape []string `json:"shape"`
+}
+
+// Delta は前回の Snapshot から変わった部分
+// ポインタとスライスのフィールドは nil なら変わっていない
+type Delta struct {
+	Full      bool           `json:"full,omitempty"` // true なら Rows に全ての行が入っている
+	Frame     int            `json:"frame"`
+	TimeLimit int            `json:"timeLimit,omitempty"`
+	Rows      map[int]string `json:"rows,omitempty"`
+	Current   *Piece         `json:"current,omitempty"`
+	NoCurrent bool           `json:"noCurrent,omitempty"` // true なら落下中のテトリミノがなくなった
+	Next      []string       `json:"next,omitempty"`
+	Hold      *string        `json:"hold,omitempty"`
+	Score     *int           `json:"score,omitempty"`
+	Lines     *int           `json:"lines,omitempty"`
+	Emotions  *[]string      `json:"emotions,omitempty"`
+	Over      bool           `json:"over,omitempty"`
+}
+
+// NewSnapshot はエンジンの状態と、判定されている表情から Snapshot を作る
+func NewSnapshot(e *engine.Engine, emotionIndexes []int) *Snapshot {
+	s := &Snapshot{
+		Frame:     e.Frame,
+		TimeLimit: engine.Frames(e.TimeLimit),
+		Board:     encodeRows(e.Board),
+		Score:     e.Score,
+		Lines:     e.Lines,
+		Emotions:  []string{},
+		Over:      e.Over,
+	}
+	if t := e.Current; t != nil {
+		s.Current = &Piece{Name: t.Name, X: t.X, Y: t.Y, Shape: encodeRows(t.Shape)}
+	}
+	for _, t := range e.Next {
+		if t != nil {
+			s.Next = append(s.Next, t.Name)
+		}
+	}
+	if e.Hold != nil {
+		s.Hold = e.Hold.Name
+	}
+	for _, i := range emotionIndexes {
+		s.Emotions = append(s.Emotions, domain.EmotionName(i))
+	}
+	return s
+}
+
+// Diff は s から next への Delta を返す（s が nil なら全ての状態）
+// フレームの他に変わったものがなければ changed は false になる
+func (s *Snapshot) Diff(next *Snapshot) (d Delta, changed bool) {
+	if s == nil || next.Frame < s.Frame {
+		return next.Full(), true
+	}
+
+	d.Frame = next.Frame
+	for y, row := range next.Board {
+		if y >= len(s.Board) || s.Board[y] != row {
+			if d.Rows == nil {
+				d.Rows = map[int]string{}
+			}
+			d.Rows[y] = row
+			changed = true
+		}
+	}
+	if !samePiece(s.Current, next.Current) {
+		d.Current = next.Current
+		d.NoCurrent = next.Current == nil
+		changed = true
+	}
+	if strings.Join(s.Next, ",") != strings.Join(next.Next, ",") {
+		d.Next = next.Next
+		changed = true
+	}
+	if s.Hold != next.Hold {
+		d.Hold = &next.Hold
+		changed = true
+	}
+	if s.Score != next.Score {
+		d.Score = &next.Score
+		changed = true
+	}
+	if s.Lines != next.Lines {
+		d.Lines = &next.Lines
+		changed = true
+	}
+	if strings.Join(s.Emotions, ",") != strings.Join(next.Emotions, ",") {
+		d.Emotions = &next.Emotions
+		changed = true
+	}
+	if !s.Over && next.Over {
+		d.Over = true
+		changed = true
+	}
+	return d, changed
+}
+
+// Full は全ての状態を Delta にする（観戦を始めたクライアントに送る）
+// 返した Delta は s と値を共有しないので、後で s に Apply しても変わらない
+func (s *Snapshot) Full() Delta {
+	hold, score, lines := s.Hold, s.Score, s.Lines
+	emotions := append([]string{}, s.Emotions...)
+	d := Delta{
+		Full:      true,
+		Frame:     s.Frame,
+		TimeLimit: s.TimeLimit,
+		Rows:      map[int]string{},
+		Current:   s.Current,
+		NoCurrent: s.Current == nil,
+		Next:      append([]string(nil), s.Next...),
+		Hold:      &hold,
+		Score:     &score,
+		Lines:     &lines,
+		Emotions:  &emotions,
+		Over:      s.Over,
+	}
+	for y, row := range s.Board {
+		d.Rows[y] = row
+	}
+	return d
+}
+
+// Apply は Delta を反映する
+func (s *Snapshot) Apply(d Delta) {
+	if d.Full {
+		*s = Snapshot{TimeLimit: d.TimeLimit, Board: make([]string, len(d.Rows))}
+	}
+	s.Frame = d.Frame
+	for y, row := range d.Rows {
+		if y >= 0 && y < len(s.Board) {
+			s.Board[y] = row
+		}
+	}
+	if d.Current != nil {
+		s.Current = d.Current
+	}
+	if d.NoCurrent {
+		s.Current = nil
+	}
+	if d.Next != nil {
+		s.Next = d.Next
+	}
+	if d.Hold != nil {
+		s.Hold = *d.Hold
+	}
+	if d.Score != nil {
+		s.Score = *d.Score
+	}
+	if d.Lines != nil {
+		s.Lines = *d.Lines
+	}
+	if d.Emotions != nil {
+		s.Emotions = *d.Emotions
+	}
+	if d.Over {
+		s.Over = true
+	}
+}
+
+// Cells はボードを domain.Board に戻す
+func (s *Snapshot) Cells() domain.Board {
+	return decodeRows(s.Board)
+}
+
+// Tetromino は描画のために engine.Tetromino に戻す（色は種類から決める）
+func (p *Piece) Tetromino() *engine.Tetromino {
+	t := &engine.Tetromino{Name: p.Name, X: p.X, Y: p.Y, Shape: decodeRows(p.Shape)}
+	if base := engine.TetrominoByName(p.Name); base != nil {
+		t.Color = base.Color
+	}
+	return t
+}
+
+func samePiece(a, b *Piece) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Name == b.Name && a.X == b.X && a.Y == b.Y && strings.Join(a.Shape, ",") == strings.Join(b.Shape, ",")
+}
+
+func encodeRows(rows [][]int) []string {
+	encoded := make([]string, len(rows))
+	for y, row := range rows {
+		b := make([]byte, len(row))
+		for x, cell := range row {
+			b[x] = '0'
+			if cell != 0 {
+				b[x] = '1'
+			}
+		}
+		encoded[y] = string(b)
+	}
+	return encoded
+}
+
+func decodeRows(encoded []string) [][]int {
+	rows := make([][]int, len(encoded))
+	for y, row := range encoded {
+		rows[y] = make([]int, len(row))
+		for x := range row {
+			if row[x] != '0' {
+				rows[y][x] = 1
+			}
+		}
+	}
+	return rows
+}
+
+// Publisher はゲームの状態を中継サーバーに配信する
+type Publisher struct {
+	Err error // 接続できない・切断されたなど、配信を続けられないエラー
+
+	dialed chan dialResult
+	conn   Conn
+	last   map[string]*Snapshot // ゲームごとに最後に送った状態
+}
+
+// NewPublisher はサーバーへの接続を始める
+// 接続できるまでの間に Publish した状態は送らずに捨てる
+func NewPublisher(url string) *Publisher {
+	p := &Publisher{
+		dialed: make(chan dialResult, 1),
+		last:   map[string]*Snapshot{},
+	}
+	go func() {
+		conn, err := Dial(url)
+		p.dialed <- dialResult{conn, err}
+	}()
+	return p
+}
+
+// Publish はゲームの状態を game という名前で配信する
+// 毎フレーム呼んでよく、BroadcastInterval フレームごとに変わった部分だけを送る
+func (p *Publisher) Publish(game string, e *engine.Engine, emotionIndexes []int) {
+	if !p.connect() {
+		return
+	}
+
+	last := p.last[game]
+	if last != nil && e.Frame >= last.Frame && e.Frame-last.Frame < BroadcastInterval && !e.Over {
+		return
+	}
+	next := NewSnapshot(e, emotionIndexes)
+	d, changed := last.Diff(next)
+	// 何も変わっていなくても、残り時間のために 1 秒ごとには送る
+	if !changed && next.Frame-last.Frame < engine.TPS {
+		return
+	}
+	p.last[game] = next
+
+	if err := p.conn.Send(Message{Type: TypeState, Game: game, State: &d}); err != nil {
+		p.Err = err
+		p.conn.Close()
+	}
+}
+
+// Close は接続を閉じる
+func (p *Publisher) Close() {
+	if p.conn != nil {
+		p.conn.Close()
+	}
+}
+
+func (p *Publisher) connect() bool {
+	if p.Err != nil {
+		return false
+	}
+	if p.conn != nil {
+		return true
+	}
+	select {
+	case r := <-p.dialed:
+		if r.err != nil {
+			p.Err = r.err
+			return false
+		}
+		p.conn = r.conn
+		return true
+	default:
+		return false
+	}
+}
+
+// Spectator は中継サーバーから配信されているゲームの状態を受け取る
+type Spectator struct {
+	Games map[string]*Snapshot // ゲームの名前ごとの最新の状態
+	Err   error
+
+	dialed chan dialResult
+	conn   Conn
+}
+
+// Watch はサーバーに接続して観戦を始める
+func Watch(url string) *Spectator {
+	s := &Spectator{
+		Games:  map[string]*Snapshot{},
+		dialed: make(chan dialResult, 1),
+	}
+	go func() {
+		conn, err := Dial(url)
+		s.dialed <- dialResult{conn, err}
+	}()
+	return s
+}
+
+// Connected はサーバーに接続できたかを返す
+func (s *Spectator) Connected() bool {
+	return s.conn != nil
+}
+
+// Update は届いているメッセージを全て反映する
+func (s *Spectator) Update() {
+	if s.conn == nil {
+		if s.Err != nil {
+			return
+		}
+		select {
+		case r := <-s.dialed:
+			if r.err != nil {
+				s.Err = r.err
+				return
+			}
+			s.conn = r.conn
+		default:
+			return
+		}
+	}
+
+	for {
+		select {
+		case m, ok := <-s.conn.Messages():
+			if !ok {
+				if s.Err == nil {
+					s.Err = errors.New("サーバーとの接続が切れました")
+				}
+				return
+			}
+			switch m.Type {
+			case TypeState:
+				if m.State == nil {
+					continue
+				}
+				game, found := s.Games[m.Game]
+				if !found {
+					game = &Snapshot{}
+					s.Games[m.Game] = game
+				}
+				game.Apply(*m.State)
+			case TypeRemove:
+				delete(s.Games, m.Game)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Names は配信されているゲームの名前を名前順に返す
+func (s *Spectator) Names() []string {
+	names := make([]string, 0, len(s.Games))
+	for name := range s.Games {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close は接続を閉じる
+func (s *Spectator) Close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
@@ -0,0 +1,113 @@
+package netplay
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// snapshot は 4x3 のボードの Snapshot を作る
+func snapshot(frame int, modify func(s *Snapshot)) *Snapshot {
+	s := &Snapshot{
+		Frame:     frame,
+		TimeLimit: 600,
+		Board:     []string{"0000", "0000", "1100"},
+		Current:   &Piece{Name: "T", X: 1, Y: 0, Shape: []string{"010", "111"}},
+		Next:      []string{"I", "O", "S"},
+		Hold:      "",
+		Score:     100,
+		Lines:     1,
+		Emotions:  []string{},
+	}
+	if modify != nil {
+		modify(s)
+	}
+	return s
+}
+
+// wire は Delta を JSON にして戻す（送受信と同じ変換）
+func wire(t *testing.T, d Delta) Delta {
+	t.Helper()
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Delta
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestDiffApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		prev, next  *Snapshot
+		wantChanged bool
+		wantFull    bool
+	}{
+		{"first state", nil, snapshot(3, nil), true, true},
+		{"only the frame", snapshot(3, nil), snapshot(6, nil), false, false},
+		{"piece falls", snapshot(3, nil), snapshot(6, func(s *Snapshot) { s.Current.Y = 1 }), true, false},
+		{"piece locks", snapshot(3, nil), snapshot(6, func(s *Snapshot) {
+			s.Board = []string{"0000", "0100", "1111"}
+			s.Current = nil
+		}), true, false},
+		{"new piece", snapshot(3, func(s *Snapshot) { s.Current = nil }), snapshot(6, nil), true, false},
+		{"hold, score and lines", snapshot(3, nil), snapshot(6, func(s *Snapshot) {
+			s.Hold = "Z"
+			s.Next = []string{"O", "S", "L"}
+			s.Score = 900
+			s.Lines = 4
+		}), true, false},
+		{"emotions", snapshot(3, nil), snapshot(6, func(s *Snapshot) { s.Emotions = []string{"SMILE", "SUS"} }), true, false},
+		{"emotions cleared", snapshot(3, func(s *Snapshot) { s.Emotions = []string{"SMILE"} }), snapshot(6, nil), true, false},
+		{"game over", snapshot(3, nil), snapshot(6, func(s *Snapshot) {
+			s.Board = []string{"1000", "1100", "1100"}
+			s.Current = nil
+			s.Over = true
+		}), true, false},
+		{"restart", snapshot(900, func(s *Snapshot) {
+			s.Board = []string{"1000", "1100", "1100"}
+			s.Over = true
+		}), snapshot(0, func(s *Snapshot) { s.Board = []string{"0000", "0000", "0000"} }), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 観戦しているクライアントは prev まで受け取っている
+			spectator := &Snapshot{}
+			if tt.prev != nil {
+				spectator.Apply(wire(t, tt.prev.Full()))
+				if !reflect.DeepEqual(spectator, tt.prev) {
+					t.Fatalf("Apply(Full) = %+v, want %+v", spectator, tt.prev)
+				}
+			}
+
+			d, changed := tt.prev.Diff(tt.next)
+			if changed != tt.wantChanged || d.Full != tt.wantFull {
+				t.Errorf("Diff changed = %v, full = %v; want %v, %v", changed, d.Full, tt.wantChanged, tt.wantFull)
+			}
+			spectator.Apply(wire(t, d))
+			if !reflect.DeepEqual(spectator, tt.next) {
+				t.Errorf("after Apply = %+v, want %+v", spectator, tt.next)
+			}
+		})
+	}
+}
+
+// Full の Delta は、元の Snapshot に後から Apply しても変わらない
+func TestFullDoesNotShareState(t *testing.T) {
+	s := snapshot(3, func(s *Snapshot) { s.Emotions = []string{"SMILE"} })
+	full := s.Full()
+	before, _ := json.Marshal(full)
+
+	d, _ := s.Diff(snapshot(6, func(n *Snapshot) {
+		n.Hold, n.Score, n.Lines, n.Emotions = "I", 500, 3, []string{"ANGRY"}
+	}))
+	s.Apply(d)
+	s.Emotions[0] = "SUS"
+
+	if after, _ := json.Marshal(full); string(after) != string(before) {
+		t.Errorf("Full changed after Apply:\n%s\nwant\n%s", after, before)
+	}
+}
@@ -0,0 +1,97 @@
+//go:build js && wasm
+// +build js,wasm
+
+package netplay
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"syscall/js"
+)
+
+// jsConn はブラウザの WebSocket による接続
+type jsConn struct {
+	ws       js.Value
+	messages chan Message
+	funcs    []js.Func
+}
+
+// Dial はブラウザの WebSocket でサーバーに接続する
+// 接続が開くか失敗するまで待つため、ゲームループとは別の goroutine から呼ぶ
+func Dial(rawURL string) (Conn, error) {
+	c := &jsConn{
+		ws:       js.Global().Get("WebSocket").New(rawURL),
+		messages: make(chan Message, 256),
+	}
+	opened := make(chan error, 1)
+
+	c.on("open", func(js.Value) {
+		opened <- nil
+	})
+	c.on("error", func(js.Value) {
+		select {
+		case opened <- errors.New("netplay: WebSocket の接続に失敗しました"):
+		default:
+		}
+	})
+	c.on("message", func(event js.Value) {
+		var m Message
+		if err := json.Unmarshal([]byte(event.Get("data").String()), &m); err != nil {
+			log.Printf("netplay: invalid message: %v", err)
+			return
+		}
+		c.messages <- m
+	})
+	c.on("close", func(js.Value) {
+		select {
+		case opened <- errors.New("netplay: WebSocket が閉じられました"):
+		default:
+		}
+		close(c.messages)
+		c.release()
+	})
+
+	if err := <-opened; err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// on はイベントリスナーを登録する
+func (c *jsConn) on(event string, handler func(js.Value)) {
+	f := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		handler(args[0])
+		return nil
+	})
+	c.funcs = append(c.funcs, f)
+	c.ws.Call("addEventListener", event, f)
+}
+
+func (c *jsConn) release() {
+	for _, f := range c.funcs {
+		f.Release()
+	}
+	c.funcs = nil
+}
+
+func (c *jsConn) Send(m Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if c.ws.Get("readyState").Int() != 1 { // OPEN
+		return errors.New("netplay: WebSocket is not open")
+	}
+	c.ws.Call("send", string(data))
+	return nil
+}
+
+func (c *jsConn) Messages() <-chan Message {
+	return c.messages
+}
+
+func (c *jsConn) Close() error {
+	c.ws.Call("close")
+	return nil
+}
@@ -0,0 +1,60 @@
+// Package netplay は WebSocket で 2 人の入力をやり取りする通信対戦を扱う
+//
+// 両方のクライアントが同じシードで 2 人分のエンジンを進め、毎フレームの操作と表情だけを送り合う。
+// 自分の操作は InputDelay フレーム後に適用することで通信の遅れを吸収し、
+// 一定間隔で状態のハッシュ値を送り合って同期ずれを検出する
+package netplay
+
+import "square-face-tetris/app/domain/engine"
+
+// メッセージの種類
+const (
+	TypeJoin  = "join"  // クライアント → サーバー: 部屋に入る
+	TypeStart = "start" // サーバー → クライアント: 2 人揃ったので対戦を始める
+	TypeInput = "input" // 1 フレーム分の操作と表情
+	TypeHash  = "hash"  // 状態のハッシュ値
+	TypeLeave = "leave" // サーバー → クライアント: 相手が切断した
+	TypeError = "error" // サーバー → クライアント: 部屋に入れないなど
+
+	// 観戦（broadcast.go）
+	TypeState  = "state"  // ゲームの状態の Delta
+	TypeRemove = "remove" // サーバー → 観戦クライアント: ゲームの配信が終わった
+)
+
+// Message はクライアントとサーバーの間でやり取りするメッセージ
+// 通常は JSON のテキストフレームとして 1 メッセージずつ送る
+type Message struct {
+	Type string `json:"type"`
+
+	// join
+	Room    string          `json:"room,omitempty"`
+	Options *engine.Options `json:"options,omitempty"`
+	Delay   int             `json:"delay,omitempty"` // 希望する入力遅延（フレーム数）
+
+	// start
+	Player int   `json:"player,omitempty"` // 自分のプレイヤー番号（0 か 1）
+	Seed   int64 `json:"seed,omitempty"`
+
+	// input, hash
+	Frame    int    `json:"frame,omitempty"`
+	Input    int    `json:"input,omitempty"` // input.Mask で作った操作のビットマスク
+	Emotions []int  `json:"emotions,omitempty"`
+	Hash     uint64 `json:"hash,omitempty"`
+
+	// error
+	Error string `json:"error,omitempty"`
+
+	// state, remove
+	Game  string `json:"game,omitempty"` // 配信しているゲームの名前
+	State *Delta `json:"state,omitempty"`
+}
+
+// Conn はメッセージを送受信する接続
+type Conn interface {
+	// Send はメッセージを送る
+	Send(m Message) error
+	// Messages は受信したメッセージを返すチャネル（切断すると閉じる）
+	Messages() <-chan Message
+	// Close は接続を閉じる
+	Close() error
+}
@@ -0,0 +1,131 @@
+package netplay
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Relay は通信対戦の中継サーバーの http.Handler
+// 同じ部屋（?room=名前 か join の Room）に入った 2 人を組にして対戦を始め、その後は互いのメッセージを中継する
+type Relay struct {
+	mu      sync.Mutex
+	waiting map[string]*waiting // 部屋の名前ごとに待っている接続
+}
+
+// 部屋に入った後、2 人目を待っている接続
+type waiting struct {
+	conn   Conn
+	join   Message
+	paired chan struct{} // 2 人目が入ったら閉じる
+}
+
+// NewRelay は誰も待っていない中継サーバーを作る
+func NewRelay() *Relay {
+	return &Relay{waiting: map[string]*waiting{}}
+}
+
+func (s *Relay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := Upgrade(w, r)
+	if err != nil {
+		log.Printf("接続を切り替えられません: %v", err)
+		return
+	}
+
+	// 最初のメッセージで部屋に入る
+	join, ok := <-conn.Messages()
+	if !ok {
+		conn.Close()
+		return
+	}
+	if join.Type != TypeJoin {
+		conn.Send(Message{Type: TypeError, Error: "最初に join を送ってください"})
+		conn.Close()
+		return
+	}
+	room := join.Room
+	if room == "" {
+		room = r.URL.Query().Get("room")
+	}
+
+	p := &waiting{conn: conn, join: join, paired: make(chan struct{})}
+	s.mu.Lock()
+	first, found := s.waiting[room]
+	if found {
+		delete(s.waiting, room)
+	} else {
+		s.waiting[room] = p
+	}
+	s.mu.Unlock()
+
+	if found {
+		log.Printf("room %q: 対戦を始めます", room)
+		close(first.paired)
+		s.play(first, p)
+		return
+	}
+
+	// 2 人目を待つ。揃った後は 2 人目の goroutine が中継する
+	log.Printf("room %q: 相手を待っています", room)
+	for {
+		select {
+		case <-p.paired:
+			return
+		case _, ok := <-conn.Messages():
+			if ok {
+				continue
+			}
+			// 揃う前に切断した場合は部屋を空ける
+			s.mu.Lock()
+			if s.waiting[room] == p {
+				delete(s.waiting, room)
+			}
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+	}
+}
+
+// play は 2 人に対戦の開始を知らせ、どちらかが切断するまでメッセージを中継する
+func (s *Relay) play(p0, p1 *waiting) {
+	delay := max(p0.join.Delay, p1.join.Delay)
+	if delay > MaxDelay {
+		delay = MaxDelay
+	}
+	start := Message{
+		Type:    TypeStart,
+		Seed:    time.Now().UnixNano(),
+		Options: p0.join.Options, // ルールは先に入った人の設定に合わせる
+		Delay:   delay,
+	}
+	for i, p := range []*waiting{p0, p1} {
+		start.Player = i
+		p.conn.Send(start)
+	}
+
+	// どちらかが切断したら、残った方に leave を送って中継をやめる
+	// 両方の接続を閉じるので、もう一方の中継も終わる
+	done := make(chan struct{}, 2)
+	relay := func(from, to Conn) {
+		defer func() { done <- struct{}{} }()
+		for m := range from.Messages() {
+			if m.Type != TypeInput && m.Type != TypeHash {
+				continue
+			}
+			if err := to.Send(m); err != nil {
+				from.Send(Message{Type: TypeLeave})
+				return
+			}
+		}
+		to.Send(Message{Type: TypeLeave})
+	}
+	go relay(p0.conn, p1.conn)
+	go relay(p1.conn, p0.conn)
+
+	<-done
+	p0.conn.Close()
+	p1.conn.Close()
+	<-done
+}
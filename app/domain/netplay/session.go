@@ -0,0 +1,273 @@
+package netplay
+
+import (
+	"errors"
+	"fmt"
+
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/input"
+)
+
+// 状態のハッシュ値を送り合う間隔（フレーム数）
+const HashInterval = engine.TPS
+
+// 入力遅延の上限（フレーム数）
+const MaxDelay = 30
+
+// 1 フレーム分の入力
+type frameInput struct {
+	actions  []input.Action
+	emotions []int
+}
+
+// Session は 1 回の通信対戦
+//
+// Update を毎フレーム呼ぶと、自分の入力を Delay フレーム後の分として送り、
+// 2 人分の入力が揃ったフレームまで両方のエンジンを進める（ロックステップ）
+type Session struct {
+	Player  int               // 自分のプレイヤー番号（0 か 1）
+	Delay   int               // 入力遅延（フレーム数）
+	Engines [2]*engine.Engine // 2 人分のエンジン（Started まで nil）
+	Started bool              // 相手が揃って対戦が始まったか
+
+	Err          error // 接続できない・切断されたなど、続けられないエラー
+	Desync       bool  // 状態のハッシュ値が相手と一致しなかった
+	OpponentLeft bool  // 相手が切断した
+
+	room    string
+	options engine.Options
+	dialed  chan dialResult
+	conn    Conn
+	sendErr error // 最初の送信エラー（切断に気付いたときに Err にする）
+
+	inputs     [2]map[int]frameInput // プレイヤーごとの、フレーム番号ごとの入力
+	sentFrame  int                   // 自分の入力を送った最後のフレーム
+	hashes     map[int]uint64        // 自分の状態のハッシュ値
+	peerHashes map[int]uint64        // 相手から届いたハッシュ値
+}
+
+type dialResult struct {
+	conn Conn
+	err  error
+}
+
+// Join はサーバーに接続し、部屋に入る
+// 接続は別の goroutine で行うため、結果は Update を呼んだ後の Err や Started で確かめる
+func Join(url, room string, opts engine.Options, delay int) *Session {
+	s := &Session{
+		Delay:   delay,
+		room:    room,
+		options: opts,
+		dialed:  make(chan dialResult, 1),
+	}
+	go func() {
+		conn, err := Dial(url)
+		s.dialed <- dialResult{conn, err}
+	}()
+	return s
+}
+
+// Connected はサーバーに接続できたかを返す
+func (s *Session) Connected() bool {
+	return s.conn != nil
+}
+
+// Over は対戦が終わったかを返す
+func (s *Session) Over() bool {
+	if s.Err != nil || s.Desync || s.OpponentLeft {
+		return true
+	}
+	return s.Started && (s.Engines[0].Over || s.Engines[1].Over)
+}
+
+// Update は受信したメッセージを処理し、自分の入力を送って、進められるだけエンジンを進める
+// actions と emotions はこのフレームの自分の操作と表情（一時停止の操作は無視する）
+func (s *Session) Update(actions []input.Action, emotions []int) {
+	if !s.connect() {
+		return
+	}
+	s.receive()
+	if !s.Started || s.Over() {
+		return
+	}
+
+	// 自分の入力は Delay フレーム後の分として送る
+	// 相手の入力が届かないときは、Delay フレームより先には進まない
+	if s.sentFrame < s.Engines[0].Frame+1+s.Delay {
+		s.sentFrame++
+		in := frameInput{actions: withoutPause(actions), emotions: emotions}
+		s.inputs[s.Player][s.sentFrame] = in
+		s.send(Message{
+			Type:     TypeInput,
+			Frame:    s.sentFrame,
+			Input:    input.Mask(in.actions),
+			Emotions: in.emotions,
+		})
+	}
+
+	// 2 人分の入力が揃ったフレームを進める
+	for !s.Over() {
+		frame := s.Engines[0].Frame + 1
+		in0, ok0 := s.inputs[0][frame]
+		in1, ok1 := s.inputs[1][frame]
+		if !ok0 || !ok1 {
+			break
+		}
+		delete(s.inputs[0], frame)
+		delete(s.inputs[1], frame)
+
+		s.Engines[0].Step(in0.actions, in0.emotions)
+		s.Engines[1].Step(in1.actions, in1.emotions)
+		s.Engines[0].PopEvents()
+		s.Engines[1].PopEvents()
+		engine.ExchangeGarbage(s.Engines[0], s.Engines[1])
+
+		if frame%HashInterval == 0 {
+			hash := s.hash()
+			s.hashes[frame] = hash
+			s.send(Message{Type: TypeHash, Frame: frame, Hash: hash})
+			s.compareHash(frame)
+		}
+	}
+}
+
+// Close は接続を閉じる
+func (s *Session) Close() {
+	if s.conn != nil {
+		s.conn.Close()
+		return
+	}
+	// 接続中の場合は、接続できたら閉じる
+	go func() {
+		if r := <-s.dialed; r.conn != nil {
+			r.conn.Close()
+		}
+	}()
+}
+
+// connect は接続の結果を確かめる。接続済みなら true を返す
+func (s *Session) connect() bool {
+	if s.conn != nil {
+		return true
+	}
+	if s.Err != nil {
+		return false
+	}
+	select {
+	case r := <-s.dialed:
+		if r.err != nil {
+			s.Err = fmt.Errorf("サーバーに接続できません: %w", r.err)
+			return false
+		}
+		s.conn = r.conn
+		options := s.options
+		s.send(Message{Type: TypeJoin, Room: s.room, Options: &options, Delay: s.Delay})
+		return true
+	default:
+		return false
+	}
+}
+
+// receive は届いているメッセージを全て処理する
+func (s *Session) receive() {
+	for {
+		select {
+		case m, ok := <-s.conn.Messages():
+			if !ok {
+				if s.Err == nil && !s.Over() {
+					if s.sendErr != nil {
+						s.Err = fmt.Errorf("送信に失敗しました: %w", s.sendErr)
+					} else {
+						s.Err = errors.New("サーバーとの接続が切れました")
+					}
+				}
+				return
+			}
+			s.handle(m)
+		default:
+			return
+		}
+	}
+}
+
+func (s *Session) handle(m Message) {
+	switch m.Type {
+	case TypeStart:
+		s.start(m)
+	case TypeInput:
+		if !s.Started {
+			return
+		}
+		peer := 1 - s.Player
+		s.inputs[peer][m.Frame] = frameInput{actions: input.FromMask(m.Input), emotions: m.Emotions}
+	case TypeHash:
+		if !s.Started {
+			return
+		}
+		s.peerHashes[m.Frame] = m.Hash
+		s.compareHash(m.Frame)
+	case TypeLeave:
+		s.OpponentLeft = true
+	case TypeError:
+		s.Err = errors.New(m.Error)
+	}
+}
+
+// start は同じシード・設定で 2 人分のエンジンを作る
+// 最初の Delay フレームは、どちらも操作なしとして扱う
+func (s *Session) start(m Message) {
+	opts := s.options
+	if m.Options != nil {
+		opts = *m.Options
+	}
+	s.Player = m.Player
+	s.Delay = m.Delay
+	s.Engines = [2]*engine.Engine{engine.New(m.Seed, opts), engine.New(m.Seed, opts)}
+	s.inputs = [2]map[int]frameInput{{}, {}}
+	s.hashes = map[int]uint64{}
+	s.peerHashes = map[int]uint64{}
+	for frame := 1; frame <= s.Delay; frame++ {
+		s.inputs[0][frame] = frameInput{}
+		s.inputs[1][frame] = frameInput{}
+	}
+	s.sentFrame = s.Delay
+	s.Started = true
+}
+
+// compareHash は自分と相手のハッシュ値が両方揃っていれば比べる
+func (s *Session) compareHash(frame int) {
+	mine, ok1 := s.hashes[frame]
+	theirs, ok2 := s.peerHashes[frame]
+	if !ok1 || !ok2 {
+		return
+	}
+	if mine != theirs {
+		s.Desync = true
+	}
+	delete(s.hashes, frame)
+	delete(s.peerHashes, frame)
+}
+
+// hash は 2 人分のエンジンの状態をまとめたハッシュ値を返す
+func (s *Session) hash() uint64 {
+	return s.Engines[0].Hash()*31 + s.Engines[1].Hash()
+}
+
+// send はメッセージを送る
+// 送れない場合も、相手の切断（leave）が先に届いているかもしれないので、
+// エラーは受信側で切断に気付いたときに Err にする
+func (s *Session) send(m Message) {
+	if err := s.conn.Send(m); err != nil && s.sendErr == nil {
+		s.sendErr = err
+	}
+}
+
+func withoutPause(actions []input.Action) []input.Action {
+	var filtered []input.Action
+	for _, a := range actions {
+		if a != input.Pause {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,111 @@
+package netplay
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain/bot"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/input"
+)
+
+// relayURL は中継サーバーを立て、/ws の URL を返す
+func relayURL(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(NewRelay())
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+}
+
+// play は Update を呼んだ回数 n に応じたプレイヤー i の操作と表情を返す
+// 対戦が始まっていれば、CPU の操作にホールドや回転などの余分な操作を加える
+func play(b *bot.Bot, s *Session, i, n int) ([]input.Action, []int) {
+	var actions []input.Action
+	if s.Started {
+		actions = b.Actions(s.Engines[s.Player])
+	}
+	switch (n + i*7) % 90 {
+	case 13:
+		actions = append(actions, input.Hold)
+	case 40:
+		actions = append(actions, input.RotateCCW, input.Pause)
+	case 70:
+		actions = append(actions, input.MoveLeft, input.SoftDrop)
+	}
+	emotions := [][]int{nil, {constants.SMILE}, {constants.ANGRY, constants.SUS}, {constants.SURPRISED}}
+	return actions, emotions[(n/30+i)%len(emotions)]
+}
+
+// run は全てのセッションの Update を、done が true を返すまで繰り返す
+func run(t *testing.T, sessions []*Session, done func() bool) {
+	t.Helper()
+	bots := make([]*bot.Bot, len(sessions))
+	for i := range bots {
+		// 操作が反映される（入力遅延が過ぎる）まで次の操作を決めない
+		bots[i] = bot.NewLevel(bot.MaxLevel)
+		bots[i].Interval = MaxDelay + 1
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for n := 0; !done(); n++ {
+		if time.Now().After(deadline) {
+			t.Fatal("sessions did not finish within 10s")
+		}
+		for i, s := range sessions {
+			s.Update(play(bots[i], s, i, n))
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+func TestSessionsStaySynchronized(t *testing.T) {
+	opts := engine.DefaultOptions
+	opts.TimeLimit = 5 * time.Second
+
+	url := relayURL(t)
+	s0 := Join(url, "sync", opts, 2)
+	defer s0.Close()
+	s1 := Join(url, "sync", opts, 6)
+	defer s1.Close()
+
+	run(t, []*Session{s0, s1}, func() bool { return s0.Over() && s1.Over() })
+
+	for i, s := range []*Session{s0, s1} {
+		if s.Err != nil || s.Desync || s.OpponentLeft {
+			t.Fatalf("session %d: Err %v, Desync %v, OpponentLeft %v", i, s.Err, s.Desync, s.OpponentLeft)
+		}
+		if s.Delay != 6 {
+			t.Errorf("session %d: Delay = %d, want the larger delay 6", i, s.Delay)
+		}
+	}
+	if s0.Player == s1.Player {
+		t.Fatalf("both sessions are player %d", s0.Player)
+	}
+	for p := range s0.Engines {
+		e0, e1 := s0.Engines[p], s1.Engines[p]
+		if e0.Frame < engine.Frames(opts.TimeLimit) {
+			t.Errorf("player %d: only %d frames played, want the whole %v", p, e0.Frame, opts.TimeLimit)
+		}
+		if e0.Frame != e1.Frame || e0.Score != e1.Score || e0.Hash() != e1.Hash() {
+			t.Errorf("player %d: frame %d/%d, score %d/%d, hash %x/%x; want equal",
+				p, e0.Frame, e1.Frame, e0.Score, e1.Score, e0.Hash(), e1.Hash())
+		}
+	}
+}
+
+func TestOpponentLeft(t *testing.T) {
+	url := relayURL(t)
+	s0 := Join(url, "leave", engine.DefaultOptions, 3)
+	defer s0.Close()
+	s1 := Join(url, "leave", engine.DefaultOptions, 3)
+
+	run(t, []*Session{s0, s1}, func() bool { return s0.Started && s1.Started && s0.Engines[0].Frame > 10 })
+	s1.Close()
+
+	run(t, []*Session{s0}, func() bool { return s0.Over() })
+	if !s0.OpponentLeft || s0.Err != nil {
+		t.Errorf("OpponentLeft = %v, Err = %v; want the opponent to have left without an error", s0.OpponentLeft, s0.Err)
+	}
+}
@@ -0,0 +1,326 @@
+package netplay
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket（RFC 6455）のうち、テキストフレームでのメッセージのやり取りに必要な部分だけを実装する
+
+// ハンドシェイクで Sec-WebSocket-Key に連結する固定の文字列
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Close で相手の close フレームを待つ時間
+const closeTimeout = time.Second
+
+// 受け付けるメッセージの最大サイズ
+const maxMessageSize = 1 << 20
+
+// フレームの種類
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// wsConn は WebSocket の接続
+type wsConn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	client   bool // クライアント側は送るフレームをマスクする
+	mu       sync.Mutex
+	messages chan Message
+
+	// Close で閉じる。受信したメッセージを誰も読まなくなっても readLoop が終わるようにする
+	done      chan struct{}
+	closeOnce sync.Once
+	readDone  chan struct{} // readLoop が終わったら閉じる
+}
+
+func newWSConn(conn net.Conn, br *bufio.Reader, client bool) *wsConn {
+	c := &wsConn{
+		conn:     conn,
+		br:       br,
+		client:   client,
+		messages: make(chan Message, 256),
+		done:     make(chan struct{}),
+		readDone: make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Upgrade は HTTP のリクエストを WebSocket の接続に切り替える（サーバー側）
+func Upgrade(w http.ResponseWriter, r *http.Request) (Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET で接続してください", http.StatusMethodNotAllowed)
+		return nil, errors.New("netplay: websocket handshake must be GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "WebSocket の接続ではありません", http.StatusBadRequest)
+		return nil, errors.New("netplay: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "対応していない WebSocket のバージョンです", http.StatusUpgradeRequired)
+		return nil, errors.New("netplay: unsupported Sec-WebSocket-Version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "Sec-WebSocket-Key がありません", http.StatusBadRequest)
+		return nil, errors.New("netplay: missing Sec-WebSocket-Key")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("netplay: response does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n")
+	fmt.Fprintf(rw, "Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	fmt.Fprintf(rw, "Sec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return newWSConn(conn, rw.Reader, false), nil
+}
+
+// handshake は WebSocket の接続を始める（クライアント側）
+func handshake(conn net.Conn, host, path string) (*wsConn, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req, err := http.NewRequest("GET", "http://"+host+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("netplay: handshake failed: %s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, errors.New("netplay: invalid Sec-WebSocket-Accept")
+	}
+	return newWSConn(conn, br, true), nil
+}
+
+func (c *wsConn) Send(m Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(opText, data)
+}
+
+func (c *wsConn) Messages() <-chan Message {
+	return c.messages
+}
+
+// Close は close フレームを送り、相手の close フレームが届くか closeTimeout が過ぎてから切断する
+// すぐに切断すると、相手が読んでいないデータが残っていた場合に、送ったばかりのメッセージが相手に届かないことがある
+func (c *wsConn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.writeFrame(opClose, nil)
+		go func() {
+			select {
+			case <-c.readDone:
+			case <-time.After(closeTimeout):
+			}
+			c.conn.Close()
+		}()
+	})
+	return err
+}
+
+// readLoop はフレームを読み続け、テキストのメッセージをチャネルに送る
+func (c *wsConn) readLoop() {
+	defer close(c.readDone)
+	defer close(c.messages)
+	defer c.conn.Close()
+
+	var message []byte
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("netplay: %v", err)
+			}
+			return
+		}
+
+		switch op {
+		case opPing:
+			c.writeFrame(opPong, payload)
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.writeFrame(opClose, nil)
+			return
+		}
+
+		message = append(message, payload...)
+		if len(message) > maxMessageSize {
+			log.Printf("netplay: message too large")
+			return
+		}
+		if !fin {
+			continue
+		}
+
+		var m Message
+		if err := json.Unmarshal(message, &m); err != nil {
+			log.Printf("netplay: invalid message: %v", err)
+		} else {
+			select {
+			case c.messages <- m:
+			case <-c.done:
+				return
+			}
+		}
+		message = nil
+	}
+}
+
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.br, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	op = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	// クライアントからのフレームは必ずマスクされ、サーバーからのフレームはマスクされない
+	if masked == c.client {
+		err = errors.New("frame masking does not match the sender")
+		return
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxMessageSize {
+		err = errors.New("frame too large")
+		return
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	frame := []byte{0x80 | op}
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ mask[i%4]
+		}
+		payload = masked
+	}
+
+	_, err := c.conn.Write(append(frame, payload...))
+	return err
+}
+
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerContains はカンマ区切りのヘッダーに value が含まれるかを返す（大文字小文字は区別しない）
+func headerContains(h http.Header, name, value string) bool {
+	for _, v := range h.Values(name) {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
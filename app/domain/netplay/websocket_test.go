@@ -0,0 +1,202 @@
+package netplay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer は受け取ったメッセージをそのまま送り返す WebSocket のサーバーを立てる
+func echoServer(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for m := range conn.Messages() {
+			if conn.Send(m) != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/"
+}
+
+func dial(t *testing.T, url string) *wsConn {
+	t.Helper()
+	conn, err := Dial(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn.(*wsConn)
+}
+
+// receive はメッセージを 1 つ受け取る。切断されたら ok が false になる
+func receive(t *testing.T, c Conn) (m Message, ok bool) {
+	t.Helper()
+	select {
+	case m, ok = <-c.Messages():
+		return m, ok
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message within 5s")
+		return
+	}
+}
+
+// rawFrame はフレームをそのまま組み立てる（マスクの鍵は固定）
+func rawFrame(fin bool, op byte, payload []byte, masked bool) []byte {
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if !masked {
+		return append(frame, payload...)
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// sized は JSON にするとちょうど size バイトになるメッセージを返す
+func sized(t *testing.T, size int) Message {
+	t.Helper()
+	m := Message{Type: TypeError}
+	data, _ := json.Marshal(m)
+	m.Error = strings.Repeat("x", size-len(data)-len(`,"error":""`))
+	if data, _ = json.Marshal(m); len(data) != size {
+		t.Fatalf("message size = %d, want %d", len(data), size)
+	}
+	return m
+}
+
+func TestFrameSizes(t *testing.T) {
+	c := dial(t, echoServer(t))
+	// 125 以下は 7 ビット、126 から 65535 は 16 ビット、それより大きいと 64 ビットの長さになる
+	for _, size := range []int{125, 126, 127, 65535, 65536, 70000} {
+		m := sized(t, size)
+		if err := c.Send(m); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := receive(t, c)
+		if !ok {
+			t.Fatalf("connection closed after a %d byte frame", size)
+		}
+		if got.Error != m.Error {
+			t.Errorf("echo of a %d byte frame has %d bytes of error", size, len(got.Error))
+		}
+	}
+}
+
+func TestFragmentedMessage(t *testing.T) {
+	c := dial(t, echoServer(t))
+	var frames []byte
+	frames = append(frames, rawFrame(false, opText, []byte(`{"type":"hash",`), true)...)
+	frames = append(frames, rawFrame(true, opPing, []byte("ping"), true)...) // 分割の途中の制御フレーム
+	frames = append(frames, rawFrame(true, opContinuation, []byte(`"hash":7}`), true)...)
+	if _, err := c.conn.Write(frames); err != nil {
+		t.Fatal(err)
+	}
+	m, ok := receive(t, c)
+	if !ok || m.Type != TypeHash || m.Hash != 7 {
+		t.Errorf("echo = %+v, %v; want a hash message with hash 7", m, ok)
+	}
+}
+
+func TestRejectsWrongMasking(t *testing.T) {
+	tests := []struct {
+		name   string
+		client bool // 受け取る側がクライアントか
+		masked bool
+	}{
+		{"unmasked frame from a client", false, false},
+		{"masked frame from a server", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			defer b.Close()
+			c := newWSConn(a, bufio.NewReader(a), tt.client)
+			go b.Write(rawFrame(true, opText, []byte(`{"type":"hash"}`), tt.masked))
+			if m, ok := receive(t, c); ok {
+				t.Errorf("received %+v, want the connection to be closed", m)
+			}
+		})
+	}
+}
+
+func TestUpgradeChecksVersion(t *testing.T) {
+	url := "http" + strings.TrimPrefix(echoServer(t), "ws")
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired || resp.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("response = %s, Sec-WebSocket-Version %q; want 426 and 13", resp.Status, resp.Header.Get("Sec-WebSocket-Version"))
+	}
+}
+
+// 受信したメッセージを誰も読まなくても、Close すれば読み込みの goroutine が終わる
+func TestCloseStopsBlockedReader(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	a, b := net.Pipe()
+	server := newWSConn(a, bufio.NewReader(a), false)
+	client := newWSConn(b, bufio.NewReader(b), true)
+	go func() {
+		for i := 0; i < 2*cap(server.messages); i++ {
+			if client.Send(Message{Type: TypeHash, Frame: i}) != nil {
+				return
+			}
+		}
+	}()
+	for len(server.messages) < cap(server.messages) {
+		time.Sleep(time.Millisecond)
+	}
+
+	server.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left after Close, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
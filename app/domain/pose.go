@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"math"
+	"square-face-tetris/app/constants"
+)
+
+// HeadPose は頭の向き（ラジアン）
+// 画像の座標系（x は右、y は下）で表し、カメラ映像の左右反転は考慮しない
+type HeadPose struct {
+	Yaw   float64 // 左右の向き。顔が画像の右側を向くと正
+	Pitch float64 // 上下の向き。下を向くと正
+	Roll  float64 // 傾き。画像上で時計回りに傾くと正
+}
+
+// Sub は p から基準の向き q を引いた相対的な向きを返す
+func (p HeadPose) Sub(q HeadPose) HeadPose {
+	return HeadPose{p.Yaw - q.Yaw, p.Pitch - q.Pitch, p.Roll - q.Roll}
+}
+
+// Mirror は左右を反転した映像での向きを返す
+func (p HeadPose) Mirror() HeadPose {
+	return HeadPose{-p.Yaw, p.Pitch, -p.Roll}
+}
+
+// EstimatePose はランドマークと瞳から頭の向きを推定する
+// 瞳が検出できていない場合は目頭と目尻の中点を目の位置として使う
+// ランドマークが 1 点でも欠けている場合は false を返す
+//
+// 3 次元モデルを使わない近似で、絶対値には意味がないため
+// PoseEstimator で較正した無表情・正面の向きとの差として使う
+func EstimatePose(landmarks Landmarks) (HeadPose, bool) {
+	if !landmarks.Complete() {
+		return HeadPose{}, false
+	}
+	p := &landmarks.Points
+
+	leftEye := eyePosition(p[constants.L_EYE_INNER], p[constants.L_EYE_OUTER], landmarks.Pupils[0])
+	rightEye := eyePosition(p[constants.R_EYE_INNER], p[constants.R_EYE_OUTER], landmarks.Pupils[1])
+
+	// 両目を結んだ線の傾きが roll
+	dx, dy := leftEye[0]-rightEye[0], leftEye[1]-rightEye[1]
+	eyeDist := math.Hypot(dx, dy)
+	if eyeDist == 0 {
+		return HeadPose{}, false
+	}
+	roll := math.Atan2(dy, dx)
+
+	// roll を打ち消した座標系で、両目の中点からの鼻と口の位置を求める
+	eyeCenter := [2]float64{(leftEye[0] + rightEye[0]) / 2, (leftEye[1] + rightEye[1]) / 2}
+	nose := unrotate(toPoint(p[constants.NOSE]), eyeCenter, roll)
+	mouth := unrotate(
+		[2]float64{
+			float64(p[constants.T_MOUTH].X+p[constants.B_MOUTH].X) / 2,
+			float64(p[constants.T_MOUTH].Y+p[constants.B_MOUTH].Y) / 2,
+		},
+		eyeCenter, roll,
+	)
+	if mouth[1] <= 0 {
+		return HeadPose{}, false
+	}
+
+	// 鼻が両目の中点から左右にずれるほど横を向いている
+	yaw := math.Atan(2 * nose[0] / eyeDist)
+	// 目から口までのうち、鼻がどの位置にあるかで上下の向きを求める
+	pitch := math.Atan(2 * (nose[1]/mouth[1] - 0.5))
+
+	return HeadPose{Yaw: yaw, Pitch: pitch, Roll: roll}, true
+}
+
+// PoseEstimator は較正した向きを基準に頭の向きを推定し、
+// 指数移動平均で平滑化する
+type PoseEstimator struct {
+	// 平滑化の係数（0 から 1）。大きいほど新しい値を重視する
+	Smoothing float64
+
+	neutral    HeadPose
+	pose       HeadPose
+	calibrated bool
+	hasPose    bool
+}
+
+func NewPoseEstimator(smoothing float64) *PoseEstimator {
+	return &PoseEstimator{Smoothing: smoothing}
+}
+
+// Calibrate は正面を向いたときのランドマークを基準にする
+func (e *PoseEstimator) Calibrate(landmarks Landmarks) bool {
+	neutral, ok := EstimatePose(landmarks)
+	if !ok {
+		return false
+	}
+	e.neutral = neutral
+	e.pose = HeadPose{}
+	e.calibrated = true
+	e.hasPose = false
+	return true
+}
+
+// IsCalibrated は基準の向きが設定済みかを返す
+func (e *PoseEstimator) IsCalibrated() bool {
+	return e.calibrated
+}
+
+// Update は新しいフレームのランドマークから向きを更新する
+// 推定できなかった場合は直前の向きを保つ
+func (e *PoseEstimator) Update(landmarks Landmarks) HeadPose {
+	if !e.calibrated {
+		return HeadPose{}
+	}
+	raw, ok := EstimatePose(landmarks)
+	if !ok {
+		return e.pose
+	}
+	current := raw.Sub(e.neutral)
+
+	if !e.hasPose {
+		e.pose = current
+		e.hasPose = true
+		return e.pose
+	}
+
+	a := e.Smoothing
+	e.pose.Yaw += a * (current.Yaw - e.pose.Yaw)
+	e.pose.Pitch += a * (current.Pitch - e.pose.Pitch)
+	e.pose.Roll += a * (current.Roll - e.pose.Roll)
+	return e.pose
+}
+
+// Reset は向きを正面に戻す（較正した基準の向きは保つ）
+func (e *PoseEstimator) Reset() {
+	e.pose = HeadPose{}
+	e.hasPose = false
+}
+
+// Pose は平滑化した基準からの向きを返す
+func (e *PoseEstimator) Pose() HeadPose {
+	return e.pose
+}
+
+// 瞳が検出できていれば瞳の位置、できていなければ目頭と目尻の中点
+func eyePosition(inner, outer, pupil Point) [2]float64 {
+	if pupil.Valid {
+		return toPoint(pupil)
+	}
+	return [2]float64{float64(inner.X+outer.X) / 2, float64(inner.Y+outer.Y) / 2}
+}
+
+func toPoint(p Point) [2]float64 {
+	return [2]float64{float64(p.X), float64(p.Y)}
+}
+
+// unrotate は origin を中心に p を -angle 回転し、origin からの相対座標を返す
+func unrotate(p, origin [2]float64, angle float64) [2]float64 {
+	x, y := p[0]-origin[0], p[1]-origin[1]
+	sin, cos := math.Sincos(-angle)
+	return [2]float64{x*cos - y*sin, x*sin + y*cos}
+}
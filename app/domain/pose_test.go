@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"math"
+	"testing"
+
+	"square-face-tetris/app/constants"
+)
+
+// rotated は点を (120, 120) を中心に画像上で時計回りに angle 回転する
+func rotated(angle float64) func(points, pupils [][]int) ([][]int, [][]int) {
+	rotate := func(p []int) []int {
+		if len(p) < 2 {
+			return p
+		}
+		x, y := float64(p[0]-120), float64(p[1]-120)
+		sin, cos := math.Sincos(angle)
+		return []int{120 + int(math.Round(x*cos-y*sin)), 120 + int(math.Round(x*sin+y*cos)), p[2]}
+	}
+	return func(points, pupils [][]int) ([][]int, [][]int) {
+		for i := range points {
+			points[i] = rotate(points[i])
+		}
+		for i := range pupils {
+			pupils[i] = rotate(pupils[i])
+		}
+		return points, pupils
+	}
+}
+
+func TestEstimatePose(t *testing.T) {
+	const tolerance = 0.03
+	tilt := 20 * math.Pi / 180
+
+	tests := []struct {
+		name      string
+		landmarks Landmarks
+		wantOK    bool
+		wantRoll  float64
+		wantYaw   int // 0: 正面、1: 画像の右向き、-1: 画像の左向き
+	}{
+		{"frontal", landmarksWith(nil), true, 0, 0},
+		{"nil pupil", landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			return points, [][]int{nil, pupils[1]}
+		}), true, 0, 0},
+		{"no pupils", landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			return points, nil
+		}), true, 0, 0},
+		{"tilted", landmarksWith(rotated(tilt)), true, tilt, 0},
+		{"tilted without pupils", landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points, _ = rotated(-tilt)(points, pupils)
+			return points, nil
+		}), true, -tilt, 0},
+		{"turned right", landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points[constants.NOSE] = []int{140, 140, 4}
+			return points, pupils
+		}), true, 0, 1},
+		{"turned left", landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points[constants.NOSE] = []int{100, 140, 4}
+			return points, pupils
+		}), true, 0, -1},
+		{"missing nose", landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points[constants.NOSE] = nil
+			return points, pupils
+		}), false, 0, 0},
+		{"invalid eye point", landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points[constants.L_EYE_OUTER] = []int{170}
+			return points, pupils
+		}), false, 0, 0},
+		{"all zero", landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			for i := range points {
+				points[i] = []int{0, 0, 0}
+			}
+			return points, nil
+		}), false, 0, 0},
+		{"mouth above the eyes", landmarksWith(func(points, pupils [][]int) ([][]int, [][]int) {
+			points[constants.T_MOUTH] = []int{120, 60, 4}
+			points[constants.B_MOUTH] = []int{120, 70, 4}
+			return points, pupils
+		}), false, 0, 0},
+		{"empty", Landmarks{}, false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pose, ok := EstimatePose(tt.landmarks)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				if pose != (HeadPose{}) {
+					t.Errorf("pose = %+v, want zero", pose)
+				}
+				return
+			}
+			if math.Abs(pose.Roll-tt.wantRoll) > tolerance {
+				t.Errorf("Roll = %v, want %v", pose.Roll, tt.wantRoll)
+			}
+			switch {
+			case tt.wantYaw == 0 && math.Abs(pose.Yaw) > tolerance,
+				tt.wantYaw > 0 && pose.Yaw <= tolerance,
+				tt.wantYaw < 0 && pose.Yaw >= -tolerance:
+				t.Errorf("Yaw = %v, want sign %d", pose.Yaw, tt.wantYaw)
+			}
+		})
+	}
+}
+
+// 推定できないフレームでは直前の向きを保つ
+func TestPoseEstimatorKeepsPose(t *testing.T) {
+	e := NewPoseEstimator(1)
+	if e.Calibrate(NewLandmarks(nil, nil)) {
+		t.Fatal("calibrated with empty landmarks")
+	}
+	if !e.Calibrate(landmarksWith(nil)) {
+		t.Fatal("failed to calibrate")
+	}
+
+	tilted := e.Update(landmarksWith(rotated(0.3)))
+	if math.Abs(tilted.Roll-0.3) > 0.03 {
+		t.Fatalf("Roll = %v, want 0.3", tilted.Roll)
+	}
+	if got := e.Update(Landmarks{}); got != tilted {
+		t.Errorf("pose after a missing frame = %+v, want %+v", got, tilted)
+	}
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// Presence は顔がカメラに映っているかを追跡する
+// 一瞬見失っただけでは見失ったとせず、Timeout を超えて見つからない場合に見失ったとする
+type Presence struct {
+	Timeout time.Duration
+
+	lastSeen time.Time
+	seen     bool // 一度でも顔を見つけたか
+}
+
+func NewPresence(timeout time.Duration) *Presence {
+	return &Presence{Timeout: timeout}
+}
+
+// Update は表情分析の結果を反映する。now は分析したフレームの時刻
+func (p *Presence) Update(detected bool, now time.Time) {
+	if detected {
+		p.lastSeen = now
+		p.seen = true
+	}
+}
+
+// Lost は一度顔を見つけた後、Timeout を超えて見つかっていないかを返す
+// 一度も顔を見つけていない場合（カメラが使えない場合など）は false を返す
+func (p *Presence) Lost(now time.Time) bool {
+	return p.seen && now.Sub(p.lastSeen) > p.Timeout
+}
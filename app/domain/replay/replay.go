@@ -0,0 +1,205 @@
+// Package replay はゲームの入力を記録し、エンジンに同じ入力を与えて再現する
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/input"
+	"square-face-tetris/app/domain/storage"
+)
+
+// 記録形式のバージョン
+const Version = 1
+
+// Replay は 1 ゲーム分の記録
+//
+// 操作はフレームごとのビットマスク（input.Action 番目のビットが立っている）を
+// [フレーム数, マスク, フレーム数, マスク, ...] の形でランレングス圧縮して保持する。
+// 表情は変化したフレームだけを保持する
+type Replay struct {
+	Version  int            `json:"version"`
+	Mode     string         `json:"mode"`
+	Seed     int64          `json:"seed"`
+	Options  engine.Options `json:"options"`
+	Date     time.Time      `json:"date"`
+	Frames   int            `json:"frames"` // 記録したフレーム数
+	Score    int            `json:"score"`  // 記録したときの最終スコア
+	Inputs   []int          `json:"inputs"`
+	Emotions []EmotionEvent `json:"emotions"`
+}
+
+// EmotionEvent は Frame 番目のフレームから判定されている表情
+type EmotionEvent struct {
+	Frame    int   `json:"frame"`
+	Emotions []int `json:"emotions"`
+}
+
+// 最後に遊んだゲームの記録を保存するキー
+const latestStorageKey = "replay"
+
+// LoadLatest は保存されている最後のゲームの記録を読み込む
+func LoadLatest() (*Replay, error) {
+	data, err := storage.Load(latestStorageKey)
+	if err != nil {
+		return nil, err
+	}
+	return Load(bytes.NewReader(data))
+}
+
+// SaveLatest は最後のゲームの記録として保存する
+func (r *Replay) SaveLatest() error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return storage.Save(latestStorageKey, data)
+}
+
+// Load は JSON 形式の記録を読み込む
+func Load(r io.Reader) (*Replay, error) {
+	var rep Replay
+	if err := json.NewDecoder(r).Decode(&rep); err != nil {
+		return nil, err
+	}
+	if rep.Version != Version {
+		return nil, fmt.Errorf("対応していないリプレイのバージョンです: %d", rep.Version)
+	}
+	if len(rep.Inputs)%2 != 0 {
+		return nil, errors.New("リプレイの操作の記録が壊れています")
+	}
+	return &rep, nil
+}
+
+// Save は記録を JSON 形式で書き出す
+func (r *Replay) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// Recorder はエンジンに与えた入力を記録する
+type Recorder struct {
+	replay       *Replay
+	lastMask     int
+	lastEmotions []int
+}
+
+// NewRecorder はエンジンの開始時点の設定で記録を始める
+func NewRecorder(mode string, e *engine.Engine) *Recorder {
+	return &Recorder{
+		replay: &Replay{
+			Version: Version,
+			Mode:    mode,
+			Seed:    e.Seed,
+			Options: e.Options,
+			Date:    time.Now(),
+		},
+		lastMask: -1,
+	}
+}
+
+// Record は 1 フレーム分の入力を記録する（engine.Engine.Step と同じ引数）
+func (rec *Recorder) Record(actions []input.Action, emotionIndexes []int) {
+	r := rec.replay
+
+	mask := input.Mask(actions)
+	if mask == rec.lastMask {
+		r.Inputs[len(r.Inputs)-2]++
+	} else {
+		r.Inputs = append(r.Inputs, 1, mask)
+		rec.lastMask = mask
+	}
+
+	if r.Frames == 0 || !sameInts(emotionIndexes, rec.lastEmotions) {
+		emotions := append([]int{}, emotionIndexes...)
+		r.Emotions = append(r.Emotions, EmotionEvent{Frame: r.Frames, Emotions: emotions})
+		rec.lastEmotions = emotions
+	}
+
+	r.Frames++
+}
+
+// Finish は最終スコアを書き込んで記録を返す
+func (rec *Recorder) Finish(score int) *Replay {
+	rec.replay.Score = score
+	return rec.replay
+}
+
+// Player は記録した入力をフレームごとに取り出す
+type Player struct {
+	replay   *Replay
+	frame    int
+	run      int // 現在の Inputs の位置
+	runFrame int // 現在の run の中で何フレーム目か
+	emotion  int // 現在の Emotions の位置
+}
+
+func NewPlayer(r *Replay) *Player {
+	return &Player{replay: r, emotion: -1}
+}
+
+// NewEngine は記録と同じシード・設定のエンジンを作る
+func (r *Replay) NewEngine() *engine.Engine {
+	return engine.New(r.Seed, r.Options)
+}
+
+// Next は次のフレームの入力を返す
+// 記録の最後まで進んだ場合は ok が false になる
+func (p *Player) Next() (actions []input.Action, emotionIndexes []int, ok bool) {
+	r := p.replay
+	if p.frame >= r.Frames || p.run >= len(r.Inputs) {
+		return nil, nil, false
+	}
+
+	actions = input.FromMask(r.Inputs[p.run+1])
+	p.runFrame++
+	if p.runFrame >= r.Inputs[p.run] {
+		p.run += 2
+		p.runFrame = 0
+	}
+
+	for p.emotion+1 < len(r.Emotions) && r.Emotions[p.emotion+1].Frame <= p.frame {
+		p.emotion++
+	}
+	if p.emotion >= 0 {
+		emotionIndexes = r.Emotions[p.emotion].Emotions
+	}
+
+	p.frame++
+	return actions, emotionIndexes, true
+}
+
+// Frame は次に取り出すフレームの番号を返す
+func (p *Player) Frame() int {
+	return p.frame
+}
+
+// Run は記録をエンジンで最初から最後まで再生し、終了時点のエンジンを返す
+// 記録したときと同じスコアになっていれば再現できている
+func Run(r *Replay) *engine.Engine {
+	e := r.NewEngine()
+	p := NewPlayer(r)
+	for {
+		actions, emotions, ok := p.Next()
+		if !ok {
+			return e
+		}
+		e.Step(actions, emotions)
+	}
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
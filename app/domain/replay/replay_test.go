@@ -0,0 +1,71 @@
+package replay
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain/bot"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/input"
+)
+
+// script はフレームごとの操作と表情を決める
+// CPU の操作に、ホールドや回転などの余分な操作と、時間とともに変わる表情を加える
+func script(b *bot.Bot, e *engine.Engine) ([]input.Action, []int) {
+	actions := b.Actions(e)
+	switch e.Frame % 300 {
+	case 13:
+		actions = append(actions, input.Hold)
+	case 150:
+		actions = append(actions, input.RotateCCW, input.SoftDrop)
+	}
+
+	var emotions []int
+	switch e.Frame / 40 % 5 {
+	case 1:
+		emotions = []int{constants.SMILE}
+	case 2:
+		emotions = []int{constants.ANGRY, constants.SUS}
+	case 3:
+		emotions = []int{constants.SURPRISED}
+	}
+	return actions, emotions
+}
+
+func TestReplayIsDeterministic(t *testing.T) {
+	opts := engine.DefaultOptions
+	opts.TimeLimit = time.Minute
+
+	e := engine.New(20240601, opts)
+	b := bot.NewLevel(bot.MaxLevel)
+	rec := NewRecorder("normal", e)
+	for !e.Over {
+		actions, emotions := script(b, e)
+		rec.Record(actions, emotions)
+		e.Step(actions, emotions)
+	}
+	if e.Lines == 0 {
+		t.Fatal("the scripted game cleared no lines; the test would not cover line clears")
+	}
+
+	// JSON を経由しても同じになることも確かめる
+	var buf bytes.Buffer
+	if err := rec.Finish(e.Score).Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := Run(r)
+	if got.Score != e.Score || got.Lines != e.Lines || got.Frame != e.Frame || got.Hash() != e.Hash() {
+		t.Errorf("replay = score %d, lines %d, frame %d, hash %x; want score %d, lines %d, frame %d, hash %x",
+			got.Score, got.Lines, got.Frame, got.Hash(), e.Score, e.Lines, e.Frame, e.Hash())
+	}
+	if got.Score != r.Score {
+		t.Errorf("replay score = %d, recorded %d", got.Score, r.Score)
+	}
+}
@@ -0,0 +1,145 @@
+// Package report はゲーム終了時の統計をまとめ、JSON や CSV で書き出す
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/engine"
+)
+
+// Report は 1 ゲーム分の統計
+type Report struct {
+	Mode     string    `json:"mode"`
+	Date     time.Time `json:"date"`
+	Score    int       `json:"score"`
+	Lines    int       `json:"lines"`
+	Level    int       `json:"level"`
+	Duration float64   `json:"duration"` // プレイ時間（秒）
+
+	Pieces        int            `json:"pieces"`
+	PPS           float64        `json:"pps"` // 1 秒あたりに固定したテトリミノの数
+	LineClears    map[string]int `json:"lineClears"`
+	TSpins        int            `json:"tSpins"`
+	MaxCombo      int            `json:"maxCombo"`
+	FinesseFaults int            `json:"finesseFaults"`
+
+	// 表情ごとの判定されていた時間（秒）と、どの表情も判定されていなかった時間
+	EmotionTime map[string]float64 `json:"emotionTime"`
+	NeutralTime float64            `json:"neutralTime"`
+	// 表情ごとの、次のテトリミノを選んだ回数
+	EmotionPicks map[string]int `json:"emotionPicks"`
+	// 顔を検出できていた時間の割合（0 から 1）
+	FaceUptime float64 `json:"faceUptime"`
+}
+
+// New は終了したゲームから統計をまとめる
+// faceFrames は顔を検出できていたフレーム数
+func New(mode string, e *engine.Engine, faceFrames int, date time.Time) *Report {
+	s := e.Stats
+	seconds := e.Elapsed().Seconds()
+
+	r := &Report{
+		Mode:          mode,
+		Date:          date,
+		Score:         e.Score,
+		Lines:         e.Lines,
+		Level:         e.Level(),
+		Duration:      seconds,
+		Pieces:        s.Pieces,
+		LineClears:    map[string]int{},
+		TSpins:        s.TSpins,
+		MaxCombo:      s.MaxCombo,
+		FinesseFaults: s.FinesseFaults,
+		EmotionTime:   map[string]float64{},
+		NeutralTime:   engine.Duration(s.NeutralFrames).Seconds(),
+		EmotionPicks:  map[string]int{},
+	}
+	if seconds > 0 {
+		r.PPS = float64(s.Pieces) / seconds
+	}
+	if e.Frame > 0 {
+		r.FaceUptime = float64(faceFrames) / float64(e.Frame)
+	}
+	for n := 1; n < len(s.LineClears); n++ {
+		r.LineClears[engine.LineClearNames[n]] = s.LineClears[n]
+	}
+	for i, frames := range s.EmotionFrames {
+		r.EmotionTime[domain.EmotionName(i)] = engine.Duration(frames).Seconds()
+	}
+	for i, picks := range s.EmotionPicks {
+		r.EmotionPicks[domain.EmotionName(i)] = picks
+	}
+	return r
+}
+
+// WriteJSON は統計を JSON で書き出す
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV は統計を「項目, 値」の 2 列の CSV で書き出す
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	for _, row := range r.Rows() {
+		if err := cw.Write(row[:]); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Rows は統計を「項目, 値」の組で返す（表情ごとの項目は EMOTION_COUNT 順）
+func (r *Report) Rows() [][2]string {
+	rows := [][2]string{
+		{"metric", "value"},
+		{"mode", r.Mode},
+		{"date", r.Date.Format(time.RFC3339)},
+		{"score", strconv.Itoa(r.Score)},
+		{"lines", strconv.Itoa(r.Lines)},
+		{"level", strconv.Itoa(r.Level)},
+		{"duration", formatFloat(r.Duration)},
+		{"pieces", strconv.Itoa(r.Pieces)},
+		{"pps", formatFloat(r.PPS)},
+	}
+	for n := 1; n < len(engine.LineClearNames); n++ {
+		name := engine.LineClearNames[n]
+		rows = append(rows, [2]string{"lineClears." + name, strconv.Itoa(r.LineClears[name])})
+	}
+	rows = append(rows,
+		[2]string{"tSpins", strconv.Itoa(r.TSpins)},
+		[2]string{"maxCombo", strconv.Itoa(r.MaxCombo)},
+		[2]string{"finesseFaults", strconv.Itoa(r.FinesseFaults)},
+	)
+	for _, name := range EmotionNames() {
+		rows = append(rows, [2]string{"emotionTime." + name, formatFloat(r.EmotionTime[name])})
+	}
+	rows = append(rows, [2]string{"neutralTime", formatFloat(r.NeutralTime)})
+	for _, name := range EmotionNames() {
+		rows = append(rows, [2]string{"emotionPicks." + name, strconv.Itoa(r.EmotionPicks[name])})
+	}
+	rows = append(rows, [2]string{"faceUptime", formatFloat(r.FaceUptime)})
+	return rows
+}
+
+// EmotionNames は表情の名前を constants.SMILE などの順に返す
+func EmotionNames() []string {
+	names := make([]string, constants.EMOTION_COUNT)
+	for i := range names {
+		names[i] = domain.EmotionName(i)
+	}
+	return names
+}
+
+func formatFloat(v float64) string {
+	return fmt.Sprintf("%.3f", v)
+}
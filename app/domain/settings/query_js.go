@@ -0,0 +1,20 @@
+//go:build js && wasm
+// +build js,wasm
+
+package settings
+
+import (
+	"net/url"
+	"strings"
+	"syscall/js"
+)
+
+// query はページの URL のクエリを返す
+func query() url.Values {
+	search := js.Global().Get("location").Get("search").String()
+	values, err := url.ParseQuery(strings.TrimPrefix(search, "?"))
+	if err != nil {
+		return nil
+	}
+	return values
+}
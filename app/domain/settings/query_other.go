@@ -0,0 +1,11 @@
+//go:build !js || !wasm
+// +build !js !wasm
+
+package settings
+
+import "net/url"
+
+// query はクエリを持たない環境では空を返す
+func query() url.Values {
+	return nil
+}
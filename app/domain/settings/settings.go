@@ -0,0 +1,389 @@
+// Package settings はゲームの設定を読み込み・保存する
+//
+// 設定は初期値、保存された設定（wasm では localStorage、それ以外では設定ディレクトリの
+// settings.json）、wasm では URL のクエリ（?dropInterval=1s&camera=false など）の順に上書きされる
+package settings
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain/bot"
+	"square-face-tetris/app/domain/detection"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/netplay"
+	"square-face-tetris/app/domain/storage"
+)
+
+// 保存先のキー
+const storageKey = "settings"
+
+// Settings はゲームの設定
+type Settings struct {
+	// カメラ機能を有効にするかどうか（再起動後に反映）
+	Camera bool `json:"camera"`
+	// カメラのプレビューを表示するかどうか（ゲームプレイには影響しない）
+	CameraPreview bool `json:"cameraPreview"`
+	// カメラプレビューの FPS
+	CameraPreviewFPS int `json:"cameraPreviewFps"`
+	// 使うカメラの deviceId（空なら既定のカメラ）
+	CameraDevice string `json:"cameraDevice"`
+	// カメラに要求する解像度（"640x480" など、CameraResolutions のどれか）と FPS
+	CameraResolution string `json:"cameraResolution"`
+	CameraFPS        int    `json:"cameraFps"`
+	// プレビューを鏡のように左右反転するかどうか（頭の向きの操作もプレビューの見た目に合わせる）
+	MirrorCamera bool `json:"mirrorCamera"`
+	// 表情分析を行う頻度
+	EmotionAnalysisFPS int `json:"emotionAnalysisFps"`
+
+	// 表情の分類器（"rule": ルールベース, "model": 学習済みモデル）
+	Classifier string `json:"classifier"`
+	// 学習済みモデルのパス（cmd/train で作成する）
+	ModelPath string `json:"modelPath"`
+	// 顔検出を Web Worker で行うかどうか（再起動後に反映、使えない場合はゲームと同じスレッドで行う）
+	DetectionWorker bool `json:"detectionWorker"`
+	// 顔検出のパラメータ
+	Detector detection.Options `json:"detector"`
+	// 頭の向きの平滑化係数（0 から 1、大きいほど反応が速くブレやすい）
+	HeadPoseSmoothing float64 `json:"headPoseSmoothing"`
+	// プレイ中に顔を見失ったときの動作（"pause": 一時停止, "keyboard": キーボードだけで続ける, "none": 何もしない）
+	FaceLostAction string `json:"faceLostAction"`
+	// 顔が見つからない状態がこれより続いたら見失ったとする
+	FaceLostTimeout Duration `json:"faceLostTimeout"`
+
+	// ボードの大きさ
+	BoardWidth  int `json:"boardWidth"`
+	BoardHeight int `json:"boardHeight"`
+	// ブロックの落下間隔
+	DropInterval Duration `json:"dropInterval"`
+	// タイムリミット
+	TimeLimit Duration `json:"timeLimit"`
+
+	// 通信対戦の中継サーバー（cmd/server）の URL
+	ServerURL string `json:"serverUrl"`
+	// 通信対戦の部屋の名前（同じ名前を指定した 2 人が対戦する）
+	Room string `json:"room"`
+	// 通信対戦の入力遅延（フレーム数、大きいほど通信の遅れに強く操作が遅れる）
+	InputDelay int `json:"inputDelay"`
+
+	// CPU 対戦の CPU の強さ（1 から 10）
+	CPULevel int `json:"cpuLevel"`
+
+	// 観戦用にゲームの状態を中継サーバーへ配信するかどうか
+	Broadcast bool `json:"broadcast"`
+	// 配信するときのプレイヤーの名前（観戦画面に表示する）
+	PlayerName string `json:"playerName"`
+}
+
+// 選択できるカメラの解像度
+var CameraResolutions = []string{"320x240", "640x480", "1280x720", "1920x1080"}
+
+// 選択できる分類器
+var Classifiers = []string{"rule", "model"}
+
+// 選択できる顔を見失ったときの動作
+var FaceLostActions = []string{"pause", "keyboard", "none"}
+
+// 設定できる値の範囲
+const (
+	MinFPS = 1
+	MaxFPS = 60
+
+	// ボードは画面（constants.ScreenWidth x constants.ScreenHeight）に収まる大きさにする
+	MinBoardWidth  = 6
+	MaxBoardWidth  = 12
+	MinBoardHeight = 10
+	MaxBoardHeight = constants.ScreenHeight / constants.BlockSize
+
+	MinFaceLostTimeout = Duration(500 * time.Millisecond)
+	MaxFaceLostTimeout = Duration(30 * time.Second)
+
+	MinDropInterval = Duration(100 * time.Millisecond)
+	MaxDropInterval = Duration(10 * time.Second)
+	MinTimeLimit    = Duration(30 * time.Second)
+	MaxTimeLimit    = Duration(30 * time.Minute)
+
+	MinInputDelay = 0
+	MaxInputDelay = netplay.MaxDelay
+)
+
+// Default は設定の初期値を返す
+func Default() Settings {
+	return Settings{
+		Camera:             true,
+		CameraPreview:      true,
+		CameraPreviewFPS:   5,
+		CameraResolution:   "640x480",
+		CameraFPS:          30,
+		MirrorCamera:       true,
+		EmotionAnalysisFPS: 20,
+		Classifier:         "rule",
+		ModelPath:          "/model/emotion.json",
+		DetectionWorker:    true,
+		Detector:           detection.DefaultOptions(),
+		HeadPoseSmoothing:  0.4,
+		FaceLostAction:     "pause",
+		FaceLostTimeout:    Duration(2 * time.Second),
+		BoardWidth:         constants.BoardWidth,
+		BoardHeight:        constants.BoardHeight,
+		DropInterval:       Duration(2 * time.Second),
+		TimeLimit:          Duration(3 * time.Minute),
+		ServerURL:          "ws://localhost:8080/ws",
+		Room:               "default",
+		InputDelay:         3,
+		CPULevel:           5,
+		PlayerName:         "player",
+	}
+}
+
+// Load は保存されている設定を読み込み、クエリで上書きする
+// 不正な値は初期値に戻し、その内容をエラーとして返す（返す設定は常に使える）
+func Load() (Settings, error) {
+	s := Default()
+
+	var errs []error
+	data, err := storage.Load(storageKey)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+	case err != nil:
+		errs = append(errs, err)
+	default:
+		if err := json.Unmarshal(data, &s); err != nil {
+			errs = append(errs, fmt.Errorf("保存された設定を読み込めません: %w", err))
+			s = Default()
+		}
+	}
+
+	if err := s.ApplyQuery(query()); err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return s, errors.Join(errs...)
+}
+
+// Save は設定を保存する
+func (s Settings) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.Save(storageKey, data)
+}
+
+// ApplyQuery は URL のクエリの値で設定を上書きする
+// キーは JSON のフィールド名と同じ。読み込めない値はその項目だけ無視し、内容をエラーとして返す
+func (s *Settings) ApplyQuery(values url.Values) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	// 同じ結果になるよう、キーの順に反映する
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	types := fieldTypes()
+	var errs []error
+	for _, key := range keys {
+		t, ok := types[key]
+		if !ok {
+			continue
+		}
+		v := values[key][len(values[key])-1]
+
+		// 文字列や Duration のフィールドはそのまま文字列として、それ以外は JSON の値として読み込む
+		var raw []byte
+		if t.Kind() == reflect.String || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+			raw, _ = json.Marshal(v)
+		} else {
+			raw = []byte(v)
+		}
+		data, err := json.Marshal(map[string]json.RawMessage{key: raw})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("クエリの %s を読み込めません: %w", key, err))
+			continue
+		}
+
+		// 不正な値で他の設定が壊れないよう、コピーに読み込んでから反映する
+		updated := *s
+		if err := json.Unmarshal(data, &updated); err != nil {
+			errs = append(errs, fmt.Errorf("クエリの %s を読み込めません: %w", key, err))
+			continue
+		}
+		*s = updated
+	}
+	return errors.Join(errs...)
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// fieldTypes は Settings の JSON のフィールド名と型の対応を返す
+func fieldTypes() map[string]reflect.Type {
+	t := reflect.TypeOf(Settings{})
+	types := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			types[name] = f.Type
+		}
+	}
+	return types
+}
+
+// Validate は範囲外の値を初期値に戻す
+// 戻した値があればその内容をエラーとして返す
+func (s *Settings) Validate() error {
+	d := Default()
+	var errs []error
+	invalid := func(name string, value interface{}) {
+		errs = append(errs, fmt.Errorf("%s の値が不正なため初期値に戻しました: %v", name, value))
+	}
+
+	if s.CameraPreviewFPS < MinFPS || s.CameraPreviewFPS > MaxFPS {
+		invalid("cameraPreviewFps", s.CameraPreviewFPS)
+		s.CameraPreviewFPS = d.CameraPreviewFPS
+	}
+	if !contains(CameraResolutions, s.CameraResolution) {
+		invalid("cameraResolution", s.CameraResolution)
+		s.CameraResolution = d.CameraResolution
+	}
+	if s.CameraFPS < MinFPS || s.CameraFPS > MaxFPS {
+		invalid("cameraFps", s.CameraFPS)
+		s.CameraFPS = d.CameraFPS
+	}
+	if s.EmotionAnalysisFPS < MinFPS || s.EmotionAnalysisFPS > MaxFPS {
+		invalid("emotionAnalysisFps", s.EmotionAnalysisFPS)
+		s.EmotionAnalysisFPS = d.EmotionAnalysisFPS
+	}
+	if !contains(Classifiers, s.Classifier) {
+		invalid("classifier", s.Classifier)
+		s.Classifier = d.Classifier
+	}
+	if s.ModelPath == "" {
+		invalid("modelPath", s.ModelPath)
+		s.ModelPath = d.ModelPath
+	}
+	if err := s.Detector.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if s.HeadPoseSmoothing <= 0 || s.HeadPoseSmoothing > 1 {
+		invalid("headPoseSmoothing", s.HeadPoseSmoothing)
+		s.HeadPoseSmoothing = d.HeadPoseSmoothing
+	}
+	if !contains(FaceLostActions, s.FaceLostAction) {
+		invalid("faceLostAction", s.FaceLostAction)
+		s.FaceLostAction = d.FaceLostAction
+	}
+	if s.FaceLostTimeout < MinFaceLostTimeout || s.FaceLostTimeout > MaxFaceLostTimeout {
+		invalid("faceLostTimeout", s.FaceLostTimeout)
+		s.FaceLostTimeout = d.FaceLostTimeout
+	}
+	if s.BoardWidth < MinBoardWidth || s.BoardWidth > MaxBoardWidth {
+		invalid("boardWidth", s.BoardWidth)
+		s.BoardWidth = d.BoardWidth
+	}
+	if s.BoardHeight < MinBoardHeight || s.BoardHeight > MaxBoardHeight {
+		invalid("boardHeight", s.BoardHeight)
+		s.BoardHeight = d.BoardHeight
+	}
+	if s.DropInterval < MinDropInterval || s.DropInterval > MaxDropInterval {
+		invalid("dropInterval", s.DropInterval)
+		s.DropInterval = d.DropInterval
+	}
+	if s.TimeLimit < MinTimeLimit || s.TimeLimit > MaxTimeLimit {
+		invalid("timeLimit", s.TimeLimit)
+		s.TimeLimit = d.TimeLimit
+	}
+	if u, err := url.Parse(s.ServerURL); err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		invalid("serverUrl", s.ServerURL)
+		s.ServerURL = d.ServerURL
+	}
+	if s.Room == "" {
+		invalid("room", s.Room)
+		s.Room = d.Room
+	}
+	if s.InputDelay < MinInputDelay || s.InputDelay > MaxInputDelay {
+		invalid("inputDelay", s.InputDelay)
+		s.InputDelay = d.InputDelay
+	}
+	if s.CPULevel < bot.MinLevel || s.CPULevel > bot.MaxLevel {
+		invalid("cpuLevel", s.CPULevel)
+		s.CPULevel = d.CPULevel
+	}
+	if s.PlayerName == "" {
+		invalid("playerName", s.PlayerName)
+		s.PlayerName = d.PlayerName
+	}
+	return errors.Join(errs...)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Duration は JSON では "2s" や "1m30s" のような文字列で表す時間
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// ServerEndpoint は中継サーバーの URL のパスを path にしたものを返す（/publish, /watch など）
+func (s Settings) ServerEndpoint(path string) string {
+	u, err := url.Parse(s.ServerURL)
+	if err != nil {
+		return s.ServerURL
+	}
+	u.Path = path
+	u.RawQuery = ""
+	return u.String()
+}
+
+// CameraSize はカメラに要求する解像度の幅と高さを返す
+func (s Settings) CameraSize() (width, height int) {
+	if _, err := fmt.Sscanf(s.CameraResolution, "%dx%d", &width, &height); err != nil {
+		return Default().CameraSize()
+	}
+	return width, height
+}
+
+// EngineOptions はゲームのルールに関わる設定を返す
+func (s Settings) EngineOptions() engine.Options {
+	return engine.Options{
+		DropInterval: time.Duration(s.DropInterval),
+		TimeLimit:    time.Duration(s.TimeLimit),
+		BoardWidth:   s.BoardWidth,
+		BoardHeight:  s.BoardHeight,
+	}
+}
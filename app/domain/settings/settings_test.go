@@ -0,0 +1,109 @@
+package settings
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestApplyQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		check   func(s Settings) bool
+		wantErr bool
+	}{
+		{
+			name:  "numeric string fields",
+			query: "room=1234&playerName=007&modelPath=123",
+			check: func(s Settings) bool { return s.Room == "1234" && s.PlayerName == "007" && s.ModelPath == "123" },
+		},
+		{
+			name:  "duration and bool",
+			query: "dropInterval=1s&camera=false",
+			check: func(s Settings) bool { return s.DropInterval == Duration(time.Second) && !s.Camera },
+		},
+		{
+			name:  "numbers",
+			query: "cpuLevel=7&headPoseSmoothing=0.5&cameraFps=15",
+			check: func(s Settings) bool { return s.CPULevel == 7 && s.HeadPoseSmoothing == 0.5 && s.CameraFPS == 15 },
+		},
+		{
+			name:  "plain strings",
+			query: "classifier=model&serverUrl=wss://example.com/ws&cameraResolution=1280x720",
+			check: func(s Settings) bool {
+				return s.Classifier == "model" && s.ServerURL == "wss://example.com/ws" && s.CameraResolution == "1280x720"
+			},
+		},
+		{
+			name:  "nested object keeps other fields",
+			query: `detector={"maxFaces":2}`,
+			check: func(s Settings) bool {
+				return s.Detector.MaxFaces == 2 && s.Detector.MinSize == Default().Detector.MinSize
+			},
+		},
+		{
+			name:  "unknown keys are ignored",
+			query: "unknown=1&room=abc",
+			check: func(s Settings) bool { return s.Room == "abc" },
+		},
+		{
+			name:  "invalid JSON number keeps valid keys",
+			query: "cpuLevel=007&dropInterval=1s&camera=false",
+			check: func(s Settings) bool {
+				return s.CPULevel == Default().CPULevel && s.DropInterval == Duration(time.Second) && !s.Camera
+			},
+			wantErr: true,
+		},
+		{
+			name:  "NaN and Inf keep valid keys",
+			query: "headPoseSmoothing=NaN&cameraFps=Inf&room=1234",
+			check: func(s Settings) bool {
+				return s.HeadPoseSmoothing == Default().HeadPoseSmoothing && s.CameraFPS == Default().CameraFPS && s.Room == "1234"
+			},
+			wantErr: true,
+		},
+		{
+			name:  "wrong type keeps valid keys",
+			query: "camera=yes&cpuLevel=abc&timeLimit=2m",
+			check: func(s Settings) bool {
+				return s.Camera && s.CPULevel == Default().CPULevel && s.TimeLimit == Duration(2*time.Minute)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			query:   "dropInterval=fast&room=x",
+			check:   func(s Settings) bool { return s.DropInterval == Default().DropInterval && s.Room == "x" },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := Default()
+			err = s.ApplyQuery(values)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ApplyQuery error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.check(s) {
+				t.Errorf("ApplyQuery(%q) = %+v", tt.query, s)
+			}
+		})
+	}
+}
+
+func TestValidateResetsInvalidValues(t *testing.T) {
+	s := Default()
+	s.CPULevel = 100
+	s.Room = ""
+	if err := s.Validate(); err == nil {
+		t.Error("Validate returned no error")
+	}
+	if d := Default(); s.CPULevel != d.CPULevel || s.Room != d.Room {
+		t.Errorf("Validate = cpuLevel %d, room %q; want the defaults", s.CPULevel, s.Room)
+	}
+}
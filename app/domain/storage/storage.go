@@ -0,0 +1,11 @@
+// Package storage は設定や記録をビルド先に応じた場所に保存する
+// wasm ではブラウザの localStorage、それ以外ではユーザーの設定ディレクトリのファイルを使う
+package storage
+
+import "errors"
+
+// 保存先のキーの接頭辞（localStorage のキーやディレクトリ名に使う）
+const namespace = "square-face-tetris"
+
+// ErrNotFound はキーに対応するデータが保存されていないことを表す
+var ErrNotFound = errors.New("storage: not found")
@@ -0,0 +1,44 @@
+//go:build !js || !wasm
+// +build !js !wasm
+
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Load はユーザーの設定ディレクトリのファイルからデータを読み込む
+func Load(key string) ([]byte, error) {
+	path, err := filePath(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Save はユーザーの設定ディレクトリのファイルにデータを保存する
+func Save(key string, data []byte) error {
+	path, err := filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func filePath(key string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, namespace, key+".json"), nil
+}
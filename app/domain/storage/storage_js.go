@@ -0,0 +1,21 @@
+//go:build js && wasm
+// +build js,wasm
+
+package storage
+
+import "syscall/js"
+
+// Load は localStorage からデータを読み込む
+func Load(key string) ([]byte, error) {
+	value := js.Global().Get("localStorage").Call("getItem", namespace+"/"+key)
+	if value.IsNull() {
+		return nil, ErrNotFound
+	}
+	return []byte(value.String()), nil
+}
+
+// Save は localStorage にデータを保存する
+func Save(key string, data []byte) error {
+	js.Global().Get("localStorage").Call("setItem", namespace+"/"+key, string(data))
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package timeline はゲーム中に判定された表情と出来事を時系列で記録する
+package timeline
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/engine"
+)
+
+// 表情が変わったことを表す種類（それ以外は engine.EventLineClear などと同じ）
+const TypeEmotion = "emotion"
+
+// Entry は時系列の 1 件
+type Entry struct {
+	Frame       int                `json:"frame"`
+	Time        float64            `json:"time"` // 開始からの秒数
+	Type        string             `json:"type"`
+	Emotions    []string           `json:"emotions,omitempty"`
+	Confidences map[string]float64 `json:"confidences,omitempty"`
+	Lines       int                `json:"lines,omitempty"`
+}
+
+// Timeline は 1 ゲーム分の時系列
+type Timeline struct {
+	Entries []Entry `json:"entries"`
+
+	lastEmotions []int
+	started      bool
+}
+
+// AddEmotions は判定されている表情が変わったときに記録する
+// confidences は constants.SMILE などのインデックスに対応する確からしさ
+func (t *Timeline) AddEmotions(frame int, emotionIndexes []int, confidences []float64) {
+	if t.started && sameInts(emotionIndexes, t.lastEmotions) {
+		return
+	}
+	t.started = true
+	t.lastEmotions = append(t.lastEmotions[:0], emotionIndexes...)
+
+	entry := Entry{
+		Frame: frame,
+		Time:  seconds(frame),
+		Type:  TypeEmotion,
+	}
+	for _, i := range emotionIndexes {
+		entry.Emotions = append(entry.Emotions, domain.EmotionName(i))
+	}
+	for i, c := range confidences {
+		if c > 0 {
+			if entry.Confidences == nil {
+				entry.Confidences = map[string]float64{}
+			}
+			entry.Confidences[domain.EmotionName(i)] = math.Round(c*1000) / 1000
+		}
+	}
+	t.Entries = append(t.Entries, entry)
+}
+
+// AddEvent はゲーム中の出来事を記録する
+func (t *Timeline) AddEvent(ev engine.GameEvent) {
+	t.Entries = append(t.Entries, Entry{
+		Frame: ev.Frame,
+		Time:  seconds(ev.Frame),
+		Type:  ev.Type,
+		Lines: ev.Lines,
+	})
+}
+
+// WriteJSON は時系列を JSON で書き出す
+func (t *Timeline) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(t)
+}
+
+// seconds はフレーム数をミリ秒単位に丸めた秒数にする
+func seconds(frame int) float64 {
+	return math.Round(engine.Duration(frame).Seconds()*1000) / 1000
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,34 +1,43 @@
 package wasm
 
 import (
-	"bytes"
-	"encoding/base64"
-	"fmt"
-
 	"image"
+	"image/color"
 	"log"
-	"math"
 	"time"
 
 	"square-face-tetris/app/constants"
 	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/detection"
 	"syscall/js"
 
-	"github.com/esimov/pigo/wasm/detector"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 var (
-	video       js.Value
-	stream      js.Value
-	canvas      js.Value
-	ctx         js.Value
-	cascadeFile []byte
-	det         *detector.Detector
+	video  js.Value
+	stream js.Value
+	canvas js.Value
+	ctx    js.Value
+
+	// 顔検出（ゲームのループとは別に動き、結果を Results で返す）
+	runner detection.Runner
+	// 最後に反映した検出結果のフレームの取得時刻
+	lastDetectionTime time.Time
 
 	CanvasImage    *ebiten.Image
+	CameraFrame    image.Image // 最後に取得したカメラの映像（ハイライトの記録に使う。次のフレームで上書きされる）
 	lastUpdateTime time.Time
-	updateInterval = time.Second / constants.CAMERA_PREVIEW_FPS
+
+	// カメラの映像のバッファ（大きさが変わるまで使い回す）
+	// frame の RGBA をプレビューと CameraFrame で共有する
+	frame *image.RGBA
+
+	// プレビューに重ねて描画する、最後に検出した顔とランドマーク
+	lastDetections [][]int
+	lastTracks     []detection.Track
+	updateInterval = time.Second / time.Duration(config.CameraPreviewFPS)
 
 	cameraWidth   int
 	cameraHeight  int
@@ -36,24 +45,33 @@ var (
 	previewWidth  float64
 	previewHeight float64
 
-	Face domain.Face
+	Face         domain.Face
 	IsFaceInited bool
+	FaceDetected bool // 最後の表情分析で顔を検出できたか
+	// 最後の表情分析での顔の追跡の状態（HUD に表示する）
+	Tracking TrackingStatus
 
-	lastEmotionAnalysisTime time.Time
-	emotionAnalysisInterval = time.Second / constants.EMOTION_ANALYSIS_FPS
+	// 頭の向き（顔を初めて検出したときの向きを基準にする）
+	HeadPose = domain.NewPoseEstimator(config.HeadPoseSmoothing)
+	// 顔が映っているか（見失ったら表情と頭の向きを解除する）
+	FacePresence = domain.NewPresence(time.Duration(config.FaceLostTimeout))
 
-	MoveLeft  bool
-	MoveRight bool
-	MoveUp    bool
-	MoveDown  bool
+	lastEmotionAnalysisTime time.Time
+	emotionAnalysisInterval = time.Second / time.Duration(config.EmotionAnalysisFPS)
 )
 
 func InitCamera() {
-	// 分析器の初期化
-	det = detector.NewDetector()
-	err := det.UnpackCascades()
-	if err != nil {
-		log.Fatal(err)
+	if !config.Camera {
+		return
+	}
+
+	// 顔検出の初期化（カスケードの読み込みは検出する側で行う）
+	runner = newRunner()
+	runner.Configure(detectorOptions())
+
+	// 表情の分類器を読み込む
+	if err := LoadClassifier(); err != nil {
+		log.Printf("分類器の読み込みに失敗したため、ルールベースで判定します: %v", err)
 	}
 
 	// DOM 要素の取得
@@ -62,248 +80,221 @@ func InitCamera() {
 	canvas = doc.Call(("createElement"), "canvas")
 	video.Set("muted", true)
 
-	// カメラの映像の取得権限をリクエスト
-	mediaDevices := js.Global().Get("navigator").Get("mediaDevices")
-	promise := mediaDevices.Call("getUserMedia", map[string]interface{}{
-		"video": true,
-		"audio": false,
+	// 映像の大きさが分かったとき・変わったときに、canvas とプレビューの大きさを合わせる
+	resize := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		cameraWidth = video.Get("videoWidth").Int()
+		cameraHeight = video.Get("videoHeight").Int()
+		canvas.Set("width", cameraWidth)
+		canvas.Set("height", cameraHeight)
+		ctx = canvas.Call("getContext", "2d")
+
+		// アスペクト比を計算
+		aspectRatio = float64(cameraHeight) / float64(cameraWidth)
+		previewWidth = float64(cameraWidth) * 0.25
+		previewHeight = previewWidth * aspectRatio
+
+		return nil
 	})
-	promise.Call("then", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		stream = args[0]
-		video.Set("srcObject", stream)
-		video.Call("play")
-		video.Call("addEventListener", "loadedmetadata", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-			cameraWidth = video.Get("videoWidth").Int()
-			cameraHeight = video.Get("videoHeight").Int()
-			canvas.Set("width", cameraWidth)
-			canvas.Set("height", cameraHeight)
-			ctx = canvas.Call("getContext", "2d")
-
-			// アスペクト比を計算
-			aspectRatio = float64(cameraHeight) / float64(cameraWidth)
-			previewWidth = float64(cameraWidth) * 0.25
-			previewHeight = previewWidth * aspectRatio
-
-			return nil
-		}))
+	video.Call("addEventListener", "loadedmetadata", resize)
+	video.Call("addEventListener", "resize", resize)
+
+	mediaDevices := js.Global().Get("navigator").Get("mediaDevices")
+	if !mediaDevices.Truthy() {
+		setCameraError("このブラウザではカメラを使用できません\nhttps か localhost で開いてください", nil)
+		return
+	}
+	// カメラがつながれた・外されたときに一覧を取り直す
+	mediaDevices.Call("addEventListener", "devicechange", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		enumerateDevices()
 		return nil
 	}))
+	startCamera()
 }
 
 func UpdateCamera() {
+	receiveDetections()
+
 	if !ctx.Truthy() || time.Since(lastUpdateTime) < updateInterval {
 		return
 	}
 	lastUpdateTime = time.Now()
 
-	ctx.Call("clearRect", 0, 0, cameraWidth, cameraHeight)
-
-	// video の映像を canvas に移す
-	if !constants.IS_CAMERA {
+	if !config.Camera {
 		return
 	}
 
+	// video の映像を canvas に移し、RGBA のまま 1 回だけコピーする
 	ctx.Call("drawImage", video, 0, 0, cameraWidth, cameraHeight)
-
 	rgba := ctx.Call("getImageData", 0, 0, cameraWidth, cameraHeight, map[string]interface{}{
 		"willReadFrequently": true,
 	}).Get("data")
-
-	// 画像を分析
-	var data = make([]byte, cameraWidth*cameraHeight*4)
-	uint8Arr := js.Global().Get("Uint8Array").New(rgba)
-	js.CopyBytesToGo(data, uint8Arr)
-	pixels := rgbaToGrayscale(data)
+	allocateFrame()
+	js.CopyBytesToGo(frame.Pix, js.Global().Get("Uint8Array").New(rgba.Get("buffer")))
 
 	// 表情分析の頻度を制御
+	// 検出は別の goroutine か Web Worker で行い、結果は次のフレーム以降に receiveDetections で反映する
 	if time.Since(lastEmotionAnalysisTime) >= emotionAnalysisInterval {
 		lastEmotionAnalysisTime = time.Now()
-		// det.DetectFaces は画像データを受け取り、以下のデータを返す
-		// [row, col, scale, q]
-		// row, col: 顔の中心座標
-		// scale: 顔のスケール
-		// q: 顔であることの信頼度
-		res := det.DetectFaces(pixels, cameraHeight, cameraWidth)
-		if len(res) > 0 {
-			DrawFaceRect(res)
-
-			// 両目の位置を取得
-			leftEye := det.DetectLeftPupil(res[0])
-			rightEye := det.DetectRightPupil(res[0])
-
-			// 顔のランドマークを取得
-			landmarks := det.DetectLandmarkPoints(leftEye, rightEye)
-			DrawLandmarkPoints(landmarks)
-
-			// 顔の情報が未設定の場合、新しい顔を作成
-			if !IsFaceInited {
-				Face = domain.NewFace(landmarks)
-				IsFaceInited = true
-			}
-
-			// 顔の情報を更新
-			choices := []int{
-				constants.SMILE,
-				constants.ANGRY,
-				constants.SURPRISED,
-				constants.SUS,
-			}
-			Face.Update(landmarks, choices)
-
-			// 鼻の位置をチェック
-			CheckNosePosition(landmarks, 50, 25)
-		}
+		pixels := runner.Buffer(cameraWidth * cameraHeight)
+		rgbaToGrayscale(frame.Pix, pixels)
+		runner.Submit(detection.Frame{
+			Time:   lastEmotionAnalysisTime,
+			Pixels: pixels,
+			Width:  cameraWidth,
+			Height: cameraHeight,
+		})
 	}
 
-	// 画面中央に赤い点を描画
-	DrawCenterPoint()
-
-	// canvas 経由で画面を base64 形式で取得
-	b64 := canvas.Call("toDataURL", "image/png").String()
+	// プレビューは同じバッファから、使い回している ebiten.Image に書き込む
+	CanvasImage.WritePixels(frame.Pix)
+	CameraFrame = frame
+}
 
-	// image.Image にデコード
-	dec, err := base64.StdEncoding.DecodeString(b64[22:])
-	if err != nil {
-		log.Fatal(err)
+// receiveDetections は届いている検出結果のうち、最新のものを反映する
+func receiveDetections() {
+	if runner == nil {
+		return
 	}
-	img, _, err := image.Decode(bytes.NewReader(dec))
-	if err != nil {
-		log.Fatal(err)
+	var (
+		latest   detection.Result
+		received bool
+	)
+	results := runner.Results()
+	for len(results) > 0 {
+		latest, received = <-results
 	}
-
-	// ebiten.Image にして保持
-	CanvasImage = ebiten.NewImageFromImage(img)
-}
-
-func DrawFaceRect(dets [][]int) {
-	for _, det := range dets {
-		ctx.Set("lineWidth", 10)
-		ctx.Set("strokeStyle", "rgba(255, 0, 0, 0.5)")
-
-		row, col, scale := det[1], det[0], int(float64(det[2])*0.72)
-		ctx.Call("rect", row-scale/2, col-scale/2, scale, scale)
-		ctx.Call("stroke")
+	// 前に反映したものより古いフレームの結果は捨てる
+	if received && latest.Time.After(lastDetectionTime) {
+		applyDetection(latest)
 	}
 }
 
-func DrawCameraPrev(screen *ebiten.Image) {
-	if !constants.IS_CAMERA_PREVIEW || CanvasImage == nil {
+// applyDetection は検出結果を顔の情報と頭の向きに反映する
+func applyDetection(r detection.Result) {
+	lastDetectionTime = r.Time
+
+	// r.Faces は [row, col, scale, q]
+	// row, col: 顔の中心座標
+	// scale: 顔のスケール
+	// q: 顔であることの信頼度
+	// 瞳やランドマークが足りないフレームは、顔が見つからなかったものとして扱う
+	primary, ok := r.Primary()
+	FaceDetected = ok && primary.Rejected == ""
+	lastDetections = r.Faces
+	lastTracks = r.Tracks
+	Tracking = TrackingStatus{Faces: len(r.Faces), Rejected: primary.Rejected}
+	FacePresence.Update(FaceDetected, r.Time)
+	updateTrackedFaces(r)
+	if !FaceDetected {
+		// 見失ったままの場合、最後の表情と頭の向きが残らないようにする
+		if FacePresence.Lost(r.Time) {
+			Face.Clear()
+			HeadPose.Reset()
+		}
 		return
 	}
-
-	// 保持している ebiten.Image を右上に描画
-	opts := &ebiten.DrawImageOptions{}
-	opts.GeoM.Scale(previewWidth/float64(CanvasImage.Bounds().Dx()), previewHeight/float64(CanvasImage.Bounds().Dy()))
-	opts.GeoM.Translate(float64(constants.ScreenWidth)-previewWidth, 0)
-	screen.DrawImage(CanvasImage, opts)
-}
-
-func DrawLandmarkPoints(landmarks [][]int) {
-	for i := 0; i < len(landmarks); i++ {
-		if len(landmarks[i]) >= 2 {
-			ctx.Set("fillStyle", "red")
-			ctx.Call("beginPath")
-			ctx.Call("rect", landmarks[i][0]-2, landmarks[i][1]-2, 4, 4)
-			ctx.Call("fill")
+	landmarks := domain.NewLandmarks(primary.Landmarks, primary.Pupils)
+	Tracking.Quality = landmarks.Quality()
+
+	// 顔の情報が未設定の場合、新しい顔を作成
+	// 基準の顔は全ての点が揃っているフレームで作る
+	if !IsFaceInited {
+		if !landmarks.Complete() {
+			return
 		}
+		Face.Classifier = EmotionClassifier
+		Face.Calibrate(landmarks)
+		HeadPose.Calibrate(landmarks)
+		IsFaceInited = true
 	}
 
+	// 顔の情報を更新
+	Face.Update(landmarks)
+	HeadPose.Update(landmarks)
+
+	// データセット用にランドマークを記録
+	SampleRecorder.Add(primary.Landmarks, primary.Pupils, Face.Snapshot.Landmarks.Slice())
 }
 
-func rgbaToGrayscale(data []uint8) []uint8 {
-	rows, cols := cameraWidth, cameraHeight
-	for r := 0; r < rows; r++ {
-		for c := 0; c < cols; c++ {
-			// gray = 0.2*red + 0.7*green + 0.1*blue
-			data[r*cols+c] = uint8(math.Round(
-				0.2126*float64(data[r*4*cols+4*c+0]) +
-					0.7152*float64(data[r*4*cols+4*c+1]) +
-					0.0722*float64(data[r*4*cols+4*c+2])))
-		}
-	}
-	return data
+// FaceLost は顔を見失っているかを返す（カメラ機能が無効な場合は false）
+func FaceLost() bool {
+	return runner != nil && FacePresence.Lost(time.Now())
 }
 
-func isAllZero(arr []int) bool {
-	for _, v := range arr {
-		if v != 0 {
-			return false
-		}
-	}
-	return true
+// TrackingStatus は顔の追跡の状態
+type TrackingStatus struct {
+	Faces    int     // 見つかった顔の数
+	Rejected string  // 顔は見つかったが使わなかった理由（"pupils", "landmarks"）
+	Quality  float64 // 検出できたランドマークと瞳の割合（0 から 1）
 }
 
-func CheckNosePosition(landmarks [][]int, horizontalThreshold, verticalThreshold int) {
-	if len(landmarks) < 1 || len(Face.Snapshot.Landmarks) < 1 {
+// allocateFrame はカメラの映像の大きさに合わせてバッファを用意する
+// 大きさが変わらない限り、前のフレームのバッファをそのまま使う
+func allocateFrame() {
+	if frame != nil && frame.Rect.Dx() == cameraWidth && frame.Rect.Dy() == cameraHeight {
 		return
 	}
+	frame = image.NewRGBA(image.Rect(0, 0, cameraWidth, cameraHeight))
+	if CanvasImage != nil {
+		CanvasImage.Deallocate()
+	}
+	CanvasImage = ebiten.NewImage(cameraWidth, cameraHeight)
+}
 
-	// 仮に鼻の位置が landmarks の最初の要素だとする
-	nose := landmarks[0]
-	noseX, noseY := nose[0], nose[1]
-
-	// 基準位置を Face.Snapshot.Landmarks の鼻の位置に変更
-	baseNose := Face.Snapshot.Landmarks[0]
-	baseNoseX, baseNoseY := baseNose[0], baseNose[1]
-
-	// 鼻の位置と基準位置を比較
-	if math.Abs(float64(noseX-baseNoseX)) > float64(horizontalThreshold) {
-		if noseX < baseNoseX {
-			fmt.Println("左")
-			MoveLeft = true
-		} else {
-			fmt.Println("右")
-			MoveRight = true
-		}
-	} else {
-		MoveLeft = false
-		MoveRight = false
+func DrawCameraPrev(screen *ebiten.Image) {
+	if !config.CameraPreview || CanvasImage == nil {
+		return
 	}
 
-	if math.Abs(float64(noseY-baseNoseY)) > float64(verticalThreshold) {
-		if noseY < baseNoseY {
-			fmt.Println("上")
-			MoveUp = true
-		} else {
-			fmt.Println("下")
-			MoveDown = true
-		}
-	} else {
-		MoveUp = false
-		MoveDown = false
+	// 保持している ebiten.Image を右上に描画（設定によって左右反転する）
+	scale := previewWidth / float64(CanvasImage.Bounds().Dx())
+	opts := &ebiten.DrawImageOptions{}
+	if config.MirrorCamera {
+		opts.GeoM.Scale(-1, 1)
+		opts.GeoM.Translate(float64(CanvasImage.Bounds().Dx()), 0)
 	}
+	opts.GeoM.Scale(scale, previewHeight/float64(CanvasImage.Bounds().Dy()))
+	opts.GeoM.Translate(float64(constants.ScreenWidth)-previewWidth, 0)
+	screen.DrawImage(CanvasImage, opts)
+
+	drawOverlay(screen, float32(scale))
 }
 
-func createKeyboardEvent(eventType, key string) js.Value {
-	event := js.Global().Get("KeyboardEvent").New(eventType, map[string]interface{}{
-		"key": key,
-		"code": key,
-		"keyCode": getKeyCode(key),
-		"which": getKeyCode(key),
-		"bubbles": true,
-	})
-	return event
+// toPreview はカメラの映像の座標を、プレビューを描画した画面上の座標に変換する
+func toPreview(x, y float64) (float64, float64) {
+	if config.MirrorCamera {
+		x = float64(cameraWidth) - x
+	}
+	scale := previewWidth / float64(cameraWidth)
+	return float64(constants.ScreenWidth) - previewWidth + x*scale, y * scale
 }
 
-func getKeyCode(key string) int {
-	switch key {
-	case "ArrowLeft":
-		return 37
-	case "ArrowUp":
-		return 38
-	case "ArrowRight":
-		return 39
-	case "ArrowDown":
-		return 40
-	default:
-		return 0
+// drawOverlay はプレビューの上に、検出した顔の枠・ランドマーク・中央の点を描画する
+// scale はカメラの映像からプレビューへの縮尺
+func drawOverlay(screen *ebiten.Image, scale float32) {
+	red := color.RGBA{255, 0, 0, 255}
+	for _, det := range lastDetections {
+		x, y := toPreview(float64(det[1]), float64(det[0]))
+		size := float32(det[2]) * 0.72 * scale
+		vector.StrokeRect(screen, float32(x)-size/2, float32(y)-size/2, size, size, 2, color.RGBA{255, 0, 0, 128}, false)
 	}
+	for _, t := range lastTracks {
+		for _, p := range t.Landmarks {
+			if len(p) >= 2 {
+				x, y := toPreview(float64(p[0]), float64(p[1]))
+				vector.DrawFilledRect(screen, float32(x)-1, float32(y)-1, 2, 2, red, false)
+			}
+		}
+	}
+	x, y := toPreview(float64(cameraWidth)/2, float64(cameraHeight)/2)
+	vector.DrawFilledCircle(screen, float32(x), float32(y), 2, red, false)
 }
 
-func DrawCenterPoint() {
-	centerX, centerY := cameraWidth/2, cameraHeight/2
-	ctx.Set("fillStyle", "red")
-	ctx.Call("beginPath")
-	ctx.Call("arc", centerX, centerY, 5, 0, 2*math.Pi)
-	ctx.Call("fill")
+// rgbaToGrayscale は RGBA の画素をグレースケールにして gray に書き込む
+func rgbaToGrayscale(rgba, gray []uint8) {
+	for i := range gray {
+		// gray = 0.2*red + 0.7*green + 0.1*blue
+		r, g, b := uint32(rgba[4*i]), uint32(rgba[4*i+1]), uint32(rgba[4*i+2])
+		gray[i] = uint8((2126*r + 7152*g + 722*b + 5000) / 10000)
+	}
 }
@@ -0,0 +1,52 @@
+package wasm
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"syscall/js"
+
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/model"
+)
+
+// 表情の判定に使う分類器（nil の場合は Face のルールベースで判定する）
+var EmotionClassifier domain.Classifier
+
+// LoadClassifier は設定で選択された分類器を用意する
+func LoadClassifier() error {
+	switch config.Classifier {
+	case "rule":
+		EmotionClassifier = nil
+		return nil
+	case "model":
+		m, err := fetchModel(config.ModelPath)
+		if err != nil {
+			return err
+		}
+		EmotionClassifier = m
+		return nil
+	default:
+		return fmt.Errorf("不明な分類器です: %s", config.Classifier)
+	}
+}
+
+// fetchModel は学習済みモデルをサーバーから取得する
+func fetchModel(path string) (*model.Model, error) {
+	u, err := url.Parse(js.Global().Get("location").Get("href").String())
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path
+
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v model file is missing", u)
+	}
+
+	return model.Load(resp.Body)
+}
@@ -0,0 +1,70 @@
+package wasm
+
+import (
+	"log"
+	"time"
+
+	"square-face-tetris/app/domain/detection"
+	"square-face-tetris/app/domain/settings"
+)
+
+// 現在の設定（Configure で更新する）
+var config = settings.Default()
+
+// Configure は設定をカメラと表情分析に反映する
+// カメラ機能の有効・無効は InitCamera を呼んだ時点の設定が使われる
+func Configure(s settings.Settings) {
+	classifierChanged := s.Classifier != config.Classifier || s.ModelPath != config.ModelPath
+	config = s
+
+	updateInterval = time.Second / time.Duration(s.CameraPreviewFPS)
+	emotionAnalysisInterval = time.Second / time.Duration(s.EmotionAnalysisFPS)
+	HeadPose.Smoothing = s.HeadPoseSmoothing
+	FacePresence.Timeout = time.Duration(s.FaceLostTimeout)
+	if runner != nil {
+		runner.Configure(detectorOptions())
+	}
+
+	// 起動後にカメラ・解像度・FPS が変わった場合は、映像の取得を開始し直す
+	if cameraConstraints != "" && cameraKey() != cameraConstraints {
+		startCamera()
+	}
+
+	// 起動後に分類器が変わった場合は読み込み直し、基準の顔を取り直す
+	if classifierChanged && runner != nil {
+		if err := LoadClassifier(); err != nil {
+			log.Printf("分類器の読み込みに失敗したため、ルールベースで判定します: %v", err)
+			EmotionClassifier = nil
+		}
+		IsFaceInited = false
+	}
+}
+
+// Mirrored はプレビューを左右反転しているかを返す
+func Mirrored() bool {
+	return config.MirrorCamera
+}
+
+// 遊んでいるモードが必要とする顔の数（RequireFaces で変更する）
+var requiredFaces = 1
+
+// RequireFaces は瞳とランドマークを検出する顔の数を、設定の値より少なくとも n にする
+// 複数の顔で遊ぶモードを始めるときと終えるときに呼ぶ
+func RequireFaces(n int) {
+	if n == requiredFaces {
+		return
+	}
+	requiredFaces = n
+	if runner != nil {
+		runner.Configure(detectorOptions())
+	}
+}
+
+// detectorOptions は検出に使うパラメータを返す
+func detectorOptions() detection.Options {
+	o := config.Detector
+	if o.MaxFaces < requiredFaces {
+		o.MaxFaces = requiredFaces
+	}
+	return o
+}
@@ -0,0 +1,139 @@
+package wasm
+
+import (
+	"fmt"
+	"log"
+	"syscall/js"
+)
+
+// CameraDevice は選択できるカメラ
+type CameraDevice struct {
+	ID    string
+	Label string
+}
+
+var (
+	// 最後に取得したカメラの一覧（ラベルはカメラの使用が許可された後にだけ分かる）
+	cameraDevices []CameraDevice
+	// カメラを開始できなかった理由（画面に表示する）
+	cameraError string
+	// カメラの開始に使った設定（変わったら開始し直す）
+	cameraConstraints string
+)
+
+// CameraDevices は選択できるカメラの一覧を返す
+func CameraDevices() []CameraDevice {
+	return cameraDevices
+}
+
+// CameraError はカメラを開始できなかった理由を返す（開始できていれば空）
+func CameraError() string {
+	return cameraError
+}
+
+// startCamera は設定のカメラ・解像度・FPS で映像の取得を開始する
+// 既に取得している映像があれば止めてから開始し直す
+func startCamera() {
+	stopCamera()
+
+	width, height := config.CameraSize()
+	constraints := map[string]interface{}{
+		"width":     map[string]interface{}{"ideal": width},
+		"height":    map[string]interface{}{"ideal": height},
+		"frameRate": map[string]interface{}{"ideal": config.CameraFPS},
+	}
+	// 選んだカメラが外されていても、既定のカメラで開始できるよう ideal で指定する
+	if config.CameraDevice != "" {
+		constraints["deviceId"] = map[string]interface{}{"ideal": config.CameraDevice}
+	} else {
+		constraints["facingMode"] = "user"
+	}
+	cameraConstraints = cameraKey()
+
+	// カメラの映像の取得権限をリクエスト
+	var then, catch js.Func
+	release := func() {
+		then.Release()
+		catch.Release()
+	}
+	then = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		defer release()
+		stream = args[0]
+		cameraError = ""
+		video.Set("srcObject", stream)
+		video.Call("play")
+		enumerateDevices()
+		return nil
+	})
+	catch = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		defer release()
+		setCameraError(cameraErrorMessage(args[0]), fmt.Errorf("%s: %s", args[0].Get("name").String(), args[0].Get("message").String()))
+		return nil
+	})
+	js.Global().Get("navigator").Get("mediaDevices").Call("getUserMedia", map[string]interface{}{
+		"video": constraints,
+		"audio": false,
+	}).Call("then", then).Call("catch", catch)
+}
+
+// stopCamera は取得している映像を止める
+func stopCamera() {
+	if !stream.Truthy() {
+		return
+	}
+	tracks := stream.Call("getTracks")
+	for i := 0; i < tracks.Length(); i++ {
+		tracks.Index(i).Call("stop")
+	}
+	stream = js.Undefined()
+}
+
+// cameraKey はカメラの開始に使う設定を 1 つの文字列にする
+func cameraKey() string {
+	return fmt.Sprintf("%s/%s/%d", config.CameraDevice, config.CameraResolution, config.CameraFPS)
+}
+
+// cameraErrorMessage は getUserMedia のエラーを画面に表示する文に変換する
+func cameraErrorMessage(err js.Value) string {
+	switch err.Get("name").String() {
+	case "NotAllowedError", "SecurityError":
+		return "カメラの使用が許可されていません\nブラウザの設定で許可して再読み込みしてください"
+	case "NotFoundError", "OverconstrainedError":
+		return "カメラが見つかりません"
+	case "NotReadableError":
+		return "カメラを使用できません\n他のアプリが使用していないか確認してください"
+	}
+	return "カメラを開始できません\n" + err.Get("message").String()
+}
+
+// setCameraError はカメラを開始できなかった理由を記録する
+func setCameraError(message string, err error) {
+	cameraError = message
+	if err != nil {
+		log.Printf("カメラを開始できません: %v", err)
+	}
+}
+
+// enumerateDevices はカメラの一覧を取得する
+func enumerateDevices() {
+	var then js.Func
+	then = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		defer then.Release()
+		var devices []CameraDevice
+		list := args[0]
+		for i := 0; i < list.Length(); i++ {
+			d := list.Index(i)
+			if d.Get("kind").String() != "videoinput" {
+				continue
+			}
+			label := d.Get("label").String()
+			if label == "" {
+				label = fmt.Sprintf("カメラ %d", len(devices)+1)
+			}
+			devices = append(devices, CameraDevice{ID: d.Get("deviceId").String(), Label: label})
+		}
+		cameraDevices = devices
+		return nil
+	})
+	js.Global().Get("navigator").Get("mediaDevices").Call("enumerateDevices").Call("then", then)
+}
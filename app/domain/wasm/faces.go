@@ -0,0 +1,104 @@
+package wasm
+
+import (
+	"sort"
+	"time"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/detection"
+)
+
+// TrackedFace は複数の顔で遊ぶときの、追跡している 1 つの顔
+// 顔ごとに基準の顔（表情の判定）と正面の向き（頭の向き）を持つ
+type TrackedFace struct {
+	ID       int
+	Face     domain.Face
+	HeadPose *domain.PoseEstimator
+	Inited   bool  // 基準の顔を設定したか
+	Detected bool  // 最後の表情分析で瞳とランドマークを検出できたか
+	Box      []int // 最後に見つけた位置 [row, col, scale, q]
+
+	lastSeen time.Time
+}
+
+// TrackedFaces は追跡している顔（キーは顔の番号）
+// 設定の時間より長く見つからなかった顔は取り除く
+var TrackedFaces = map[int]*TrackedFace{}
+
+// TrackedFaceIDs は追跡している顔の番号を、見つけた順に返す
+func TrackedFaceIDs() []int {
+	ids := make([]int, 0, len(TrackedFaces))
+	for id := range TrackedFaces {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// PopTrackedEvents は追跡している顔ごとに、溜まっているイベントを取り出す
+func PopTrackedEvents() map[int][]domain.FaceEvent {
+	events := make(map[int][]domain.FaceEvent, len(TrackedFaces))
+	for id, tf := range TrackedFaces {
+		events[id] = tf.Face.PopEvents()
+	}
+	return events
+}
+
+// updateTrackedFaces は検出結果を顔ごとに反映する
+func updateTrackedFaces(r detection.Result) {
+	seen := make(map[int]bool, len(r.Tracks))
+	for _, t := range r.Tracks {
+		seen[t.ID] = true
+		tf := TrackedFaces[t.ID]
+		if tf == nil {
+			tf = &TrackedFace{ID: t.ID, HeadPose: domain.NewPoseEstimator(config.HeadPoseSmoothing)}
+			tf.Face.EmoteFlags = make([]bool, constants.EMOTION_COUNT)
+			TrackedFaces[t.ID] = tf
+		}
+		tf.Box = t.Face
+		tf.lastSeen = r.Time
+		tf.update(t)
+	}
+
+	for id, tf := range TrackedFaces {
+		if seen[id] {
+			continue
+		}
+		tf.Detected = false
+		if r.Time.Sub(tf.lastSeen) > time.Duration(config.FaceLostTimeout) {
+			delete(TrackedFaces, id)
+		}
+	}
+}
+
+func (tf *TrackedFace) update(t detection.Track) {
+	tf.Detected = t.Rejected == ""
+	if !tf.Detected {
+		return
+	}
+	landmarks := domain.NewLandmarks(t.Landmarks, t.Pupils)
+
+	// 基準の顔は全ての点が揃っているフレームで作る
+	if !tf.Inited {
+		if !landmarks.Complete() {
+			return
+		}
+		tf.Face.Classifier = EmotionClassifier
+		tf.Face.Calibrate(landmarks)
+		tf.HeadPose.Calibrate(landmarks)
+		tf.Inited = true
+	}
+	tf.Face.Update(landmarks)
+	tf.HeadPose.Update(landmarks)
+}
+
+// PreviewPosition はカメラの映像の座標を、プレビューを描画した画面上の座標に変換する
+// プレビューを表示していない場合は false を返す
+func PreviewPosition(x, y int) (float64, float64, bool) {
+	if !config.CameraPreview || CanvasImage == nil || cameraWidth == 0 {
+		return 0, 0, false
+	}
+	px, py := toPreview(float64(x), float64(y))
+	return px, py, true
+}
@@ -0,0 +1,98 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"syscall/js"
+	"time"
+
+	"square-face-tetris/app/domain/dataset"
+)
+
+// Recorder はカメラから得たランドマークをラベル付きで記録し、
+// 停止時に JSONL ファイルとしてダウンロードさせる
+type Recorder struct {
+	recording bool
+	label     string
+	samples   []dataset.Sample
+}
+
+var SampleRecorder = &Recorder{label: dataset.Neutral}
+
+func (r *Recorder) IsRecording() bool {
+	return r.recording
+}
+
+func (r *Recorder) Label() string {
+	return r.label
+}
+
+func (r *Recorder) Len() int {
+	return len(r.samples)
+}
+
+// SetLabel は以降に記録するフレームのラベルを変更する
+func (r *Recorder) SetLabel(label string) {
+	r.label = label
+}
+
+// Start は記録を開始する
+func (r *Recorder) Start() {
+	r.recording = true
+	r.samples = r.samples[:0]
+}
+
+// Stop は記録を終了し、記録したフレームをダウンロードさせる
+func (r *Recorder) Stop() {
+	r.recording = false
+	if len(r.samples) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	w := dataset.NewWriter(&buf)
+	for _, s := range r.samples {
+		if err := w.Write(s); err != nil {
+			log.Printf("記録の書き出しに失敗しました: %v", err)
+			return
+		}
+	}
+	filename := fmt.Sprintf("landmarks-%s.jsonl", time.Now().Format("20060102-150405"))
+	Download(filename, "application/x-ndjson", buf.Bytes())
+}
+
+// Add は 1 フレーム分のランドマークを記録する
+// pupils は左右の瞳の座標 [x, y, scale]（検出できなかった瞳は nil）
+func (r *Recorder) Add(landmarks, pupils, baseline [][]int) {
+	if !r.recording {
+		return
+	}
+	r.samples = append(r.samples, dataset.Sample{
+		Time:      time.Now().UnixMilli(),
+		Landmarks: landmarks,
+		Pupils:    pupils,
+		Baseline:  baseline,
+		Label:     r.label,
+	})
+}
+
+// Download はブラウザ上でファイルをダウンロードさせる
+func Download(filename, mimeType string, data []byte) {
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+
+	blob := js.Global().Get("Blob").New([]interface{}{array}, map[string]interface{}{
+		"type": mimeType,
+	})
+	url := js.Global().Get("URL").Call("createObjectURL", blob)
+
+	doc := js.Global().Get("document")
+	a := doc.Call("createElement", "a")
+	a.Set("href", url)
+	a.Set("download", filename)
+	doc.Get("body").Call("appendChild", a)
+	a.Call("click")
+	doc.Get("body").Call("removeChild", a)
+	js.Global().Get("URL").Call("revokeObjectURL", url)
+}
@@ -0,0 +1,203 @@
+package wasm
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"syscall/js"
+
+	"square-face-tetris/app/domain/detection"
+	"square-face-tetris/detector"
+)
+
+// 顔検出の Web Worker のスクリプト
+const detectorWorkerURL = "detector-worker.js"
+
+// workerRunner は Web Worker で顔を検出する detection.Runner
+// worker が使えない場合は goroutine で検出する detection.Pipeline に切り替える
+type workerRunner struct {
+	mu       sync.Mutex
+	worker   js.Value
+	ready    bool     // worker の準備ができたか
+	busy     bool     // worker がフレームを検出中か
+	pending  js.Value // 検出を待っているフレーム（最新の 1 つだけ）
+	options  js.Value // worker の準備ができたら送るパラメータ
+	fallback detection.Runner
+
+	buffer  []byte
+	results chan detection.Result
+	funcs   []js.Func
+}
+
+// newRunner は設定に合わせて顔検出の Runner を作る
+func newRunner() detection.Runner {
+	if config.DetectionWorker && js.Global().Get("Worker").Truthy() {
+		return newWorkerRunner()
+	}
+	return newPipeline()
+}
+
+// newPipeline は goroutine で顔を検出する Runner を作る
+func newPipeline() detection.Runner {
+	return detection.NewPipeline(func() (detection.Detector, error) {
+		det := detector.NewDetector()
+		if err := det.UnpackCascades(); err != nil {
+			return nil, err
+		}
+		return det, nil
+	}, func(err error) {
+		log.Printf("顔検出の準備に失敗しました: %v", err)
+	})
+}
+
+func newWorkerRunner() *workerRunner {
+	r := &workerRunner{
+		worker:  js.Global().Get("Worker").New(detectorWorkerURL),
+		results: make(chan detection.Result, detection.ResultBuffer),
+	}
+	onMessage := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		r.receive(args[0].Get("data"))
+		return nil
+	})
+	onError := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		r.fail(errors.New(args[0].Get("message").String()))
+		return nil
+	})
+	r.funcs = []js.Func{onMessage, onError}
+	r.worker.Call("addEventListener", "message", onMessage)
+	r.worker.Call("addEventListener", "error", onError)
+	return r
+}
+
+func (r *workerRunner) Buffer(n int) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fallback != nil {
+		return r.fallback.Buffer(n)
+	}
+	// Submit で JS 側にコピーするので、同じバッファを使い回せる
+	if cap(r.buffer) < n {
+		r.buffer = make([]byte, n)
+	}
+	return r.buffer[:n]
+}
+
+func (r *workerRunner) Submit(f detection.Frame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fallback != nil {
+		r.fallback.Submit(f)
+		return
+	}
+
+	pixels := js.Global().Get("Uint8Array").New(len(f.Pixels))
+	js.CopyBytesToJS(pixels, f.Pixels)
+	r.pending = js.ValueOf(map[string]interface{}{
+		"type":   "frame",
+		"time":   f.Time.UnixMilli(),
+		"width":  f.Width,
+		"height": f.Height,
+		"pixels": pixels,
+	})
+	r.post()
+}
+
+func (r *workerRunner) Configure(o detection.Options) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fallback != nil {
+		r.fallback.Configure(o)
+		return
+	}
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		log.Printf("顔検出のパラメータを送れません: %v", err)
+		return
+	}
+	r.options = js.ValueOf(map[string]interface{}{
+		"type":    "options",
+		"options": string(data),
+	})
+	r.post()
+}
+
+// post は worker の準備ができていれば、待っているパラメータとフレームを送る
+// フレームは worker が空いているときだけ送る
+func (r *workerRunner) post() {
+	if !r.ready {
+		return
+	}
+	if r.options.Truthy() {
+		r.worker.Call("postMessage", r.options)
+		r.options = js.Undefined()
+	}
+	if r.busy || !r.pending.Truthy() {
+		return
+	}
+	buffer := r.pending.Get("pixels").Get("buffer")
+	r.worker.Call("postMessage", r.pending, []interface{}{buffer})
+	r.pending = js.Undefined()
+	r.busy = true
+}
+
+func (r *workerRunner) receive(data js.Value) {
+	if data.Get("type").String() == "error" {
+		r.fail(errors.New(data.Get("error").String()))
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch data.Get("type").String() {
+	case "ready":
+		r.ready = true
+	case "result":
+		r.busy = false
+		var result detection.Result
+		if err := json.Unmarshal([]byte(data.Get("result").String()), &result); err != nil {
+			log.Printf("顔検出の結果を読み込めません: %v", err)
+			break
+		}
+		detection.Send(r.results, result)
+	}
+	r.post()
+}
+
+// fail は worker を止め、goroutine での検出に切り替える
+func (r *workerRunner) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fallback != nil {
+		return
+	}
+	log.Printf("Web Worker で顔検出ができないため、ゲームと同じスレッドで検出します: %v", err)
+	r.worker.Call("terminate")
+	r.fallback = newPipeline()
+	r.fallback.Configure(detectorOptions())
+
+	// 切り替える前の結果を受け取れるよう、Pipeline の結果を同じチャネルに流す
+	go func(results <-chan detection.Result) {
+		for result := range results {
+			detection.Send(r.results, result)
+		}
+	}(r.fallback.Results())
+}
+
+func (r *workerRunner) Results() <-chan detection.Result {
+	return r.results
+}
+
+func (r *workerRunner) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fallback != nil {
+		r.fallback.Close()
+	} else {
+		r.worker.Call("terminate")
+	}
+	for _, f := range r.funcs {
+		f.Release()
+	}
+}
@@ -2,24 +2,28 @@ package main
 
 import (
 	"log"
-	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"square-face-tetris/app/constants"
 	"square-face-tetris/app/domain/game"
+	"square-face-tetris/app/domain/settings"
 	"square-face-tetris/app/domain/wasm"
 )
 
 func main() {
+	// 設定の読み込み（不正な値は初期値に戻して続行する）
+	s, err := settings.Load()
+	if err != nil {
+		log.Printf("設定の読み込みで問題がありました: %v", err)
+	}
+	wasm.Configure(s)
+
 	// ゲームインスタンスの生成
 	gameWrapper := &game.GameWrapper{
-		Game: game.Game{
-			DropInterval: 2 * time.Second,           // ブロックの落下間隔を2秒に設定
-			KeyState:     make(map[ebiten.Key]bool), // キー入力の状態を管理
-		},
+		Settings: s,
 	}
 	// ゲームの初期化
-	err := gameWrapper.Init()
+	err = gameWrapper.Init()
 	if err != nil {
 		log.Fatalf("ゲームの初期化に失敗しました: %v", err)
 	}
@@ -0,0 +1,76 @@
+//go:build js && wasm
+// +build js,wasm
+
+// detectworker はブラウザの Web Worker の中で顔を検出する
+//
+// detector-worker.js から読み込まれ、ゲームから送られたグレースケールのフレームを検出して結果を返す。
+//
+//	GOOS=js GOARCH=wasm go build -o dist/detector.wasm ./cmd/detectworker
+//
+// メッセージの形式:
+//
+//	ゲーム → worker: {type: "frame", time: 取得時刻（ミリ秒）, width, height, pixels: Uint8Array}
+//	                 {type: "options", options: detection.Options の JSON}
+//	worker → ゲーム: {type: "ready"} / {type: "result", result: detection.Result の JSON} / {type: "error", error}
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+	"time"
+
+	"square-face-tetris/app/domain/detection"
+	"square-face-tetris/detector"
+)
+
+func main() {
+	self := js.Global()
+
+	det := detector.NewDetector()
+	if err := det.UnpackCascades(); err != nil {
+		self.Call("postMessage", map[string]interface{}{"type": "error", "error": err.Error()})
+		return
+	}
+
+	// 画素のバッファは大きさが変わるまで使い回す
+	var pixels []byte
+	self.Set("onmessage", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		data := args[0].Get("data")
+		if data.Get("type").String() == "options" {
+			var o detection.Options
+			if err := json.Unmarshal([]byte(data.Get("options").String()), &o); err != nil {
+				self.Call("postMessage", map[string]interface{}{"type": "error", "error": err.Error()})
+				return nil
+			}
+			det.Configure(o)
+			return nil
+		}
+
+		f := detection.Frame{
+			Time:   time.UnixMilli(int64(data.Get("time").Float())),
+			Width:  data.Get("width").Int(),
+			Height: data.Get("height").Int(),
+		}
+		if len(pixels) != f.Width*f.Height {
+			pixels = make([]byte, f.Width*f.Height)
+		}
+		js.CopyBytesToGo(pixels, data.Get("pixels"))
+		f.Pixels = pixels
+
+		start := time.Now()
+		r := det.Detect(f)
+		r.Time = f.Time
+		r.Duration = time.Since(start)
+
+		result, err := json.Marshal(r)
+		if err != nil {
+			self.Call("postMessage", map[string]interface{}{"type": "error", "error": err.Error()})
+			return nil
+		}
+		self.Call("postMessage", map[string]interface{}{"type": "result", "result": string(result)})
+		return nil
+	}))
+	self.Call("postMessage", map[string]interface{}{"type": "ready"})
+
+	select {}
+}
@@ -0,0 +1,51 @@
+// evaluate は記録したランドマークのデータセットに表情分類器をかけ、
+// 表情ごとの適合率・再現率と混同行列を表示する
+//
+//	go run ./cmd/evaluate session1.jsonl session2.jsonl
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/dataset"
+	"square-face-tetris/app/domain/model"
+)
+
+func main() {
+	classifier := flag.String("classifier", "rule", "使用する分類器（rule, model）")
+	modelPath := flag.String("model", "model/emotion.json", "-classifier model で使うモデルのパス")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: evaluate [flags] dataset.jsonl...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	samples, err := dataset.ReadFiles(flag.Args()...)
+	if err != nil {
+		log.Fatalf("データセットの読み込みに失敗しました: %v", err)
+	}
+
+	var c domain.Classifier
+	switch *classifier {
+	case "rule":
+		c = &domain.Face{}
+	case "model":
+		m, err := model.LoadFile(*modelPath)
+		if err != nil {
+			log.Fatalf("モデルの読み込みに失敗しました: %v", err)
+		}
+		c = m
+	default:
+		log.Fatalf("不明な分類器です: %s", *classifier)
+	}
+
+	dataset.Evaluate(c, samples).Fprint(os.Stdout)
+}
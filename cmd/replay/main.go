@@ -0,0 +1,54 @@
+// replay はゲームの記録をエンジンで再生し、記録したときと同じスコアになるか確かめる
+// ルールを変更したときの回帰確認に使う。一致しない記録があれば終了コード 1 で終わる
+//
+//	go run ./cmd/replay replay1.json replay2.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"square-face-tetris/app/domain/replay"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: replay replay.json...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		r, err := loadFile(path)
+		if err != nil {
+			log.Fatalf("記録の読み込みに失敗しました: %s: %v", path, err)
+		}
+
+		e := replay.Run(r)
+		status := "OK"
+		if e.Score != r.Score {
+			status = "NG"
+			failed = true
+		}
+		fmt.Printf("%s %s: mode=%s frames=%d score=%d (記録: %d)\n", status, path, r.Mode, e.Frame, e.Score, r.Score)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func loadFile(path string) (*replay.Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return replay.Load(f)
+}
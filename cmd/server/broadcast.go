@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"square-face-tetris/app/domain/netplay"
+)
+
+// 観戦クライアントに送るメッセージを溜めておく数
+// 溢れた（受信が追いつかない）クライアントは切断する
+const watcherBuffer = 256
+
+// hub は配信されているゲームの最新の状態を持ち、観戦クライアントに送る
+type hub struct {
+	mu       sync.Mutex
+	games    map[string]*netplay.Snapshot
+	watchers map[chan netplay.Message]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		games:    map[string]*netplay.Snapshot{},
+		watchers: map[chan netplay.Message]struct{}{},
+	}
+}
+
+// publish はゲームを遊んでいるクライアントから状態を受け取る
+// 切断したときは、そのクライアントが配信していたゲームを取り除く
+func (h *hub) publish(w http.ResponseWriter, r *http.Request) {
+	conn, err := netplay.Upgrade(w, r)
+	if err != nil {
+		log.Printf("接続を切り替えられません: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	published := map[string]bool{}
+	for m := range conn.Messages() {
+		if m.Type != netplay.TypeState || m.Game == "" || m.State == nil {
+			continue
+		}
+		if !published[m.Game] {
+			log.Printf("game %q: 配信が始まりました", m.Game)
+			published[m.Game] = true
+		}
+		h.update(m)
+	}
+
+	for game := range published {
+		log.Printf("game %q: 配信が終わりました", game)
+		h.remove(game)
+	}
+}
+
+func (h *hub) update(m netplay.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	game, found := h.games[m.Game]
+	if !found {
+		if !m.State.Full {
+			// 最初の状態が届いていないゲームの差分は反映できない
+			return
+		}
+		game = &netplay.Snapshot{}
+		h.games[m.Game] = game
+	}
+	game.Apply(*m.State)
+	h.broadcast(m)
+}
+
+func (h *hub) remove(game string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.games, game)
+	h.broadcast(netplay.Message{Type: netplay.TypeRemove, Game: game})
+}
+
+// broadcast は全ての観戦クライアントにメッセージを送る（h.mu を取った状態で呼ぶ）
+func (h *hub) broadcast(m netplay.Message) {
+	for ch := range h.watchers {
+		select {
+		case ch <- m:
+		default:
+			delete(h.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe は観戦クライアントを登録し、現在の全てのゲームの状態を先頭に入れたチャネルを返す
+func (h *hub) subscribe() chan netplay.Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan netplay.Message, watcherBuffer+len(h.games))
+	for name, game := range h.games {
+		full := game.Full()
+		ch <- netplay.Message{Type: netplay.TypeState, Game: name, State: &full}
+	}
+	h.watchers[ch] = struct{}{}
+	return ch
+}
+
+func (h *hub) unsubscribe(ch chan netplay.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.watchers[ch]; ok {
+		delete(h.watchers, ch)
+		close(ch)
+	}
+}
+
+// watch は観戦クライアントに WebSocket で状態を送る
+func (h *hub) watch(w http.ResponseWriter, r *http.Request) {
+	conn, err := netplay.Upgrade(w, r)
+	if err != nil {
+		log.Printf("接続を切り替えられません: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ch := h.subscribe()
+	defer h.unsubscribe(ch)
+
+	// クライアントから送られてくるものはないが、切断を検出するために読み続ける
+	closed := make(chan struct{})
+	go func() {
+		for range conn.Messages() {
+		}
+		close(closed)
+	}()
+
+	for {
+		select {
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := conn.Send(m); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// events は観戦クライアントに Server-Sent Events で状態を送る
+// 各イベントの data は WebSocket と同じ JSON のメッセージ
+func (h *hub) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "ストリーミングに対応していません", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	ch := h.subscribe()
+	defer h.unsubscribe(ch)
+
+	for {
+		select {
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(m)
+			if err != nil {
+				log.Printf("メッセージを変換できません: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", m.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"square-face-tetris/app/domain/netplay"
+)
+
+// states は配信するゲームの状態の列（スコアや表情、テトリミノが毎回変わる）
+func states(n int) []*netplay.Snapshot {
+	var s []*netplay.Snapshot
+	for i := 0; i < n; i++ {
+		snap := &netplay.Snapshot{
+			Frame:     i * netplay.BroadcastInterval,
+			TimeLimit: 3600,
+			Board:     []string{"0000", "0000", "1100"},
+			Next:      []string{"I", "O"},
+			Hold:      []string{"", "T", "Z"}[i%3],
+			Score:     i * 100,
+			Lines:     i / 4,
+			Emotions:  [][]string{{}, {"SMILE"}, {"ANGRY", "SUS"}}[i%3],
+		}
+		if i%5 != 4 {
+			snap.Current = &netplay.Piece{Name: "T", X: i % 3, Y: i % 7, Shape: []string{"010", "111"}}
+		}
+		s = append(s, snap)
+	}
+	return s
+}
+
+// deltas は states を配信するときのメッセージを返す
+func deltas(game string, s []*netplay.Snapshot) []netplay.Message {
+	var m []netplay.Message
+	var last *netplay.Snapshot
+	for _, next := range s {
+		d, _ := last.Diff(next)
+		m = append(m, netplay.Message{Type: netplay.TypeState, Game: game, State: &d})
+		last = next
+	}
+	return m
+}
+
+// 配信を受けている間に観戦を始めたクライアントも、送られたメッセージを JSON にして最新の状態に追いつく
+// （-race で、最初に送る全ての状態が hub の状態と値を共有していないことを確かめる）
+func TestHubConcurrentWatchers(t *testing.T) {
+	h := newHub()
+	s := states(300)
+	messages := deltas("alice", s)
+
+	// 観戦を始めては最初のメッセージを JSON にするクライアントが終わるまで、繰り返し配信する
+	stop := make(chan struct{})
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for {
+			for _, m := range messages {
+				h.update(m)
+			}
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				ch := h.subscribe()
+				if _, err := json.Marshal(<-ch); err != nil {
+					t.Error(err)
+				}
+				h.unsubscribe(ch)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-published
+
+	// 全て配信した後に観戦を始めると、最新の状態を受け取る
+	ch := h.subscribe()
+	defer h.unsubscribe(ch)
+	m := <-ch
+	got := &netplay.Snapshot{}
+	got.Apply(*m.State)
+	if want := s[len(s)-1]; m.Game != "alice" || !reflect.DeepEqual(got, want) {
+		t.Errorf("state of %q = %+v, want %+v", m.Game, got, want)
+	}
+}
+
+func TestWatchOverWebSocket(t *testing.T) {
+	h := newHub()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/publish", h.publish)
+	mux.HandleFunc("/watch", h.watch)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	watcher, err := netplay.Dial(url + "/watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	publisher, err := netplay.Dial(url + "/publish")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := states(50)
+	for _, m := range deltas("bob", s) {
+		if err := publisher.Send(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 観戦を始めた時点の状態と、その後の差分から、最後に配信した状態に追いつく
+	got := &netplay.Snapshot{}
+	want := s[len(s)-1]
+	timeout := time.After(5 * time.Second)
+	for !reflect.DeepEqual(got, want) {
+		select {
+		case m, ok := <-watcher.Messages():
+			if !ok {
+				t.Fatal("watch connection closed")
+			}
+			if m.Type == netplay.TypeState && m.Game == "bob" {
+				got.Apply(*m.State)
+			}
+		case <-timeout:
+			t.Fatalf("state = %+v, want %+v", got, want)
+		}
+	}
+
+	// 配信していたクライアントが切断すると、ゲームが取り除かれる
+	publisher.Close()
+	for {
+		select {
+		case m, ok := <-watcher.Messages():
+			if !ok {
+				t.Fatal("watch connection closed")
+			}
+			if m.Type == netplay.TypeRemove && m.Game == "bob" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("no remove message after the publisher left")
+		}
+	}
+}
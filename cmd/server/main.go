@@ -0,0 +1,33 @@
+// server は通信対戦と観戦の中継サーバー
+//
+//	go run ./cmd/server -addr :8080
+//
+// 次のエンドポイントを持つ。
+//
+//   - /ws: 同じ部屋（/ws?room=名前）に入った 2 人を組にして対戦を始め、その後は互いのメッセージを中継する
+//   - /publish: ゲームの状態の配信を受け付ける（WebSocket）
+//   - /watch: 配信されている全てのゲームの状態を送る（WebSocket）
+//   - /events: /watch と同じ内容を Server-Sent Events で送る
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"square-face-tetris/app/domain/netplay"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "待ち受けるアドレス")
+	flag.Parse()
+
+	http.Handle("/ws", netplay.NewRelay())
+
+	h := newHub()
+	http.HandleFunc("/publish", h.publish)
+	http.HandleFunc("/watch", h.watch)
+	http.HandleFunc("/events", h.events)
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
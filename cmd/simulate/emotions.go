@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/bot"
+	"square-face-tetris/app/domain/dataset"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/input"
+)
+
+// emotionStream は各フレームで判定されたことにする表情を返す
+type emotionStream func(e *engine.Engine, b *bot.Bot) []int
+
+func noEmotions(*engine.Engine, *bot.Bot) []int {
+	return nil
+}
+
+// 顔の操作の割り当て（ゲームの初期設定と同じ）
+var faceBindings = input.DefaultBindings().Face
+
+// face は 1 つの顔の表情の入力
+// 表情は次のテトリミノの選択に使い、判定され始めた表情・ジェスチャーは faceBindings で操作に変換する
+type face struct {
+	emotions emotionStream
+	previous []int // 直前のフレームの表情
+}
+
+// update はこのフレームの表情と、それによる操作を返す
+func (f *face) update(e *engine.Engine, b *bot.Bot) ([]int, []input.Action) {
+	emotions := f.emotions(e, b)
+	var actions []input.Action
+	for _, emotion := range emotions {
+		if !slices.Contains(f.previous, emotion) {
+			actions = append(actions, input.ActionsFor(faceBindings, domain.EmotionName(emotion))...)
+		}
+	}
+	f.previous = emotions
+	return emotions, actions
+}
+
+// parseEmotions は -emotions の指定から、シードごとに表情の入力を作る関数を返す
+func parseEmotions(spec string) (func(seed int64) emotionStream, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "none":
+		return func(int64) emotionStream { return noEmotions }, nil
+
+	case "always":
+		index := domain.EmotionIndex(arg)
+		if index < 0 {
+			return nil, fmt.Errorf("不明な表情です: %s", arg)
+		}
+		return func(int64) emotionStream {
+			return func(*engine.Engine, *bot.Bot) []int { return []int{index} }
+		}, nil
+
+	case "random":
+		hz := 2.0
+		if arg != "" {
+			v, err := strconv.ParseFloat(arg, 64)
+			if err != nil || v <= 0 {
+				return nil, fmt.Errorf("表情を変える頻度が不正です: %s", arg)
+			}
+			hz = v
+		}
+		return func(seed int64) emotionStream { return randomEmotions(seed, hz) }, nil
+
+	case "dataset":
+		samples, err := dataset.ReadFiles(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(samples) == 0 {
+			return nil, fmt.Errorf("データセットが空です: %s", arg)
+		}
+		return func(int64) emotionStream { return datasetEmotions(samples) }, nil
+
+	case "best", "worst":
+		worst := kind == "worst"
+		return func(int64) emotionStream { return oracleEmotions(worst) }, nil
+	}
+	return nil, fmt.Errorf("不明な表情の入力です: %s", spec)
+}
+
+// randomEmotions は hz 回/秒、無表情と次のテトリミノを選ぶ表情の中からランダムに表情を変える
+func randomEmotions(seed int64, hz float64) emotionStream {
+	rng := rand.New(rand.NewSource(seed))
+	interval := int(engine.TPS / hz)
+	if interval < 1 {
+		interval = 1
+	}
+	var current []int
+	return func(e *engine.Engine, _ *bot.Bot) []int {
+		if e.Frame%interval == 0 {
+			i := rng.Intn(len(e.ChoiceEmotions) + 1)
+			if i == len(e.ChoiceEmotions) {
+				current = nil
+			} else {
+				current = []int{e.ChoiceEmotions[i]}
+			}
+		}
+		return current
+	}
+}
+
+// datasetEmotions は記録したデータセットのラベルを、記録した時刻の通りに繰り返し再生する
+// 時刻のない記録は 1 件を 3 フレーム（表情分析の初期値の 20 FPS）として扱う
+func datasetEmotions(samples []dataset.Sample) emotionStream {
+	type label struct {
+		frame   int
+		emotion int
+	}
+	labels := make([]label, len(samples))
+	for i, s := range samples {
+		frame := i * 3
+		if s.Time != 0 && samples[0].Time != 0 {
+			frame = int((s.Time - samples[0].Time) * engine.TPS / 1000)
+		}
+		labels[i] = label{frame: frame, emotion: domain.EmotionIndex(s.Label)}
+	}
+	sort.SliceStable(labels, func(i, j int) bool { return labels[i].frame < labels[j].frame })
+	length := labels[len(labels)-1].frame + 1
+
+	return func(e *engine.Engine, _ *bot.Bot) []int {
+		frame := e.Frame % length
+		i := sort.Search(len(labels), func(i int) bool { return labels[i].frame > frame }) - 1
+		if i < 0 || labels[i].emotion < 0 {
+			return nil // NEUTRAL
+		}
+		return []int{labels[i].emotion}
+	}
+}
+
+// oracleEmotions は次のテトリミノを選ぶときに、CPU の評価で最も良い（worst なら最も悪い）候補の表情を返す
+// 表情で選べる範囲でゲームがどこまで易しく・難しくなるかの上限を見るために使う
+func oracleEmotions(worst bool) emotionStream {
+	return func(e *engine.Engine, b *bot.Bot) []int {
+		// 次のテトリミノは、現在のテトリミノが固定された次のフレームで選ばれる
+		if e.Current != nil {
+			return nil
+		}
+		chosen, chosenScore := -1, 0.0
+		for i, emotion := range e.ChoiceEmotions {
+			if i+1 >= len(e.Next) {
+				break
+			}
+			score := -1e9 // 置けない候補は最も悪い
+			if p, ok := b.BestFor(e.Board, e.Next[i+1]); ok {
+				score = p.Score
+			}
+			if chosen < 0 || (!worst && score > chosenScore) || (worst && score < chosenScore) {
+				chosen, chosenScore = emotion, score
+			}
+		}
+		if chosen < 0 {
+			return nil
+		}
+		return []int{chosen}
+	}
+}
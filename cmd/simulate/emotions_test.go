@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain/bot"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/input"
+)
+
+// 判定され始めた表情・ジェスチャーだけが、顔の操作の割り当ての通りに操作になる
+func TestFaceUpdate(t *testing.T) {
+	frames := [][]int{
+		nil,
+		{constants.WINK_LEFT},
+		{constants.WINK_LEFT},
+		{constants.SMILE},
+		{constants.SMILE, constants.DOUBLE_BLINK},
+		{constants.WINK_RIGHT, constants.DOUBLE_BLINK},
+	}
+	want := [][]input.Action{
+		nil,
+		{input.MoveLeft},
+		nil,
+		nil,
+		{input.RotateCW},
+		{input.MoveRight},
+	}
+
+	frame := 0
+	f := &face{emotions: func(*engine.Engine, *bot.Bot) []int { return frames[frame] }}
+	for frame = range frames {
+		emotions, actions := f.update(nil, nil)
+		if !reflect.DeepEqual(emotions, frames[frame]) || !reflect.DeepEqual(actions, want[frame]) {
+			t.Errorf("frame %d: emotions, actions = %v, %v, want %v, %v", frame, emotions, actions, frames[frame], want[frame])
+		}
+	}
+}
+
+// 協力プレイでは、次のテトリミノを選ぶ顔の表情は操作にならない
+func TestPlayCoop(t *testing.T) {
+	opts := engine.DefaultOptions
+	opts.TimeLimit = engine.Duration(600)
+	winks := func(e *engine.Engine, _ *bot.Bot) []int {
+		if e.Frame%20 < 10 {
+			return []int{constants.WINK_LEFT}
+		}
+		return nil
+	}
+	// 表情のフレーム数の統計は除き、盤面の進み方だけを比べる
+	type outcome struct {
+		score, lines, pieces int
+		placed               map[string]int
+	}
+	run := func(mover, chooser emotionStream) outcome {
+		m := &face{emotions: mover}
+		c := m
+		if chooser != nil {
+			c = &face{emotions: chooser}
+		}
+		r := play(engine.New(1, opts), bot.New(), m, c)
+		return outcome{r.score, r.lines, r.stats.Pieces, r.pieces}
+	}
+
+	alone := run(noEmotions, nil)
+	if got := run(noEmotions, winks); !reflect.DeepEqual(got, alone) {
+		t.Errorf("the chooser's winks changed the game: %+v, want %+v", got, alone)
+	}
+	if got := run(winks, noEmotions); reflect.DeepEqual(got, alone) {
+		t.Error("the mover's winks did not change the game")
+	}
+	if got, want := run(winks, nil), run(winks, noEmotions); !reflect.DeepEqual(got, want) {
+		t.Errorf("a single face with winks = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+// simulate は CPU に大量のゲームを遊ばせ、スコアの分布・生存時間・テトリミノの統計を表示する
+// 表情で次のテトリミノを選ぶ仕組みが、ゲームを易しくしているか難しくしているかを確かめるのに使う
+//
+// テトリミノは CPU が動かし、表情の入力は次のテトリミノの選択と、顔の操作の割り当てに従った操作に使う。
+// 協力プレイ（-mode coop）では、移動する顔（-mover）と次のテトリミノを選ぶ顔（-emotions）の入力を分ける
+//
+//	go run ./cmd/simulate -n 1000 -emotions random:2
+//	go run ./cmd/simulate -n 1000 -randomizer bag
+//	go run ./cmd/simulate -mode coop -mover dataset:winks.jsonl
+//	go run ./cmd/simulate -emotions dataset:landmarks.jsonl -csv games.csv
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"square-face-tetris/app/domain/bot"
+	"square-face-tetris/app/domain/engine"
+	"square-face-tetris/app/domain/leaderboard"
+)
+
+// 次のテトリミノの選び方（-randomizer）ごとの engine.Options.Randomizer
+var randomizers = map[string]string{
+	"emotion": engine.RandomizerEmotion,
+	"bag":     engine.RandomizerBag,
+	"uniform": engine.RandomizerUniform,
+}
+
+// modeOptions はモードのルールを返す
+// ランキングのあるモードはどれも同じルールで、操作する顔の数だけが違う（play の movers を参照）
+func modeOptions(mode string) (engine.Options, bool) {
+	for _, m := range leaderboard.GameModes {
+		if m == mode {
+			return engine.DefaultOptions, true
+		}
+	}
+	return engine.Options{}, false
+}
+
+func main() {
+	n := flag.Int("n", 1000, "遊ばせるゲームの数")
+	mode := flag.String("mode", leaderboard.ModeNormal, "モード（"+strings.Join(leaderboard.GameModes, ", ")+"）")
+	randomizer := flag.String("randomizer", "emotion", "次のテトリミノの選び方（emotion: 表情で候補から選ぶ, bag: 7 種類を 1 組にして順番に出す, uniform: 毎回 7 種類からランダムに選ぶ）")
+	emotions := flag.String("emotions", "random:2", "表情の入力（none, always:SMILE, random:2（Hz）, dataset:path.jsonl, best, worst）")
+	mover := flag.String("mover", "", "協力プレイで移動する顔の表情の入力（空なら -emotions と同じ指定を別のシードで使う）")
+	level := flag.Int("level", bot.MaxLevel, "CPU の強さ（1 から 10）")
+	hold := flag.Bool("hold", true, "CPU がホールドを使うか")
+	dropInterval := flag.Duration("drop", 0, "落下間隔（0 ならモードの設定）")
+	timeLimit := flag.Duration("time", 0, "タイムリミット（0 ならモードの設定）")
+	seed := flag.Int64("seed", 1, "最初のゲームのシード（i 番目のゲームは seed+i）")
+	parallel := flag.Int("parallel", runtime.NumCPU(), "同時に遊ばせるゲームの数")
+	csvPath := flag.String("csv", "", "ゲームごとの結果を書き出す CSV ファイル")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: simulate [flags]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	opts, ok := modeOptions(*mode)
+	if !ok {
+		log.Fatalf("不明なモードです: %s", *mode)
+	}
+	if opts.Randomizer, ok = randomizers[*randomizer]; !ok {
+		log.Fatalf("不明な選び方です: %s", *randomizer)
+	}
+	if *dropInterval > 0 {
+		opts.DropInterval = *dropInterval
+	}
+	if *timeLimit > 0 {
+		opts.TimeLimit = *timeLimit
+	}
+	newStream, err := parseEmotions(*emotions)
+	if err != nil {
+		log.Fatalf("表情の入力を指定できません: %v", err)
+	}
+	coop := *mode == leaderboard.ModeCoop
+	if !coop && *mover != "" {
+		log.Fatalf("-mover は協力プレイ（-mode %s）でだけ指定できます", leaderboard.ModeCoop)
+	}
+	newMoverStream := newStream
+	if *mover != "" {
+		if newMoverStream, err = parseEmotions(*mover); err != nil {
+			log.Fatalf("移動する顔の表情の入力を指定できません: %v", err)
+		}
+	}
+
+	start := time.Now()
+	results := make([]result, *n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < *parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				b := bot.NewLevel(*level)
+				b.UseHold = *hold
+				gameSeed := *seed + int64(i)
+				chooser := &face{emotions: newStream(gameSeed)}
+				moverFace := chooser
+				if coop {
+					// 2 人目の顔は、同じ指定でも 1 人目とは別の乱数で表情を変える
+					moverFace = &face{emotions: newMoverStream(^gameSeed)}
+				}
+				results[i] = play(engine.New(gameSeed, opts), b, moverFace, chooser)
+			}
+		}()
+	}
+	for i := 0; i < *n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	fmt.Printf("mode=%s randomizer=%s emotions=%s", *mode, *randomizer, *emotions)
+	if coop {
+		fmt.Printf(" mover=%s", cmp.Or(*mover, *emotions))
+	}
+	fmt.Printf(" level=%d hold=%t games=%d (%s)\n\n", *level, *hold, *n, time.Since(start).Round(time.Millisecond))
+	printSummary(os.Stdout, results, opts)
+
+	if *csvPath != "" {
+		if err := writeCSV(*csvPath, results); err != nil {
+			log.Fatalf("CSV の書き出しに失敗しました: %v", err)
+		}
+	}
+}
+
+// result は 1 ゲームの結果
+type result struct {
+	seed     int64
+	score    int
+	lines    int
+	frames   int
+	survived bool // タイムリミットまで続いた
+	pieces   map[string]int
+	stats    engine.Stats
+}
+
+// play はゲームが終わるまで CPU に遊ばせ、出てきたテトリミノの種類を数える
+// mover の表情による操作を CPU の操作に加え、chooser の表情で次のテトリミノを選ぶ
+// 1 つの顔で遊ぶモードでは mover と chooser に同じ顔を渡す
+func play(e *engine.Engine, b *bot.Bot, mover, chooser *face) result {
+	r := result{seed: e.Seed, pieces: map[string]int{}}
+	var current *engine.Tetromino
+	for !e.Over {
+		emotions, actions := mover.update(e, b)
+		if chooser != mover {
+			emotions, _ = chooser.update(e, b)
+		}
+		e.Step(append(b.Actions(e), actions...), emotions)
+		e.PopEvents()
+		if e.Current != nil && e.Current != current {
+			current = e.Current
+			r.pieces[current.Name]++
+		}
+	}
+	r.score = e.Score
+	r.lines = e.Lines
+	r.frames = e.Frame
+	r.survived = e.RemainingTime() == 0
+	r.stats = e.Stats
+	return r
+}
+
+func writeCSV(path string, results []result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"seed", "score", "lines", "seconds", "survived", "pieces", "tspins", "maxCombo"})
+	for _, r := range results {
+		w.Write([]string{
+			strconv.FormatInt(r.seed, 10),
+			strconv.Itoa(r.score),
+			strconv.Itoa(r.lines),
+			strconv.FormatFloat(engine.Duration(r.frames).Seconds(), 'f', 2, 64),
+			strconv.FormatBool(r.survived),
+			strconv.Itoa(r.stats.Pieces),
+			strconv.Itoa(r.stats.TSpins),
+			strconv.Itoa(r.stats.MaxCombo),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
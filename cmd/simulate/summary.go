@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"square-face-tetris/app/constants"
+	"square-face-tetris/app/domain"
+	"square-face-tetris/app/domain/engine"
+)
+
+// ヒストグラムの階級の数と、棒の最大の長さ
+const (
+	histogramBins  = 10
+	histogramWidth = 40
+)
+
+// printSummary はスコアの分布・生存時間・テトリミノの統計を表示する
+func printSummary(w io.Writer, results []result, opts engine.Options) {
+	if len(results) == 0 {
+		return
+	}
+
+	scores := make([]float64, len(results))
+	lines := make([]float64, len(results))
+	seconds := make([]float64, len(results))
+	survived := 0
+	for i, r := range results {
+		scores[i] = float64(r.score)
+		lines[i] = float64(r.lines)
+		seconds[i] = engine.Duration(r.frames).Seconds()
+		if r.survived {
+			survived++
+		}
+	}
+
+	fmt.Fprintf(w, "%-12s %10s %10s %10s %10s %10s %10s %10s\n", "", "mean", "stddev", "min", "p10", "p50", "p90", "max")
+	printDistribution(w, "score", scores)
+	printDistribution(w, "lines", lines)
+	printDistribution(w, "seconds", seconds)
+	fmt.Fprintf(w, "\nタイムリミット（%s）まで続いたゲーム: %d / %d (%.1f%%)\n\n", opts.TimeLimit, survived, len(results), percent(survived, len(results)))
+
+	fmt.Fprintln(w, "スコアの分布")
+	printHistogram(w, scores)
+
+	// テトリミノの種類ごとの割合と、消した行数ごとの回数
+	pieces := map[string]int{}
+	var lineClears [5]int
+	emotionPicks := make([]int, constants.EMOTION_COUNT)
+	totalPieces, tSpins := 0, 0
+	for _, r := range results {
+		for name, count := range r.pieces {
+			pieces[name] += count
+			totalPieces += count
+		}
+		for i, count := range r.stats.LineClears {
+			lineClears[i] += count
+		}
+		for i, count := range r.stats.EmotionPicks {
+			if i < len(emotionPicks) {
+				emotionPicks[i] += count
+			}
+		}
+		tSpins += r.stats.TSpins
+	}
+
+	fmt.Fprintln(w, "\nテトリミノの種類")
+	for _, t := range engine.Tetrominos {
+		fmt.Fprintf(w, "  %-2s %8d (%5.1f%%)\n", t.Name, pieces[t.Name], percent(pieces[t.Name], totalPieces))
+	}
+
+	fmt.Fprintln(w, "\n消した行数（1 ゲームあたり）")
+	for i := 1; i < len(lineClears); i++ {
+		fmt.Fprintf(w, "  %-8s %8.2f\n", engine.LineClearNames[i], float64(lineClears[i])/float64(len(results)))
+	}
+	fmt.Fprintf(w, "  %-8s %8.2f\n", "T-Spin", float64(tSpins)/float64(len(results)))
+
+	fmt.Fprintln(w, "\n表情で次のテトリミノを選んだ回数（1 ゲームあたり）")
+	for _, emotion := range engine.DefaultChoiceEmotions {
+		fmt.Fprintf(w, "  %-10s %8.2f\n", domain.EmotionName(emotion), float64(emotionPicks[emotion])/float64(len(results)))
+	}
+}
+
+func printDistribution(w io.Writer, name string, values []float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	mean := 0.0
+	for _, v := range sorted {
+		mean += v
+	}
+	mean /= float64(len(sorted))
+	variance := 0.0
+	for _, v := range sorted {
+		variance += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(sorted)))
+
+	fmt.Fprintf(w, "%-12s %10.1f %10.1f %10.1f %10.1f %10.1f %10.1f %10.1f\n", name, mean, stddev,
+		sorted[0], quantile(sorted, 0.1), quantile(sorted, 0.5), quantile(sorted, 0.9), sorted[len(sorted)-1])
+}
+
+// quantile は並べ替えた値の q 分位数を返す
+func quantile(sorted []float64, q float64) float64 {
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+func printHistogram(w io.Writer, values []float64) {
+	min, max := values[0], values[0]
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	width := (max - min) / histogramBins
+	if width == 0 {
+		width = 1
+	}
+
+	var counts [histogramBins]int
+	most := 0
+	for _, v := range values {
+		bin := int((v - min) / width)
+		if bin >= histogramBins {
+			bin = histogramBins - 1
+		}
+		counts[bin]++
+		if counts[bin] > most {
+			most = counts[bin]
+		}
+	}
+	for i, count := range counts {
+		bar := strings.Repeat("#", count*histogramWidth/most)
+		fmt.Fprintf(w, "  %8.0f - %8.0f %6d %s\n", min+float64(i)*width, min+float64(i+1)*width, count, bar)
+	}
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
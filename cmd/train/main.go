@@ -0,0 +1,61 @@
+// train は記録したランドマークのデータセットから表情分類モデルを学習し、
+// JSON ファイルとして保存する
+//
+//	go run ./cmd/train -type knn -o model/emotion.json session1.jsonl session2.jsonl
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"square-face-tetris/app/domain/dataset"
+	"square-face-tetris/app/domain/model"
+)
+
+func main() {
+	opts := model.Options{Logistic: model.DefaultLogisticOptions}
+	flag.StringVar(&opts.Type, "type", model.TypeKNN, "モデルの種類（knn, logistic）")
+	flag.IntVar(&opts.K, "k", 5, "k 近傍法の k")
+	flag.IntVar(&opts.Logistic.Epochs, "epochs", opts.Logistic.Epochs, "ロジスティック回帰の反復回数")
+	flag.Float64Var(&opts.Logistic.LearningRate, "lr", opts.Logistic.LearningRate, "ロジスティック回帰の学習率")
+	flag.Float64Var(&opts.Logistic.L2, "l2", opts.Logistic.L2, "ロジスティック回帰の L2 正則化")
+	output := flag.String("o", "model/emotion.json", "モデルの出力先")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: train [flags] dataset.jsonl...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	samples, err := dataset.ReadFiles(flag.Args()...)
+	if err != nil {
+		log.Fatalf("データセットの読み込みに失敗しました: %v", err)
+	}
+
+	m, err := model.Train(samples, opts)
+	if err != nil {
+		log.Fatalf("学習に失敗しました: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*output), 0o755); err != nil {
+		log.Fatalf("モデルを保存できません: %v", err)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		log.Fatalf("モデルを保存できません: %v", err)
+	}
+	defer f.Close()
+	if err := m.Save(f); err != nil {
+		log.Fatalf("モデルを保存できません: %v", err)
+	}
+
+	// 学習データに対する結果（汎化性能は別のデータで cmd/evaluate を使って確認する）
+	fmt.Printf("saved %s model to %s\n\n", opts.Type, *output)
+	dataset.Evaluate(m, samples).Fprint(os.Stdout)
+}
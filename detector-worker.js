@@ -0,0 +1,9 @@
+// 顔検出の Web Worker（dist/detector.wasm は cmd/detectworker をビルドしたもの）
+importScripts("lib/wasm_exec.js");
+
+const go = new Go();
+WebAssembly.instantiateStreaming(fetch("dist/detector.wasm"), go.importObject).then(result => {
+    go.run(result.instance);
+}).catch(err => {
+    postMessage({ type: "error", error: String(err) });
+});
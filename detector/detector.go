@@ -11,6 +11,8 @@ import (
 	"net/url"
 	"syscall/js"
 
+	"square-face-tetris/app/domain/detection"
+
 	pigo "github.com/esimov/pigo/core"
 )
 
@@ -20,8 +22,6 @@ type FlpCascade struct {
 	error
 }
 
-const perturb = 63
-
 var (
 	cascade          []byte
 	puplocCascade    []byte
@@ -43,13 +43,19 @@ type Detector struct {
 	errChan  chan error
 	done     chan struct{}
 
-	window js.Value
+	window     js.Value
+	tracker    *detection.Tracker
+	identities *detection.Identities
+	options    detection.Options
 }
 
 // NewDetector initializes a new constructor function.
 func NewDetector() *Detector {
 	var d Detector
 	d.window = js.Global()
+	d.tracker = detection.NewTracker()
+	d.identities = detection.NewIdentities()
+	d.options = detection.DefaultOptions()
 
 	return &d
 }
@@ -186,13 +192,77 @@ func (d *Detector) DetectFaces(pixels []uint8, width, height int) [][]int {
 	return dets
 }
 
+// Configure sets the detector parameters used from the next frame.
+func (d *Detector) Configure(o detection.Options) {
+	d.options = o
+}
+
+// Detect runs the face detection over a grayscale frame. With a single face,
+// only the region around the tracked face is searched until the face is lost.
+// The pupils and the landmark points are detected for up to MaxFaces faces,
+// and a face is rejected when they are not found.
+func (d *Detector) Detect(f detection.Frame) detection.Result {
+	var r detection.Result
+	o := d.options
+	full := detection.FullRegion(f.Width, f.Height, o.MinSize, o.MaxSize)
+	region := full
+	if o.MaxFaces == 1 {
+		region = d.tracker.Region(f.Time, f.Width, f.Height, o.MinSize, o.MaxSize)
+	}
+	faces := o.Select(d.detectRegion(f.Pixels, f.Width, region), f.Width, f.Height)
+	if len(faces) == 0 && !region.Full {
+		region = full
+		faces = o.Select(d.detectRegion(f.Pixels, f.Width, region), f.Width, f.Height)
+	}
+	r.Faces = d.tracker.Update(f.Time, region, faces)
+	ids := d.identities.Assign(r.Faces, f.Time)
+
+	imgParams = &pigo.ImageParams{
+		Pixels: f.Pixels,
+		Rows:   f.Height,
+		Cols:   f.Width,
+		Dim:    f.Width,
+	}
+	for i := 0; i < len(r.Faces) && i < o.MaxFaces; i++ {
+		r.Tracks = append(r.Tracks, d.detectTrack(ids[i], r.Faces[i]))
+	}
+	return r
+}
+
+// detectTrack detects the pupils and the landmark points of the face.
+func (d *Detector) detectTrack(id int, face []int) detection.Track {
+	t := detection.Track{ID: id, Face: face}
+	leftEye := d.DetectLeftPupil(face)
+	rightEye := d.DetectRightPupil(face)
+	if leftEye == nil || rightEye == nil {
+		t.Rejected = "pupils"
+		return t
+	}
+	landmarks := d.DetectLandmarkPoints(leftEye, rightEye)
+	if detection.CountLandmarks(landmarks) < d.options.MinLandmarks {
+		t.Rejected = "landmarks"
+		return t
+	}
+	t.Landmarks = landmarks
+	t.Pupils = [][]int{puplocToPoint(leftEye), puplocToPoint(rightEye)}
+	return t
+}
+
+// puplocToPoint converts a pupil into the [x, y, scale] format of the landmark points.
+func puplocToPoint(p *pigo.Puploc) []int {
+	if p == nil {
+		return nil
+	}
+	return []int{p.Col, p.Row, int(p.Scale)}
+}
+
 // DetectLeftPupil detects the left pupil
 func (d *Detector) DetectLeftPupil(results []int) *pigo.Puploc {
 	puploc := &pigo.Puploc{
 		Row:      results[0] - int(0.085*float32(results[2])),
 		Col:      results[1] - int(0.185*float32(results[2])),
 		Scale:    float32(results[2]) * 0.4,
-		Perturbs: perturb,
+		Perturbs: d.options.Perturb,
 	}
 	leftEye := puplocClassifier.RunDetector(*puploc, *imgParams, 0.0, false)
 	if leftEye.Row > 0 && leftEye.Col > 0 {
@@ -207,7 +277,7 @@ func (d *Detector) DetectRightPupil(results []int) *pigo.Puploc {
 		Row:      results[0] - int(0.085*float32(results[2])),
 		Col:      results[1] + int(0.185*float32(results[2])),
 		Scale:    float32(results[2]) * 0.4,
-		Perturbs: perturb,
+		Perturbs: d.options.Perturb,
 	}
 	rightEye := puplocClassifier.RunDetector(*puploc, *imgParams, 0.0, false)
 	if rightEye.Row > 0 && rightEye.Col > 0 {
@@ -225,13 +295,13 @@ func (d *Detector) DetectLandmarkPoints(leftEye, rightEye *pigo.Puploc) [][]int
 
 	for _, eye := range eyeCascades {
 		for _, flpc := range flpcs[eye] {
-			flp := flpc.GetLandmarkPoint(leftEye, rightEye, *imgParams, perturb, false)
+			flp := flpc.GetLandmarkPoint(leftEye, rightEye, *imgParams, d.options.Perturb, false)
 			if flp.Row > 0 && flp.Col > 0 {
 				det[idx] = append(det[idx], flp.Col, flp.Row, int(flp.Scale))
 			}
 			idx++
 
-			flp = flpc.GetLandmarkPoint(leftEye, rightEye, *imgParams, perturb, true)
+			flp = flpc.GetLandmarkPoint(leftEye, rightEye, *imgParams, d.options.Perturb, true)
 			if flp.Row > 0 && flp.Col > 0 {
 				det[idx] = append(det[idx], flp.Col, flp.Row, int(flp.Scale))
 			}
@@ -241,20 +311,45 @@ func (d *Detector) DetectLandmarkPoints(leftEye, rightEye *pigo.Puploc) [][]int
 
 	for _, mouth := range mouthCascade {
 		for _, flpc := range flpcs[mouth] {
-			flp := flpc.GetLandmarkPoint(leftEye, rightEye, *imgParams, perturb, false)
+			flp := flpc.GetLandmarkPoint(leftEye, rightEye, *imgParams, d.options.Perturb, false)
 			if flp.Row > 0 && flp.Col > 0 {
 				det[idx] = append(det[idx], flp.Col, flp.Row, int(flp.Scale))
 			}
 			idx++
 		}
 	}
-	flp := flpcs["lp84"][0].GetLandmarkPoint(leftEye, rightEye, *imgParams, perturb, true)
+	flp := flpcs["lp84"][0].GetLandmarkPoint(leftEye, rightEye, *imgParams, d.options.Perturb, true)
 	if flp.Row > 0 && flp.Col > 0 {
 		det[idx] = append(det[idx], flp.Col, flp.Row, int(flp.Scale))
 	}
 	return det
 }
 
+// detectRegion runs the face detector over the region of the frame and
+// returns the detected faces in the frame coordinates.
+func (d *Detector) detectRegion(pixels []uint8, width int, region detection.Region) [][]int {
+	// The pixels are indexed with the frame width, so the region needs no copy.
+	cParams := pigo.CascadeParams{
+		MinSize:     region.MinSize,
+		MaxSize:     region.MaxSize,
+		ShiftFactor: d.options.ShiftFactor,
+		ScaleFactor: d.options.ScaleFactor,
+		ImageParams: pigo.ImageParams{
+			Pixels: pixels[region.Top*width+region.Left:],
+			Rows:   region.Height,
+			Cols:   region.Width,
+			Dim:    width,
+		},
+	}
+	results := faceClassifier.ClusterDetections(faceClassifier.RunCascade(cParams, 0.0), d.options.IoU)
+
+	dets := make([][]int, len(results))
+	for i, res := range results {
+		dets[i] = []int{res.Row + region.Top, res.Col + region.Left, res.Scale, int(res.Q)}
+	}
+	return dets
+}
+
 // clusterDetection runs Pigo face detector core methods
 // and returns a cluster with the detected faces coordinates.
 func (d *Detector) clusterDetection(pixels []uint8, width, height int) []pigo.Detection {
@@ -265,10 +360,10 @@ func (d *Detector) clusterDetection(pixels []uint8, width, height int) []pigo.De
 		Dim:    height,
 	}
 	cParams := pigo.CascadeParams{
-		MinSize:     200,
-		MaxSize:     480,
-		ShiftFactor: 0.1,
-		ScaleFactor: 1.1,
+		MinSize:     d.options.MinSize,
+		MaxSize:     d.options.MaxSize,
+		ShiftFactor: d.options.ShiftFactor,
+		ScaleFactor: d.options.ScaleFactor,
 		ImageParams: *imgParams,
 	}
 
@@ -277,7 +372,7 @@ func (d *Detector) clusterDetection(pixels []uint8, width, height int) []pigo.De
 	dets := faceClassifier.RunCascade(cParams, 0.0)
 
 	// Calculate the intersection over union (IoU) of two clusters.
-	dets = faceClassifier.ClusterDetections(dets, 0.1)
+	dets = faceClassifier.ClusterDetections(dets, d.options.IoU)
 
 	return dets
 }